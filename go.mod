@@ -5,6 +5,7 @@ go 1.23
 require (
 	github.com/joho/godotenv v1.5.1
 	google.golang.org/genai v1.26.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
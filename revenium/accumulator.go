@@ -0,0 +1,184 @@
+package revenium
+
+import (
+	"bytes"
+	"sort"
+
+	"google.golang.org/genai"
+)
+
+// StreamAccumulator merges the chunks of a GenerateContentStream call into one
+// GenerateContentResponse, as if the call had not been streamed:
+//
+//	acc := revenium.NewStreamAccumulator()
+//	for chunk, err := range client.Models().GenerateContentStream(ctx, model, contents, nil) {
+//		if err != nil {
+//			return err
+//		}
+//		acc.Add(chunk)
+//	}
+//	resp := acc.Response()
+//
+// Candidates are merged by index. Consecutive text parts of the same kind (answer or
+// thought) are joined; function calls, inline data and other parts are kept as they
+// arrive. Citations and log probabilities are appended; finish reasons, safety
+// ratings, grounding metadata and usage metadata keep the latest value reported.
+// A StreamAccumulator is not safe for concurrent use.
+type StreamAccumulator struct {
+	resp       genai.GenerateContentResponse
+	candidates map[int32]*genai.Candidate
+	chunks     int
+}
+
+// NewStreamAccumulator returns an empty accumulator
+func NewStreamAccumulator() *StreamAccumulator {
+	return &StreamAccumulator{candidates: map[int32]*genai.Candidate{}}
+}
+
+// Add merges one chunk. Chunks are copied, so they may be changed after Add returns.
+func (a *StreamAccumulator) Add(chunk *genai.GenerateContentResponse) {
+	if chunk == nil {
+		return
+	}
+	a.chunks++
+
+	if chunk.SDKHTTPResponse != nil {
+		a.resp.SDKHTTPResponse = chunk.SDKHTTPResponse
+	}
+	if !chunk.CreateTime.IsZero() {
+		a.resp.CreateTime = chunk.CreateTime
+	}
+	if chunk.ModelVersion != "" {
+		a.resp.ModelVersion = chunk.ModelVersion
+	}
+	if chunk.ResponseID != "" {
+		a.resp.ResponseID = chunk.ResponseID
+	}
+	if chunk.PromptFeedback != nil {
+		a.resp.PromptFeedback = chunk.PromptFeedback
+	}
+	// Usage is cumulative; the last report covers the whole call
+	if chunk.UsageMetadata != nil {
+		a.resp.UsageMetadata = chunk.UsageMetadata
+	}
+
+	for _, candidate := range chunk.Candidates {
+		if candidate != nil {
+			a.addCandidate(candidate)
+		}
+	}
+}
+
+// addCandidate merges a chunk's candidate into the candidate with the same index
+func (a *StreamAccumulator) addCandidate(chunk *genai.Candidate) {
+	merged, ok := a.candidates[chunk.Index]
+	if !ok {
+		merged = &genai.Candidate{Index: chunk.Index}
+		a.candidates[chunk.Index] = merged
+	}
+
+	if chunk.Content != nil {
+		if merged.Content == nil {
+			merged.Content = &genai.Content{}
+		}
+		if chunk.Content.Role != "" {
+			merged.Content.Role = chunk.Content.Role
+		}
+		for _, part := range chunk.Content.Parts {
+			if part != nil {
+				merged.Content.Parts = appendPart(merged.Content.Parts, part)
+			}
+		}
+	}
+
+	if chunk.CitationMetadata != nil && len(chunk.CitationMetadata.Citations) > 0 {
+		if merged.CitationMetadata == nil {
+			merged.CitationMetadata = &genai.CitationMetadata{}
+		}
+		merged.CitationMetadata.Citations = append(merged.CitationMetadata.Citations, chunk.CitationMetadata.Citations...)
+	}
+	if chunk.LogprobsResult != nil {
+		if merged.LogprobsResult == nil {
+			merged.LogprobsResult = &genai.LogprobsResult{}
+		}
+		merged.LogprobsResult.ChosenCandidates = append(merged.LogprobsResult.ChosenCandidates, chunk.LogprobsResult.ChosenCandidates...)
+		merged.LogprobsResult.TopCandidates = append(merged.LogprobsResult.TopCandidates, chunk.LogprobsResult.TopCandidates...)
+	}
+
+	if chunk.FinishReason != "" {
+		merged.FinishReason = chunk.FinishReason
+	}
+	if chunk.FinishMessage != "" {
+		merged.FinishMessage = chunk.FinishMessage
+	}
+	if len(chunk.SafetyRatings) > 0 {
+		merged.SafetyRatings = chunk.SafetyRatings
+	}
+	if chunk.GroundingMetadata != nil {
+		merged.GroundingMetadata = chunk.GroundingMetadata
+	}
+	if chunk.URLContextMetadata != nil {
+		merged.URLContextMetadata = chunk.URLContextMetadata
+	}
+	if chunk.TokenCount != 0 {
+		merged.TokenCount = chunk.TokenCount
+	}
+	if chunk.AvgLogprobs != 0 {
+		merged.AvgLogprobs = chunk.AvgLogprobs
+	}
+}
+
+// appendPart adds a copy of part, joining it to the last part when both are text of
+// the same kind
+func appendPart(parts []*genai.Part, part *genai.Part) []*genai.Part {
+	if n := len(parts); n > 0 && isTextPart(parts[n-1]) && isTextPart(part) &&
+		parts[n-1].Thought == part.Thought && len(parts[n-1].ThoughtSignature) == 0 {
+		last := *parts[n-1]
+		last.Text += part.Text
+		last.ThoughtSignature = bytes.Clone(part.ThoughtSignature)
+		parts[n-1] = &last
+		return parts
+	}
+
+	copied := *part
+	copied.ThoughtSignature = bytes.Clone(part.ThoughtSignature)
+	return append(parts, &copied)
+}
+
+// isTextPart reports whether part holds only text (with an optional thought signature)
+func isTextPart(part *genai.Part) bool {
+	return part.Text != "" && part.InlineData == nil && part.FileData == nil && part.FunctionCall == nil &&
+		part.FunctionResponse == nil && part.ExecutableCode == nil && part.CodeExecutionResult == nil &&
+		part.VideoMetadata == nil
+}
+
+// Chunks returns the number of chunks added
+func (a *StreamAccumulator) Chunks() int {
+	return a.chunks
+}
+
+// Response returns the merged response so far, with candidates ordered by index.
+// It returns a new response on every call; the accumulator keeps its own copy.
+func (a *StreamAccumulator) Response() *genai.GenerateContentResponse {
+	resp := a.resp
+	resp.Candidates = make([]*genai.Candidate, 0, len(a.candidates))
+	for _, candidate := range a.candidates {
+		copied := *candidate
+		if candidate.Content != nil {
+			content := *candidate.Content
+			content.Parts = append([]*genai.Part(nil), candidate.Content.Parts...)
+			copied.Content = &content
+		}
+		if candidate.CitationMetadata != nil {
+			citations := *candidate.CitationMetadata
+			copied.CitationMetadata = &citations
+		}
+		if candidate.LogprobsResult != nil {
+			logprobs := *candidate.LogprobsResult
+			copied.LogprobsResult = &logprobs
+		}
+		resp.Candidates = append(resp.Candidates, &copied)
+	}
+	sort.Slice(resp.Candidates, func(i, j int) bool { return resp.Candidates[i].Index < resp.Candidates[j].Index })
+	return &resp
+}
@@ -0,0 +1,72 @@
+package revenium
+
+import (
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestStreamAccumulator(t *testing.T) {
+	chunks := []*genai.GenerateContentResponse{
+		{
+			ResponseID: "resp-1",
+			Candidates: []*genai.Candidate{{
+				Content: &genai.Content{Role: genai.RoleModel, Parts: []*genai.Part{
+					{Text: "Checking ", Thought: true},
+					{Text: "the weather"},
+				}},
+				CitationMetadata: &genai.CitationMetadata{Citations: []*genai.Citation{{URI: "https://a.example"}}},
+			}},
+			UsageMetadata: &genai.GenerateContentResponseUsageMetadata{PromptTokenCount: 8},
+		},
+		{
+			Candidates: []*genai.Candidate{
+				{
+					Content: &genai.Content{Parts: []*genai.Part{
+						{Text: " in Paris."},
+						{FunctionCall: &genai.FunctionCall{Name: "get_weather", Args: map[string]any{"city": "Paris"}}},
+					}},
+					CitationMetadata: &genai.CitationMetadata{Citations: []*genai.Citation{{URI: "https://b.example"}}},
+				},
+				{Index: 1, Content: &genai.Content{Parts: []*genai.Part{{Text: "Other"}}}},
+			},
+		},
+		{
+			Candidates: []*genai.Candidate{{
+				FinishReason:      genai.FinishReasonStop,
+				SafetyRatings:     []*genai.SafetyRating{{Category: genai.HarmCategoryHarassment}},
+				GroundingMetadata: &genai.GroundingMetadata{WebSearchQueries: []string{"paris weather"}},
+			}},
+			UsageMetadata: &genai.GenerateContentResponseUsageMetadata{PromptTokenCount: 8, CandidatesTokenCount: 6, TotalTokenCount: 14},
+		},
+	}
+
+	accumulator := NewStreamAccumulator()
+	for _, chunk := range chunks {
+		accumulator.Add(chunk)
+	}
+	chunks[0].Candidates[0].Content.Parts[1].Text = "changed"
+	resp := accumulator.Response()
+
+	if accumulator.Chunks() != 3 || resp.ResponseID != "resp-1" || resp.UsageMetadata.TotalTokenCount != 14 {
+		t.Errorf("unexpected response fields: chunks %d, %+v", accumulator.Chunks(), resp)
+	}
+	if len(resp.Candidates) != 2 || resp.Candidates[1].Content.Parts[0].Text != "Other" {
+		t.Fatalf("expected two candidates ordered by index, got %+v", resp.Candidates)
+	}
+
+	first := resp.Candidates[0]
+	parts := first.Content.Parts
+	if len(parts) != 3 || !parts[0].Thought || parts[1].Text != "the weather in Paris." || parts[2].FunctionCall.Name != "get_weather" {
+		t.Errorf("unexpected parts %+v", parts)
+	}
+	if resp.Text() != "the weather in Paris." {
+		t.Errorf("Text() = %q", resp.Text())
+	}
+	if first.Content.Role != genai.RoleModel || first.FinishReason != genai.FinishReasonStop {
+		t.Errorf("unexpected role %q or finish reason %q", first.Content.Role, first.FinishReason)
+	}
+	if len(first.CitationMetadata.Citations) != 2 || len(first.SafetyRatings) != 1 || first.GroundingMetadata == nil {
+		t.Errorf("expected citations, safety ratings and grounding to be kept, got %+v", first)
+	}
+}
@@ -0,0 +1,332 @@
+package revenium
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// Metering attributes of cache hits
+const (
+	cacheHitAttribute         = "cacheHit"
+	cacheSavedTokensAttribute = "cacheSavedTokens"
+)
+
+// defaultCacheTTL is how long responses are cached when no TTL is configured
+const defaultCacheTTL = time.Hour
+
+// ResponseCache stores serialized GenerateContent responses. Implementations must be
+// safe for concurrent use. Errors are logged and treated as cache misses.
+type ResponseCache interface {
+	// Get returns the value stored under key, or false when it is missing or expired
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key for ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// WithResponseCache caches successful GenerateContent responses in cache for ttl
+// (zero uses one hour). Cache hits are metered with zero provider tokens.
+func WithResponseCache(cache ResponseCache, ttl time.Duration) Option {
+	return func(c *Config) {
+		c.ResponseCache = cache
+		c.ResponseCacheTTL = ttl
+	}
+}
+
+// WithResponseCacheNamespace separates this client's cache entries from those of
+// other clients sharing the same ResponseCache, for example one namespace per tenant
+func WithResponseCacheNamespace(namespace string) Option {
+	return func(c *Config) {
+		c.ResponseCacheNamespace = namespace
+	}
+}
+
+// cacheScope identifies whose calls a cache entry answers, so clients with different
+// backends, projects, API keys or namespaces never share entries
+type cacheScope struct {
+	Namespace string `json:"namespace,omitempty"`
+	Backend   string `json:"backend"`
+	Project   string `json:"project,omitempty"`
+	Location  string `json:"location,omitempty"`
+	BaseURL   string `json:"baseUrl,omitempty"`
+	// APIKey only feeds the hash; it is never stored
+	APIKey string `json:"apiKey,omitempty"`
+}
+
+// cacheScope returns the scope of calls made through this interface's genai client
+func (m *ModelsInterface) cacheScope(namespace string) cacheScope {
+	scope := cacheScope{Namespace: namespace, Backend: m.provider.String()}
+	if m.client != nil {
+		clientConfig := m.client.ClientConfig()
+		scope.Backend = clientConfig.Backend.String()
+		scope.Project = clientConfig.Project
+		scope.Location = clientConfig.Location
+		scope.BaseURL = clientConfig.HTTPOptions.BaseURL
+		scope.APIKey = clientConfig.APIKey
+	}
+	return scope
+}
+
+// responseCacheKey returns the canonical hash of a GenerateContent request in scope
+func responseCacheKey(scope cacheScope, model string, contents []*genai.Content, config *genai.GenerateContentConfig) (string, error) {
+	data, err := json.Marshal(struct {
+		Scope    cacheScope                   `json:"scope"`
+		Model    string                       `json:"model"`
+		Contents []*genai.Content             `json:"contents"`
+		Config   *genai.GenerateContentConfig `json:"config,omitempty"`
+	}{scope, model, contents, config})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// cacheable reports whether a response is worth caching: a complete answer, not a
+// truncated, blocked or empty one
+func cacheable(resp *genai.GenerateContentResponse) bool {
+	return resp != nil && len(resp.Candidates) > 0 && resp.Candidates[0] != nil &&
+		resp.Candidates[0].FinishReason == genai.FinishReasonStop
+}
+
+// cachedResponse looks up a response. Lookup failures are logged and reported as misses.
+func (m *ModelsInterface) cachedResponse(ctx context.Context, cache ResponseCache, key string) (*genai.GenerateContentResponse, bool) {
+	data, ok, err := cache.Get(ctx, key)
+	if err != nil {
+		m.logger.Warn("Response cache lookup failed: %v", err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+
+	var resp genai.GenerateContentResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		m.logger.Warn("Response cache entry is unreadable: %v", err)
+		return nil, false
+	}
+	return &resp, true
+}
+
+// storeResponse caches a response without its HTTP response details
+func (m *ModelsInterface) storeResponse(ctx context.Context, cfg *Config, key string, resp *genai.GenerateContentResponse) {
+	stored := *resp
+	stored.SDKHTTPResponse = nil
+	data, err := json.Marshal(&stored)
+	if err == nil {
+		ttl := cfg.ResponseCacheTTL
+		if ttl <= 0 {
+			ttl = defaultCacheTTL
+		}
+		err = cfg.ResponseCache.Set(ctx, key, data, ttl)
+	}
+	if err != nil {
+		m.logger.Warn("Failed to cache response: %v", err)
+	}
+}
+
+// meterCacheHit sends the metering event of a cache hit: zero provider tokens, with
+// the tokens the original call used recorded as the saving
+func (m *ModelsInterface) meterCacheHit(ctx context.Context, resp *genai.GenerateContentResponse, model string, metadata map[string]interface{}, requestTime time.Time, config *genai.GenerateContentConfig) {
+	var savedTokens int64
+	if resp.UsageMetadata != nil {
+		savedTokens = int64(resp.UsageMetadata.TotalTokenCount)
+	}
+	m.meterWithoutUsage(ctx, resp, nil, model, metadata, requestTime, config, map[string]interface{}{
+		cacheHitAttribute:         true,
+		cacheSavedTokensAttribute: savedTokens,
+	})
+}
+
+// meterWithoutUsage sends the metering event of a call answered without its own
+// provider call, with zero tokens and the given attributes
+func (m *ModelsInterface) meterWithoutUsage(ctx context.Context, resp *genai.GenerateContentResponse, err error, model string, metadata map[string]interface{}, requestTime time.Time, config *genai.GenerateContentConfig, attributes map[string]interface{}) {
+	responseTime := time.Now()
+	var free *genai.GenerateContentResponse
+	if resp != nil {
+		free = &genai.GenerateContentResponse{Candidates: resp.Candidates}
+	}
+
+	m.parent.wg.Add(1)
+	go func() {
+		defer m.parent.wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				m.logger.Error("Metering goroutine panic: %v", r)
+				failMeteringReceipt(ctx, metadata, model, NewInternalError(fmt.Sprintf("metering event could not be built: %v", r), nil))
+			}
+		}()
+
+		payload := buildGoogleMeteringPayloadWithTimingAndVision(free, model, metadata, false,
+			requestTime, responseTime, responseTime, m.provider.String(), config, err, VisionDetectionResult{}, m.logger)
+		addPayloadAttributes(payload, attributes)
+
+		if err := m.parent.metering.send(ctx, MeteringEventCompletion, payload); err != nil {
+			m.logger.Error("Failed to send metering data: %v", err)
+		}
+	}()
+}
+
+// addPayloadAttributes merges attributes into the payload's attributes map
+func addPayloadAttributes(payload map[string]interface{}, attributes map[string]interface{}) {
+	existing, ok := payload["attributes"].(map[string]interface{})
+	if !ok {
+		existing = make(map[string]interface{}, len(attributes))
+		payload["attributes"] = existing
+	}
+	for key, value := range attributes {
+		existing[key] = value
+	}
+}
+
+// MemoryCache is an in-memory LRU ResponseCache
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List
+	entries    map[string]*list.Element
+	now        func() time.Time
+}
+
+// memoryCacheEntry is one value in a MemoryCache
+type memoryCacheEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewMemoryCache returns an LRU cache holding at most maxEntries responses (zero or
+// less means 1,000)
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &MemoryCache{maxEntries: maxEntries, order: list.New(), entries: map[string]*list.Element{}, now: time.Now}
+}
+
+// Get returns the value for key and marks it as recently used
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := element.Value.(*memoryCacheEntry)
+	if c.now().After(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	c.order.MoveToFront(element)
+	return entry.value, true, nil
+}
+
+// Set stores value, evicting the least recently used entry when the cache is full
+func (c *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &memoryCacheEntry{key: key, value: value, expiresAt: c.now().Add(ttl)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return nil
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryCacheEntry).key)
+	}
+	return nil
+}
+
+// Len returns the number of cached entries, including expired ones not yet evicted
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// DiskCache is a ResponseCache that keeps one file per response in a directory, so
+// cached responses survive restarts
+type DiskCache struct {
+	dir string
+	now func() time.Time
+}
+
+// diskCacheEntry is the file format of a DiskCache entry
+type diskCacheEntry struct {
+	ExpiresAt time.Time       `json:"expiresAt"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// NewDiskCache returns a cache storing responses in dir, creating it if needed
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, NewConfigError(fmt.Sprintf("failed to create cache directory %s", dir), err)
+	}
+	return &DiskCache{dir: dir, now: time.Now}, nil
+}
+
+// path returns the file for key. Keys are hex hashes, so they are safe file names.
+func (c *DiskCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// Get reads the entry for key, deleting it when it has expired
+func (c *DiskCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	data, err := os.ReadFile(c.path(key))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var entry diskCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false, err
+	}
+	if c.now().After(entry.ExpiresAt) {
+		os.Remove(c.path(key))
+		return nil, false, nil
+	}
+	return entry.Value, true, nil
+}
+
+// Set writes the entry for key. The file is written to a temporary name and renamed,
+// so readers never see a partial entry.
+func (c *DiskCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	data, err := json.Marshal(diskCacheEntry{ExpiresAt: c.now().Add(ttl), Value: value})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(key))
+}
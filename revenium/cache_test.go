@@ -0,0 +1,102 @@
+package revenium
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+func TestResponseCacheKey(t *testing.T) {
+	temperature := float32(0.2)
+	gemini := cacheScope{Backend: genai.BackendGeminiAPI.String(), APIKey: "key-a"}
+	key := func(scope cacheScope, model, text string, config *genai.GenerateContentConfig) string {
+		t.Helper()
+		k, err := responseCacheKey(scope, model, genai.Text(text), config)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return k
+	}
+
+	config := &genai.GenerateContentConfig{Temperature: &temperature}
+	base := key(gemini, "gemini-2.0-flash", "Classify: refund request", config)
+	if base != key(gemini, "gemini-2.0-flash", "Classify: refund request", &genai.GenerateContentConfig{Temperature: &temperature}) {
+		t.Error("equal requests must have the same key")
+	}
+	for name, other := range map[string]string{
+		"model":     key(gemini, "gemini-2.5-flash", "Classify: refund request", config),
+		"contents":  key(gemini, "gemini-2.0-flash", "Classify: shipping delay", config),
+		"config":    key(gemini, "gemini-2.0-flash", "Classify: refund request", nil),
+		"API key":   key(cacheScope{Backend: gemini.Backend, APIKey: "key-b"}, "gemini-2.0-flash", "Classify: refund request", config),
+		"namespace": key(cacheScope{Backend: gemini.Backend, APIKey: "key-a", Namespace: "tenant-b"}, "gemini-2.0-flash", "Classify: refund request", config),
+		"backend": key(cacheScope{Backend: genai.BackendVertexAI.String(), Project: "p", Location: "us-central1"},
+			"gemini-2.0-flash", "Classify: refund request", config),
+	} {
+		if other == base {
+			t.Errorf("a different %s must change the key", name)
+		}
+	}
+
+	vertex := func(project string) string {
+		return key(cacheScope{Backend: genai.BackendVertexAI.String(), Project: project, Location: "us-central1"}, "gemini-2.0-flash", "Classify: refund request", config)
+	}
+	if vertex("project-a") == vertex("project-b") {
+		t.Error("a different Google project must change the key")
+	}
+}
+
+func TestResponseCaches(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	memory := NewMemoryCache(2)
+	memory.now = clock
+	disk, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	disk.now = clock
+
+	for name, cache := range map[string]ResponseCache{"memory": memory, "disk": disk} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if err := cache.Set(ctx, "a", []byte(`{"answer":1}`), time.Minute); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			value, ok, err := cache.Get(ctx, "a")
+			if err != nil || !ok || string(value) != `{"answer":1}` {
+				t.Fatalf("expected the stored value, got %q, %v, %v", value, ok, err)
+			}
+			if _, ok, _ := cache.Get(ctx, "missing"); ok {
+				t.Error("missing keys must not be found")
+			}
+
+			now = now.Add(2 * time.Minute)
+			defer func() { now = now.Add(-2 * time.Minute) }()
+			if _, ok, _ := cache.Get(ctx, "a"); ok {
+				t.Error("expired entries must not be returned")
+			}
+		})
+	}
+}
+
+func TestMemoryCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryCache(2)
+	cache.Set(ctx, "a", []byte("1"), time.Minute)
+	cache.Set(ctx, "b", []byte("2"), time.Minute)
+	cache.Get(ctx, "a")
+	cache.Set(ctx, "c", []byte("3"), time.Minute)
+
+	if _, ok, _ := cache.Get(ctx, "b"); ok {
+		t.Error("the least recently used entry should be evicted")
+	}
+	if _, ok, _ := cache.Get(ctx, "a"); !ok {
+		t.Error("a recently read entry should be kept")
+	}
+	if cache.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", cache.Len())
+	}
+}
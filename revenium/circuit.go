@@ -0,0 +1,226 @@
+package revenium
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of the metering circuit breaker
+type CircuitState string
+
+const (
+	// CircuitClosed delivers events normally
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen drops events without contacting Revenium until the cooldown ends
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets one probe event through; its outcome closes or reopens the circuit
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitBreakerConfig controls the circuit breaker around Revenium metering
+// deliveries. Zero fields use the values from DefaultCircuitBreakerConfig.
+type CircuitBreakerConfig struct {
+	// Disabled turns the circuit breaker off, so every event is attempted
+	Disabled bool
+	// FailureThreshold is the number of consecutive failed events (after retries)
+	// that opens the circuit
+	FailureThreshold int
+	// Cooldown is how long the circuit stays open before a probe event is allowed
+	Cooldown time.Duration
+}
+
+// DefaultCircuitBreakerConfig returns the settings used when nothing is configured:
+// open after 5 consecutive failed events, probe again after 30s
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		FailureThreshold: 5,
+		Cooldown:         30 * time.Second,
+	}
+}
+
+// normalized returns the settings with defaults filled in for unset fields
+func (c CircuitBreakerConfig) normalized() CircuitBreakerConfig {
+	defaults := DefaultCircuitBreakerConfig()
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = defaults.FailureThreshold
+	}
+	if c.Cooldown <= 0 {
+		c.Cooldown = defaults.Cooldown
+	}
+	return c
+}
+
+// validationProblems describes every invalid field
+func (c CircuitBreakerConfig) validationProblems() []string {
+	var problems []string
+	if c.FailureThreshold < 0 {
+		problems = append(problems, "CircuitBreaker.FailureThreshold must not be negative")
+	}
+	if c.Cooldown < 0 {
+		problems = append(problems, "CircuitBreaker.Cooldown must not be negative")
+	}
+	return problems
+}
+
+// CircuitHealth is a snapshot of one circuit breaker
+type CircuitHealth struct {
+	State CircuitState
+	// ConsecutiveFailures counts failed events since the last success
+	ConsecutiveFailures int
+	// Dropped counts events discarded while the circuit was open
+	Dropped int64
+	// Since is when the circuit entered its current state (zero if it never changed)
+	Since time.Time
+}
+
+// MeteringHealth reports the state of the client's metering delivery
+type MeteringHealth struct {
+	// Primary is the circuit in front of the Revenium metering API
+	Primary CircuitHealth
+	// Shadow is the circuit in front of the shadow endpoint, when one is configured
+	Shadow *CircuitHealth
+}
+
+// Healthy reports whether the primary circuit is closed
+func (h MeteringHealth) Healthy() bool {
+	return h.Primary.State == CircuitClosed
+}
+
+// circuitBreaker tracks delivery failures for one metering endpoint
+type circuitBreaker struct {
+	name   string
+	config func() *Config
+	logger Logger
+	now    func() time.Time
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	since    time.Time
+	probing  bool
+	dropped  int64
+}
+
+// newCircuitBreaker creates a closed breaker. name identifies the endpoint in logs.
+func newCircuitBreaker(name string, config func() *Config, logger Logger) *circuitBreaker {
+	return &circuitBreaker{name: name, config: config, logger: logger, now: time.Now, state: CircuitClosed}
+}
+
+// settings returns the current breaker configuration
+func (b *circuitBreaker) settings() CircuitBreakerConfig {
+	if config := b.config(); config != nil {
+		return config.CircuitBreaker.normalized()
+	}
+	return DefaultCircuitBreakerConfig()
+}
+
+// allow reports whether an event may be delivered now. When the cooldown has passed
+// the circuit turns half-open and exactly one caller is allowed through as a probe.
+func (b *circuitBreaker) allow() bool {
+	settings := b.settings()
+	if settings.Disabled {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if b.now().Sub(b.since) < settings.Cooldown {
+			b.dropped++
+			return false
+		}
+		b.transition(CircuitHalfOpen)
+		b.probing = true
+		return true
+	case CircuitHalfOpen:
+		if b.probing {
+			b.dropped++
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// record updates the breaker with the outcome of an allowed delivery. Rejections by
+// the API and configuration errors show the endpoint is reachable, so only metering
+// and network errors count as failures.
+func (b *circuitBreaker) record(err error) {
+	failed := err != nil && !IsValidationError(err) && !IsConfigError(err)
+	settings := b.settings()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		if b.state != CircuitClosed {
+			b.transition(CircuitClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if settings.Disabled {
+		return
+	}
+	if b.state == CircuitHalfOpen || (b.state == CircuitClosed && b.failures >= settings.FailureThreshold) {
+		b.transition(CircuitOpen)
+	}
+}
+
+// transition changes state and logs it. Must be called with b.mu held.
+func (b *circuitBreaker) transition(state CircuitState) {
+	previous := b.state
+	b.state = state
+	b.since = b.now()
+
+	switch state {
+	case CircuitOpen:
+		b.logger.Warn("[METERING] %s circuit %s -> open after %d consecutive failures; events are dropped for %v",
+			b.name, previous, b.failures, b.settings().Cooldown)
+	case CircuitHalfOpen:
+		b.logger.Info("[METERING] %s circuit open -> half-open; probing with the next event (%d events dropped so far)", b.name, b.dropped)
+	case CircuitClosed:
+		b.logger.Info("[METERING] %s circuit %s -> closed; delivery resumed", b.name, previous)
+	}
+}
+
+// health returns a snapshot of the breaker
+func (b *circuitBreaker) health() CircuitHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return CircuitHealth{State: b.state, ConsecutiveFailures: b.failures, Dropped: b.dropped, Since: b.since}
+}
+
+// breakerSink guards a sink with a circuit breaker
+type breakerSink struct {
+	next    MeteringSink
+	breaker *circuitBreaker
+}
+
+// Send delivers the event unless the circuit is open, in which case the event is
+// dropped and counted without contacting the endpoint
+func (s *breakerSink) Send(ctx context.Context, event MeteringEvent) error {
+	if !s.breaker.allow() {
+		payloadLogger(s.breaker.logger, event.Payload).Debug("[METERING] %s circuit open, %s event dropped", s.breaker.name, event.Type)
+		return NewMeteringError(fmt.Sprintf("%s circuit open, %s event dropped", s.breaker.name, event.Type), nil).
+			WithDetails("circuitState", CircuitOpen)
+	}
+
+	err := s.next.Send(ctx, event)
+	s.breaker.record(err)
+	return err
+}
+
+// MeteringHealth reports the circuit breaker state of the client's metering delivery.
+// Clients using a custom MeteringSink or dry-run mode always report a closed circuit.
+func (r *ReveniumGoogle) MeteringHealth() MeteringHealth {
+	return r.metering.health()
+}
@@ -0,0 +1,109 @@
+package revenium
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker_Transitions(t *testing.T) {
+	var status atomic.Int32
+	var requests atomic.Int32
+	status.Store(http.StatusBadGateway)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	client, err := NewReveniumGoogle(&Config{
+		ReveniumAPIKey:  "hak_circuit",
+		ReveniumBaseURL: server.URL,
+		GoogleAPIKey:    "google-key",
+		RetryPolicy:     RetryPolicy{MaxAttempts: 1},
+		CircuitBreaker:  CircuitBreakerConfig{FailureThreshold: 2, Cooldown: time.Minute},
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	now := time.Now()
+	client.metering.primaryBreaker.now = func() time.Time { return now }
+
+	send := func() error {
+		event := testEvent(MeteringEventCompletion)
+		return client.metering.send(context.Background(), event.Type, event.Payload)
+	}
+
+	steps := []struct {
+		name         string
+		advance      time.Duration
+		status       int
+		wantRequests int32
+		wantState    CircuitState
+	}{
+		{"first failure keeps the circuit closed", 0, http.StatusBadGateway, 1, CircuitClosed},
+		{"threshold opens the circuit", 0, http.StatusBadGateway, 2, CircuitOpen},
+		{"open circuit drops without connecting", time.Second, http.StatusOK, 2, CircuitOpen},
+		{"failed probe reopens", time.Minute, http.StatusBadGateway, 3, CircuitOpen},
+		{"successful probe closes", time.Minute, http.StatusOK, 4, CircuitClosed},
+		{"closed circuit delivers", 0, http.StatusOK, 5, CircuitClosed},
+	}
+
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		status.Store(int32(step.status))
+		send()
+
+		if got := requests.Load(); got != step.wantRequests {
+			t.Errorf("%s: expected %d requests, got %d", step.name, step.wantRequests, got)
+		}
+		if health := client.MeteringHealth(); health.Primary.State != step.wantState {
+			t.Errorf("%s: expected state %s, got %s", step.name, step.wantState, health.Primary.State)
+		}
+	}
+
+	health := client.MeteringHealth()
+	if health.Primary.Dropped != 1 || !health.Healthy() || health.Shadow != nil {
+		t.Errorf("unexpected health: %+v", health)
+	}
+}
+
+func TestCircuitBreaker_HalfOpenAllowsOneProbe(t *testing.T) {
+	cfg := &Config{CircuitBreaker: CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Second}}
+	breaker := newCircuitBreaker("Revenium", func() *Config { return cfg }, NewDefaultLogger())
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+
+	breaker.allow()
+	breaker.record(NewNetworkError("connection refused", nil))
+	now = now.Add(2 * time.Second)
+
+	if !breaker.allow() {
+		t.Fatal("expected the first call after the cooldown to probe")
+	}
+	if breaker.allow() {
+		t.Error("expected other calls to be dropped while the probe is in flight")
+	}
+	breaker.record(nil)
+	if !breaker.allow() || breaker.health().State != CircuitClosed {
+		t.Errorf("expected the circuit to close after a successful probe, got %+v", breaker.health())
+	}
+}
+
+func TestCircuitBreaker_Disabled(t *testing.T) {
+	cfg := &Config{CircuitBreaker: CircuitBreakerConfig{Disabled: true, FailureThreshold: 1}}
+	breaker := newCircuitBreaker("Revenium", func() *Config { return cfg }, NewDefaultLogger())
+
+	for i := 0; i < 3; i++ {
+		if !breaker.allow() {
+			t.Fatal("a disabled breaker must allow every event")
+		}
+		breaker.record(NewMeteringError("server error", nil))
+	}
+	if health := breaker.health(); health.State != CircuitClosed || health.ConsecutiveFailures != 3 {
+		t.Errorf("unexpected health: %+v", health)
+	}
+}
@@ -0,0 +1,146 @@
+package revenium
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// Metering attributes of coalesced calls
+const (
+	coalescedAttribute              = "coalesced"
+	coalescedTransactionIDAttribute = "coalescedTransactionId"
+)
+
+// WithRequestCoalescing makes identical concurrent GenerateContent calls share one
+// Google call. The caller that starts the call is metered as usual; every other
+// caller is metered as a coalesced event with zero provider tokens.
+func WithRequestCoalescing() Option {
+	return func(c *Config) {
+		c.CoalesceRequests = true
+	}
+}
+
+// inflightCall is a GenerateContent call that identical requests can join
+type inflightCall struct {
+	done          chan struct{}
+	resp          *genai.GenerateContentResponse
+	transactionID string
+	err           error
+}
+
+// callGroup tracks in-flight calls by request key
+type callGroup struct {
+	mu    sync.Mutex
+	calls map[string]*inflightCall
+}
+
+// join returns the in-flight call for key and false, or registers a new call and
+// returns it with true when the caller must make it
+func (g *callGroup) join(key string) (*inflightCall, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if call, ok := g.calls[key]; ok {
+		return call, false
+	}
+	if g.calls == nil {
+		g.calls = map[string]*inflightCall{}
+	}
+	call := &inflightCall{done: make(chan struct{})}
+	g.calls[key] = call
+	return call, true
+}
+
+// complete records the result of the call for key and releases its waiters
+func (g *callGroup) complete(key string, call *inflightCall, resp *genai.GenerateContentResponse, transactionID string, err error) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	call.resp, call.transactionID, call.err = resp, transactionID, err
+	close(call.done)
+}
+
+// coalesce runs generate once per key at a time. The shared call runs on a context
+// that keeps ctx's values and deadline but not its cancellation, so a caller that gives
+// up does not fail the call for the others while the call stays bounded. Every caller gets its own deep copy of
+// the response. Callers that join an in-flight call get a copy stamped with their own
+// transaction ID and are metered as coalesced. A caller whose ctx ends first returns
+// ctx's error; a caller that joined is then not metered.
+func (m *ModelsInterface) coalesce(
+	ctx context.Context,
+	key string,
+	model string,
+	config *genai.GenerateContentConfig,
+	generate func(ctx context.Context) (*genai.GenerateContentResponse, string, error),
+) (*genai.GenerateContentResponse, string, error) {
+	call, leader := m.parent.inflight.join(key)
+	if leader {
+		shared := context.WithoutCancel(ctx)
+		cancel := func() {}
+		if deadline, ok := ctx.Deadline(); ok {
+			shared, cancel = context.WithDeadline(shared, deadline)
+		}
+		go func() {
+			defer cancel()
+			// A panicking call must still release its waiters
+			var resp *genai.GenerateContentResponse
+			var transactionID string
+			err := error(NewInternalError("coalesced GenerateContent call did not complete", nil))
+			defer func() {
+				if r := recover(); r != nil {
+					m.logger.Error("Coalesced GenerateContent call panic: %v", r)
+				}
+				m.parent.inflight.complete(key, call, resp, transactionID, err)
+			}()
+			resp, transactionID, err = generate(shared)
+		}()
+	}
+
+	requestTime := time.Now()
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return nil, "", ctx.Err()
+	}
+	if leader {
+		return m.copyResponse(call.resp), call.transactionID, call.err
+	}
+
+	metadata, transactionID := withTransactionID(GetUsageMetadata(ctx))
+	resp := m.copyResponse(call.resp)
+	if resp != nil {
+		resp.SDKHTTPResponse = stampTransactionID(resp.SDKHTTPResponse, transactionID)
+	}
+	m.logger.Debug("GenerateContent joined in-flight call %s", call.transactionID)
+	m.meterWithoutUsage(ctx, resp, call.err, model, metadata, requestTime, config, map[string]interface{}{
+		coalescedAttribute:              true,
+		coalescedTransactionIDAttribute: call.transactionID,
+	})
+	return resp, transactionID, call.err
+}
+
+// copyResponse returns a deep copy of a shared response, so callers can change theirs
+// without affecting the others
+func (m *ModelsInterface) copyResponse(resp *genai.GenerateContentResponse) *genai.GenerateContentResponse {
+	if resp == nil {
+		return nil
+	}
+	var copied genai.GenerateContentResponse
+	data, err := json.Marshal(resp)
+	if err == nil {
+		err = json.Unmarshal(data, &copied)
+	}
+	if err != nil {
+		m.logger.Warn("Failed to copy coalesced response: %v", err)
+		copied = *resp
+	}
+	if resp.SDKHTTPResponse != nil {
+		copied.SDKHTTPResponse = &genai.HTTPResponse{Headers: resp.SDKHTTPResponse.Headers.Clone(), Body: resp.SDKHTTPResponse.Body}
+	}
+	return &copied
+}
@@ -0,0 +1,84 @@
+package revenium
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+func TestCallGroup(t *testing.T) {
+	var group callGroup
+
+	call, leader := group.join("a")
+	if !leader {
+		t.Fatal("the first caller should make the call")
+	}
+	joined, leader := group.join("a")
+	if leader || joined != call {
+		t.Fatal("an identical request should join the in-flight call")
+	}
+	if _, leader := group.join("b"); !leader {
+		t.Error("a different request should make its own call")
+	}
+
+	resp := &genai.GenerateContentResponse{ModelVersion: "gemini-2.0-flash"}
+	group.complete("a", call, resp, "txn-1", nil)
+	<-joined.done
+	if joined.resp != resp || joined.transactionID != "txn-1" {
+		t.Errorf("waiters should see the result, got %+v", joined)
+	}
+	if _, leader := group.join("a"); !leader {
+		t.Error("a completed call should not be joined")
+	}
+}
+
+func TestCopyResponse_IsDeep(t *testing.T) {
+	m := &ModelsInterface{logger: NewDefaultLogger()}
+	resp := &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{Content: genai.NewContentFromText("shared", genai.RoleModel)}}}
+	resp.SDKHTTPResponse = stampTransactionID(nil, "txn-1")
+
+	copied := m.copyResponse(resp)
+	copied.Candidates[0].Content.Parts[0].Text = "changed"
+	copied.SDKHTTPResponse = stampTransactionID(copied.SDKHTTPResponse, "txn-2")
+
+	if resp.Text() != "shared" || ResponseTransactionID(resp) != "txn-1" {
+		t.Errorf("changing a copy must not change the shared response, got %q and %q", resp.Text(), ResponseTransactionID(resp))
+	}
+}
+
+func TestCoalesce_LeaderKeepsDeadline(t *testing.T) {
+	m := &ModelsInterface{logger: NewDefaultLogger(), parent: &ReveniumGoogle{}}
+
+	tests := []struct {
+		name         string
+		deadline     bool
+		wantDeadline bool
+	}{
+		{"parent deadline is kept", true, true},
+		{"no parent deadline", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.deadline {
+				ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+			}
+			defer cancel()
+
+			var gotDeadline bool
+			_, _, err := m.coalesce(ctx, tt.name, "gemini-2.0-flash", nil, func(shared context.Context) (*genai.GenerateContentResponse, string, error) {
+				_, gotDeadline = shared.Deadline()
+				return nil, "txn-1", nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if gotDeadline != tt.wantDeadline {
+				t.Errorf("shared call deadline set = %v, want %v", gotDeadline, tt.wantDeadline)
+			}
+		})
+	}
+}
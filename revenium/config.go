@@ -1,10 +1,17 @@
 package revenium
 
 import (
+	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"google.golang.org/genai"
 )
 
 // Config holds all configuration for the Revenium middleware
@@ -14,14 +21,139 @@ type Config struct {
 	ProjectID string
 	Location  string
 
+	// GoogleBaseURL overrides the Gemini API or Vertex AI endpoint, for example to go
+	// through a proxy or to use the fake server in the reveniumtest package
+	GoogleBaseURL string
+	// GoogleHTTPClient is used for calls to Gemini API or Vertex AI. With Vertex AI the
+	// client is responsible for authentication; Application Default Credentials are
+	// only looked up when it is nil.
+	GoogleHTTPClient *http.Client
+	// GenaiClientConfig is passed to genai.NewClient, for example to set HTTPOptions,
+	// an HTTPClient or explicit Credentials. Its empty fields are filled from the
+	// settings above.
+	GenaiClientConfig *genai.ClientConfig
+	// GenaiClient, when set, is wrapped instead of creating a client; the Google
+	// settings above are then ignored
+	GenaiClient *genai.Client
+	// TransportMetering installs a metering http.RoundTripper into the genai client so
+	// calls made outside the wrapper methods are metered too
+	TransportMetering bool
+
 	ReveniumAPIKey  string
 	ReveniumBaseURL string
 
 	VertexDisabled bool
 	Debug          bool
 
+	// LogLevel is the minimum log level: "debug", "info", "warn" or "error" (defaults to info).
+	// A custom *slog.Logger is filtered by its own handler instead, and neither Debug
+	// nor LogLevel affects it.
+	LogLevel string
+	// LogFormat selects the default logger output: "text" or "json" (defaults to text)
+	LogFormat string
+	// RedactPayloads keeps metering payload bodies (which may contain captured prompts) out of logs
+	RedactPayloads bool
+	// Logger is the client's logger (a *slog.Logger is accepted). When nil, each client
+	// created with NewReveniumGoogle gets its own console logger; Initialize uses the
+	// package-level logger set with SetLogger.
+	Logger Logger
+
 	// Prompt capture configuration (opt-in)
 	CapturePrompts bool
+
+	// MaxPromptLength caps the captured system prompt and output response, in bytes
+	// (defaults to 50,000)
+	MaxPromptLength int
+	// MaxInputMessagesLength is the total byte budget shared by all captured input
+	// messages (defaults to 50,000)
+	MaxInputMessagesLength int
+	// PromptTruncationStrategy selects which part of over-long content is kept
+	// (defaults to TruncateHead)
+	PromptTruncationStrategy TruncationStrategy
+
+	// MeteringSink receives metering events instead of the Revenium metering API.
+	// When set, ReveniumAPIKey is optional.
+	MeteringSink MeteringSink
+
+	// DryRun builds and validates metering events and logs them instead of sending
+	// them anywhere. ReveniumAPIKey is optional in dry-run mode.
+	DryRun bool
+	// DryRunFile, when set, also appends dry-run events to this JSON lines file
+	DryRunFile string
+
+	// ShadowBaseURL, when set, posts every metering event to a second Revenium
+	// endpoint (for example staging) next to ReveniumBaseURL. Failures of the shadow
+	// endpoint are logged and never affect the primary delivery.
+	ShadowBaseURL string
+	// ShadowAPIKey is the API key for ShadowBaseURL (defaults to ReveniumAPIKey)
+	ShadowAPIKey string
+
+	// RetryPolicy controls retries of metering deliveries to Revenium (zero fields use
+	// DefaultRetryPolicy)
+	RetryPolicy RetryPolicy
+
+	// GenerationRetry controls retries and model fallbacks of Google generation calls
+	// made through ModelsInterface (off by default)
+	GenerationRetry GenerationRetryPolicy
+
+	// ResponseCache, when set, answers repeated GenerateContent calls from the cache;
+	// hits are metered with zero provider tokens
+	ResponseCache ResponseCache
+	// ResponseCacheTTL is how long responses are cached (defaults to one hour)
+	ResponseCacheTTL time.Duration
+	// ResponseCacheNamespace separates this client's entries from those of other
+	// clients sharing the same ResponseCache
+	ResponseCacheNamespace string
+
+	// CoalesceRequests makes identical concurrent GenerateContent calls share one
+	// Google call; the callers that join it are metered as coalesced events
+	CoalesceRequests bool
+
+	// RateLimits throttles calls made through ModelsInterface and ImagesInterface by
+	// requests and tokens per minute (off by default)
+	RateLimits RateLimitConfig
+
+	// StreamStallThreshold is the gap between stream chunks counted as a stall in
+	// streaming latency statistics (defaults to 5s)
+	StreamStallThreshold time.Duration
+
+	// CircuitBreaker controls when metering to Revenium is suspended after repeated
+	// failures (zero fields use DefaultCircuitBreakerConfig)
+	CircuitBreaker CircuitBreakerConfig
+
+	// MeteringHTTPClient, when set, is used as is for every metering request
+	MeteringHTTPClient *http.Client
+	// MeteringRoundTripper, when set, carries every metering request (for example
+	// an instrumented or corporate transport)
+	MeteringRoundTripper http.RoundTripper
+	// MeteringProxyURL routes metering requests through an HTTP(S) proxy (by default
+	// the HTTPS_PROXY and NO_PROXY environment variables apply)
+	MeteringProxyURL string
+	// MeteringCAFile is a PEM bundle trusted in addition to the system roots, for
+	// example a corporate proxy's private CA
+	MeteringCAFile string
+	// MeteringClientCertFile and MeteringClientKeyFile are the PEM client certificate
+	// and key presented for mTLS
+	MeteringClientCertFile string
+	MeteringClientKeyFile  string
+	// MeteringConnectTimeout bounds connection setup and the TLS handshake (the whole
+	// attempt is bounded by RetryPolicy.AttemptTimeout)
+	MeteringConnectTimeout time.Duration
+	// MeteringGzip compresses metering request bodies of 1 KB or more, such as
+	// payloads with captured prompts
+	MeteringGzip bool
+}
+
+// PromptLimits returns the prompt capture truncation limits with defaults applied
+func (c *Config) PromptLimits() PromptLimits {
+	if c == nil {
+		return DefaultPromptLimits()
+	}
+	return PromptLimits{
+		MaxFieldLength:         c.MaxPromptLength,
+		MaxInputMessagesLength: c.MaxInputMessagesLength,
+		Strategy:               c.PromptTruncationStrategy,
+	}.normalized()
 }
 
 // Option is a functional option for configuring Config
@@ -48,6 +180,20 @@ func WithLocation(location string) Option {
 	}
 }
 
+// WithGoogleBaseURL overrides the Gemini API or Vertex AI endpoint
+func WithGoogleBaseURL(url string) Option {
+	return func(c *Config) {
+		c.GoogleBaseURL = url
+	}
+}
+
+// WithGoogleHTTPClient sets the HTTP client used for calls to Gemini API or Vertex AI
+func WithGoogleHTTPClient(client *http.Client) Option {
+	return func(c *Config) {
+		c.GoogleHTTPClient = client
+	}
+}
+
 // WithReveniumAPIKey sets the Revenium API key
 func WithReveniumAPIKey(key string) Option {
 	return func(c *Config) {
@@ -69,48 +215,297 @@ func WithDebug(debug bool) Option {
 	}
 }
 
+// WithLogLevel sets the minimum log level ("debug", "info", "warn" or "error").
+// It does not filter a custom *slog.Logger, whose handler decides the level.
+func WithLogLevel(level string) Option {
+	return func(c *Config) {
+		c.LogLevel = level
+	}
+}
+
+// WithLogFormat sets the default logger output format ("text" or "json")
+func WithLogFormat(format string) Option {
+	return func(c *Config) {
+		c.LogFormat = format
+	}
+}
+
+// WithLogger sets the client's logger. A *slog.Logger (or one wrapped with
+// NewSlogLogger) is filtered by its own handler only: Debug and LogLevel, including
+// changes made by UpdateConfig and Reload, do not apply to it. Use a slog.LevelVar
+// in the handler options to change its level at runtime. Other loggers are filtered
+// by Debug and LogLevel.
+func WithLogger(logger Logger) Option {
+	return func(c *Config) {
+		c.Logger = logger
+	}
+}
+
+// WithMeteringSink sends metering events to sink instead of the Revenium metering API.
+// Use NewFanOutSink with NewReveniumSink to keep sending to Revenium as well.
+func WithMeteringSink(sink MeteringSink) Option {
+	return func(c *Config) {
+		c.MeteringSink = sink
+	}
+}
+
+// WithDryRun builds and validates metering events without sending them. Events are
+// logged and, when file is not empty, appended to that JSON lines file.
+func WithDryRun(file string) Option {
+	return func(c *Config) {
+		c.DryRun = true
+		c.DryRunFile = file
+	}
+}
+
+// WithShadowMetering also posts every metering event to baseURL, using apiKey or,
+// when empty, the primary Revenium API key
+func WithShadowMetering(baseURL, apiKey string) Option {
+	return func(c *Config) {
+		c.ShadowBaseURL = baseURL
+		c.ShadowAPIKey = apiKey
+	}
+}
+
+// WithRedactPayloads keeps metering payload bodies out of debug logs
+func WithRedactPayloads(redact bool) Option {
+	return func(c *Config) {
+		c.RedactPayloads = redact
+	}
+}
+
 // WithCapturePrompts enables or disables prompt capture for analytics
 // When enabled, system prompts, input messages, and output responses are captured
-// and sent to Revenium for analytics (with truncation at 50,000 characters)
+// and sent to Revenium for analytics (truncated according to the prompt limits)
 func WithCapturePrompts(capture bool) Option {
 	return func(c *Config) {
 		c.CapturePrompts = capture
 	}
 }
 
+// WithMaxPromptLength sets the maximum captured length of the system prompt and
+// output response, in bytes
+func WithMaxPromptLength(maxBytes int) Option {
+	return func(c *Config) {
+		c.MaxPromptLength = maxBytes
+	}
+}
+
+// WithMaxInputMessagesLength sets the total byte budget shared by all captured input messages
+func WithMaxInputMessagesLength(maxBytes int) Option {
+	return func(c *Config) {
+		c.MaxInputMessagesLength = maxBytes
+	}
+}
+
+// WithPromptTruncationStrategy sets which part of over-long captured content is kept
+func WithPromptTruncationStrategy(strategy TruncationStrategy) Option {
+	return func(c *Config) {
+		c.PromptTruncationStrategy = strategy
+	}
+}
+
+// WithRetryPolicy sets how metering deliveries to Revenium are retried
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Config) {
+		c.RetryPolicy = policy
+	}
+}
+
+// WithCircuitBreaker sets when metering to Revenium is suspended after repeated failures
+func WithCircuitBreaker(breaker CircuitBreakerConfig) Option {
+	return func(c *Config) {
+		c.CircuitBreaker = breaker
+	}
+}
+
+// WithMeteringHTTPClient sets the HTTP client used for every metering request
+func WithMeteringHTTPClient(client *http.Client) Option {
+	return func(c *Config) {
+		c.MeteringHTTPClient = client
+	}
+}
+
+// WithMeteringRoundTripper sets the transport used for every metering request
+func WithMeteringRoundTripper(transport http.RoundTripper) Option {
+	return func(c *Config) {
+		c.MeteringRoundTripper = transport
+	}
+}
+
+// WithMeteringProxy routes metering requests through the proxy at proxyURL
+func WithMeteringProxy(proxyURL string) Option {
+	return func(c *Config) {
+		c.MeteringProxyURL = proxyURL
+	}
+}
+
+// WithMeteringTLS trusts the PEM CA bundle at caFile in addition to the system roots
+// and, when certFile and keyFile are set, presents that client certificate for mTLS.
+// Empty arguments leave the corresponding setting unchanged.
+func WithMeteringTLS(caFile, certFile, keyFile string) Option {
+	return func(c *Config) {
+		if caFile != "" {
+			c.MeteringCAFile = caFile
+		}
+		if certFile != "" {
+			c.MeteringClientCertFile = certFile
+		}
+		if keyFile != "" {
+			c.MeteringClientKeyFile = keyFile
+		}
+	}
+}
+
+// WithMeteringGzip compresses large metering request bodies
+func WithMeteringGzip() Option {
+	return func(c *Config) {
+		c.MeteringGzip = true
+	}
+}
+
+// newConfig builds a configuration in a fixed order: defaults, then the file named by
+// REVENIUM_CONFIG_FILE (if any), then environment variables (and .env files), then the
+// explicit options. The base URL is normalized.
+// Malformed environment values are reported in the returned error; the config is
+// still usable so callers can decide whether to fail.
+func newConfig(opts ...Option) (*Config, error) {
+	cfg := defaultConfig()
+
+	var fileErr error
+	if path := os.Getenv("REVENIUM_CONFIG_FILE"); path != "" {
+		fileErr = cfg.applyConfigFile(path, os.Getenv("REVENIUM_PROFILE"))
+	}
+	envErr := joinConfigErrors(fileErr, cfg.loadFromEnv())
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	cfg.ReveniumBaseURL = NormalizeReveniumBaseURL(cfg.ReveniumBaseURL)
+	return cfg, envErr
+}
+
+// defaultConfig returns a configuration with every default applied
+func defaultConfig() *Config {
+	return &Config{
+		ReveniumBaseURL: defaultReveniumBaseURL,
+	}
+}
+
 // loadFromEnv loads configuration from environment variables and .env files
+// Only variables that are set override the current values.
 func (c *Config) loadFromEnv() error {
 	// First, try to load .env files automatically
 	c.loadEnvFiles()
 
-	// Then load from environment variables (which may have been set by .env files)
-	c.GoogleAPIKey = os.Getenv("GOOGLE_API_KEY")
-	c.ProjectID = os.Getenv("GOOGLE_CLOUD_PROJECT")
-	c.Location = os.Getenv("GOOGLE_CLOUD_LOCATION")
+	newClientLogger(c).Debug("Loading configuration from environment variables")
 
-	c.ReveniumAPIKey = os.Getenv("REVENIUM_METERING_API_KEY")
-	baseURL := os.Getenv("REVENIUM_METERING_BASE_URL")
-	if baseURL == "" {
-		baseURL = defaultReveniumBaseURL
-	}
-	c.ReveniumBaseURL = NormalizeReveniumBaseURL(baseURL)
+	var problems []string
 
-	if os.Getenv("REVENIUM_VERTEX_DISABLE") == "1" || os.Getenv("REVENIUM_VERTEX_DISABLE") == "true" {
-		c.VertexDisabled = true
+	// Then load from environment variables (which may have been set by .env files)
+	setStringFromEnv(&c.GoogleAPIKey, "GOOGLE_API_KEY")
+	setStringFromEnv(&c.ProjectID, "GOOGLE_CLOUD_PROJECT")
+	setStringFromEnv(&c.Location, "GOOGLE_CLOUD_LOCATION")
+
+	setStringFromEnv(&c.ReveniumAPIKey, "REVENIUM_METERING_API_KEY")
+	setStringFromEnv(&c.ReveniumBaseURL, "REVENIUM_METERING_BASE_URL")
+
+	setBoolFromEnv(&c.VertexDisabled, "REVENIUM_VERTEX_DISABLE")
+	setBoolFromEnv(&c.Debug, "REVENIUM_DEBUG")
+	setStringFromEnv(&c.LogLevel, "REVENIUM_LOG_LEVEL")
+	setStringFromEnv(&c.LogFormat, "REVENIUM_LOG_FORMAT")
+	setBoolFromEnv(&c.RedactPayloads, "REVENIUM_REDACT_PAYLOADS")
+	setBoolFromEnv(&c.CapturePrompts, "REVENIUM_CAPTURE_PROMPTS")
+	setBoolFromEnv(&c.DryRun, "REVENIUM_DRY_RUN")
+	setStringFromEnv(&c.DryRunFile, "REVENIUM_DRY_RUN_FILE")
+	setStringFromEnv(&c.ShadowBaseURL, "REVENIUM_SHADOW_BASE_URL")
+	setStringFromEnv(&c.ShadowAPIKey, "REVENIUM_SHADOW_API_KEY")
+	setBoolFromEnv(&c.TransportMetering, "REVENIUM_TRANSPORT_METERING")
+	setStringFromEnv(&c.ResponseCacheNamespace, "REVENIUM_RESPONSE_CACHE_NAMESPACE")
+	setBoolFromEnv(&c.CoalesceRequests, "REVENIUM_COALESCE_REQUESTS")
+
+	problems = appendIntFromEnv(problems, &c.MaxPromptLength, "REVENIUM_MAX_PROMPT_LENGTH")
+	problems = appendIntFromEnv(problems, &c.MaxInputMessagesLength, "REVENIUM_MAX_INPUT_MESSAGES_LENGTH")
+	problems = appendIntFromEnv(problems, &c.RetryPolicy.MaxAttempts, "REVENIUM_RETRY_MAX_ATTEMPTS")
+	problems = appendDurationFromEnv(problems, &c.RetryPolicy.BaseDelay, "REVENIUM_RETRY_BASE_DELAY")
+	problems = appendDurationFromEnv(problems, &c.RetryPolicy.MaxDelay, "REVENIUM_RETRY_MAX_DELAY")
+	problems = appendDurationFromEnv(problems, &c.RetryPolicy.AttemptTimeout, "REVENIUM_RETRY_ATTEMPT_TIMEOUT")
+	problems = appendDurationFromEnv(problems, &c.RetryPolicy.Deadline, "REVENIUM_RETRY_DEADLINE")
+	problems = appendIntFromEnv(problems, &c.GenerationRetry.MaxAttempts, "REVENIUM_GENERATION_RETRY_MAX_ATTEMPTS")
+	problems = appendDurationFromEnv(problems, &c.GenerationRetry.BaseDelay, "REVENIUM_GENERATION_RETRY_BASE_DELAY")
+	problems = appendDurationFromEnv(problems, &c.GenerationRetry.MaxDelay, "REVENIUM_GENERATION_RETRY_MAX_DELAY")
+	if value := os.Getenv("REVENIUM_FALLBACK_MODELS"); value != "" {
+		c.GenerationRetry.Fallbacks = parseFallbackModels(value)
+	}
+	problems = appendIntFromEnv(problems, &c.RateLimits.Default.RequestsPerMinute, "REVENIUM_RATE_LIMIT_RPM")
+	problems = appendIntFromEnv(problems, &c.RateLimits.Default.TokensPerMinute, "REVENIUM_RATE_LIMIT_TPM")
+	setBoolFromEnv(&c.RateLimits.FailFast, "REVENIUM_RATE_LIMIT_FAIL_FAST")
+	problems = appendDurationFromEnv(problems, &c.StreamStallThreshold, "REVENIUM_STREAM_STALL_THRESHOLD")
+	setBoolFromEnv(&c.CircuitBreaker.Disabled, "REVENIUM_CIRCUIT_BREAKER_DISABLED")
+	problems = appendIntFromEnv(problems, &c.CircuitBreaker.FailureThreshold, "REVENIUM_CIRCUIT_BREAKER_THRESHOLD")
+	problems = appendDurationFromEnv(problems, &c.CircuitBreaker.Cooldown, "REVENIUM_CIRCUIT_BREAKER_COOLDOWN")
+	setStringFromEnv(&c.MeteringProxyURL, "REVENIUM_METERING_PROXY_URL")
+	setStringFromEnv(&c.MeteringCAFile, "REVENIUM_METERING_CA_FILE")
+	setStringFromEnv(&c.MeteringClientCertFile, "REVENIUM_METERING_CLIENT_CERT_FILE")
+	setStringFromEnv(&c.MeteringClientKeyFile, "REVENIUM_METERING_CLIENT_KEY_FILE")
+	problems = appendDurationFromEnv(problems, &c.MeteringConnectTimeout, "REVENIUM_METERING_CONNECT_TIMEOUT")
+	setBoolFromEnv(&c.MeteringGzip, "REVENIUM_METERING_GZIP")
+	if value := os.Getenv("REVENIUM_PROMPT_TRUNCATION_STRATEGY"); value != "" {
+		if strategy, ok := ParseTruncationStrategy(value); ok {
+			c.PromptTruncationStrategy = strategy
+		} else {
+			problems = append(problems, fmt.Sprintf("REVENIUM_PROMPT_TRUNCATION_STRATEGY has unknown value %q", value))
+		}
 	}
 
-	c.Debug = os.Getenv("REVENIUM_DEBUG") == "true" || os.Getenv("REVENIUM_DEBUG") == "1"
-	c.CapturePrompts = os.Getenv("REVENIUM_CAPTURE_PROMPTS") == "true" || os.Getenv("REVENIUM_CAPTURE_PROMPTS") == "1"
+	if len(problems) > 0 {
+		return newConfigValidationError("invalid environment configuration", problems)
+	}
+	return nil
+}
 
-	// Initialize logger early so we can use it
-	InitializeLogger()
+// setStringFromEnv sets *dst when the environment variable is non-empty
+func setStringFromEnv(dst *string, name string) {
+	if value := os.Getenv(name); value != "" {
+		*dst = value
+	}
+}
 
-	// Set global debug flag for logger
-	SetGlobalDebug(c.Debug)
+// setBoolFromEnv sets *dst when the environment variable is non-empty ("true" and "1" mean true)
+func setBoolFromEnv(dst *bool, name string) {
+	if value := os.Getenv(name); value != "" {
+		*dst = value == "true" || value == "1"
+	}
+}
 
-	Debug("Loading configuration from environment variables")
+// appendIntFromEnv sets *dst from a non-empty environment variable, recording a problem if it is not an integer
+func appendIntFromEnv(problems []string, dst *int, name string) []string {
+	value := os.Getenv(name)
+	if value == "" {
+		return problems
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return append(problems, fmt.Sprintf("%s must be an integer, got %q", name, value))
+	}
+	*dst = parsed
+	return problems
+}
 
-	return nil
+// appendDurationFromEnv sets *dst from a non-empty environment variable such as "250ms",
+// recording a problem if it is not a duration
+func appendDurationFromEnv(problems []string, dst *time.Duration, name string) []string {
+	value := os.Getenv(name)
+	if value == "" {
+		return problems
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return append(problems, fmt.Sprintf("%s must be a duration such as \"500ms\", got %q", name, value))
+	}
+	*dst = parsed
+	return problems
 }
 
 // loadEnvFiles loads environment variables from .env files
@@ -148,17 +543,109 @@ func (c *Config) loadEnvFiles() {
 }
 
 // Validate validates the configuration
+// Every problem is reported, not only the first: the returned *ReveniumError lists
+// them in its message and in Details["problems"].
 func (c *Config) Validate() error {
+	problems := c.validationProblems()
+	if len(problems) > 0 {
+		return newConfigValidationError("invalid configuration", problems)
+	}
+
+	newClientLogger(c).Debug("Configuration validation passed")
+	return nil
+}
+
+// validationProblems checks every field and returns a description of each problem
+func (c *Config) validationProblems() []string {
+	var problems []string
+
 	if c.ReveniumAPIKey == "" {
-		return NewConfigError("REVENIUM_METERING_API_KEY is required", nil)
+		if c.MeteringSink == nil && !c.DryRun {
+			problems = append(problems, "REVENIUM_METERING_API_KEY is required")
+		}
+	} else if !isValidAPIKeyFormat(c.ReveniumAPIKey) {
+		problems = append(problems, "invalid Revenium API key format (expected prefix \"hak_\")")
 	}
 
-	if !isValidAPIKeyFormat(c.ReveniumAPIKey) {
-		return NewConfigError("invalid Revenium API key format", nil)
+	if c.ReveniumBaseURL != "" {
+		if problem := validateBaseURL(c.ReveniumBaseURL); problem != "" {
+			problems = append(problems, "REVENIUM_METERING_BASE_URL "+problem)
+		}
 	}
 
-	Debug("Configuration validation passed")
-	return nil
+	if c.ShadowBaseURL != "" {
+		if problem := validateBaseURL(c.ShadowBaseURL); problem != "" {
+			problems = append(problems, "REVENIUM_SHADOW_BASE_URL "+problem)
+		}
+	}
+	if c.ShadowAPIKey != "" && !isValidAPIKeyFormat(c.ShadowAPIKey) {
+		problems = append(problems, "invalid shadow Revenium API key format (expected prefix \"hak_\")")
+	}
+
+	if c.GoogleBaseURL != "" {
+		if problem := validateBaseURL(c.GoogleBaseURL); problem != "" {
+			problems = append(problems, "GoogleBaseURL "+problem)
+		}
+	}
+
+	problems = append(problems, c.googleProblems()...)
+
+	if c.MaxPromptLength < 0 {
+		problems = append(problems, "MaxPromptLength must not be negative")
+	}
+	if c.MaxInputMessagesLength < 0 {
+		problems = append(problems, "MaxInputMessagesLength must not be negative")
+	}
+	if c.PromptTruncationStrategy != "" && !c.PromptTruncationStrategy.IsValid() {
+		problems = append(problems, fmt.Sprintf("unknown prompt truncation strategy %q", c.PromptTruncationStrategy))
+	}
+	problems = append(problems, c.RetryPolicy.validationProblems()...)
+	problems = append(problems, c.GenerationRetry.validationProblems()...)
+	problems = append(problems, c.RateLimits.validationProblems()...)
+	if c.ResponseCacheTTL < 0 {
+		problems = append(problems, "ResponseCacheTTL must not be negative")
+	}
+	if c.StreamStallThreshold < 0 {
+		problems = append(problems, "StreamStallThreshold must not be negative")
+	}
+	problems = append(problems, c.CircuitBreaker.validationProblems()...)
+	problems = append(problems, c.meteringHTTPProblems()...)
+	if c.LogLevel != "" {
+		if _, ok := ParseLogLevel(c.LogLevel); !ok {
+			problems = append(problems, fmt.Sprintf("unknown log level %q", c.LogLevel))
+		}
+	}
+	if c.LogFormat != "" && !strings.EqualFold(c.LogFormat, LogFormatText) && !strings.EqualFold(c.LogFormat, LogFormatJSON) {
+		problems = append(problems, fmt.Sprintf("unknown log format %q (expected \"text\" or \"json\")", c.LogFormat))
+	}
+
+	return problems
+}
+
+// validateBaseURL checks that a base URL is an absolute http(s) URL and describes the problem if not
+func validateBaseURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Sprintf("is not a valid URL: %v", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Sprintf("must use http or https, got %q", raw)
+	}
+	if parsed.Host == "" {
+		return fmt.Sprintf("must include a host, got %q", raw)
+	}
+	return ""
+}
+
+// newConfigValidationError builds a configuration error that lists every problem
+func newConfigValidationError(message string, problems []string) *ReveniumError {
+	noun := "problems"
+	if len(problems) == 1 {
+		noun = "problem"
+	}
+
+	return NewConfigError(fmt.Sprintf("%s (%d %s): %s", message, len(problems), noun, strings.Join(problems, "; ")), nil).
+		WithDetails("problems", problems)
 }
 
 // isValidAPIKeyFormat checks if the API key has a valid format
@@ -172,7 +659,7 @@ func isValidAPIKeyFormat(key string) bool {
 
 // NormalizeReveniumBaseURL normalizes the base URL to a consistent format
 // It handles various input formats and returns a normalized base URL without trailing slash
-// The endpoint path (/meter/v2/ai/completions) is appended by sendMeteringRequest
+// The endpoint path for each event type is appended by reveniumSink.post (sink.go)
 func NormalizeReveniumBaseURL(baseURL string) string {
 	if baseURL == "" {
 		return ""
@@ -0,0 +1,394 @@
+package revenium
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Reserved top-level keys in a configuration file
+const (
+	configFileProfilesKey       = "profiles"
+	configFileDefaultProfileKey = "defaultProfile"
+)
+
+// LoadConfigFile loads configuration from a YAML or JSON file.
+//
+// The file holds Config fields as camelCase keys (reveniumApiKey, capturePrompts, ...).
+// Top-level keys apply to every profile; keys under profiles.<name> override them for
+// that profile. The profile is taken from REVENIUM_PROFILE, then from the file's
+// defaultProfile key; with neither, only the top-level keys are used.
+// String values may reference environment variables as ${NAME} or ${NAME:-fallback}.
+//
+// Resolution order is defaults, then the file, then environment variables, so a single
+// key can be overridden per deployment. Pass the result to NewReveniumGoogle.
+//
+// Example:
+//
+//	reveniumApiKey: ${REVENIUM_METERING_API_KEY}
+//	capturePrompts: true
+//	profiles:
+//	  dev:
+//	    debug: true
+//	  prod:
+//	    reveniumBaseUrl: https://api.revenium.ai
+//	    redactPayloads: true
+func LoadConfigFile(path string) (*Config, error) {
+	return LoadConfigFileProfile(path, os.Getenv("REVENIUM_PROFILE"))
+}
+
+// LoadConfigFileProfile loads configuration from a YAML or JSON file using the named
+// profile. An empty profile falls back to the file's defaultProfile key.
+func LoadConfigFileProfile(path string, profile string) (*Config, error) {
+	cfg := defaultConfig()
+	if err := cfg.applyConfigFile(path, profile); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.loadFromEnv(); err != nil {
+		return nil, err
+	}
+
+	cfg.ReveniumBaseURL = NormalizeReveniumBaseURL(cfg.ReveniumBaseURL)
+	return cfg, nil
+}
+
+// applyConfigFile reads the file and applies the selected profile on top of c
+func (c *Config) applyConfigFile(path string, profile string) error {
+	values, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	values = interpolateEnv(values).(map[string]interface{})
+
+	merged, err := selectConfigProfile(values, profile)
+	if err != nil {
+		return err
+	}
+
+	if problems := c.applyConfigValues(merged); len(problems) > 0 {
+		return newConfigValidationError(fmt.Sprintf("invalid configuration file %s", path), problems)
+	}
+
+	newClientLogger(c).Debug("Loaded configuration file %s (profile: %q)", path, profile)
+	return nil
+}
+
+// readConfigFile decodes a YAML or JSON file into a generic map
+func readConfigFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, NewConfigError(fmt.Sprintf("failed to read configuration file %s", path), err)
+	}
+
+	values := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &values)
+	default:
+		err = yaml.Unmarshal(data, &values)
+	}
+	if err != nil {
+		return nil, NewConfigError(fmt.Sprintf("failed to parse configuration file %s", path), err)
+	}
+	return values, nil
+}
+
+// selectConfigProfile merges the named profile over the top-level keys
+func selectConfigProfile(values map[string]interface{}, profile string) (map[string]interface{}, error) {
+	profiles, _ := values[configFileProfilesKey].(map[string]interface{})
+
+	if profile == "" {
+		profile, _ = values[configFileDefaultProfileKey].(string)
+	}
+
+	merged := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if key == configFileProfilesKey || key == configFileDefaultProfileKey {
+			continue
+		}
+		merged[key] = value
+	}
+
+	if profile == "" {
+		return merged, nil
+	}
+
+	overrides, ok := profiles[profile].(map[string]interface{})
+	if !ok {
+		available := make([]string, 0, len(profiles))
+		for name := range profiles {
+			available = append(available, name)
+		}
+		sort.Strings(available)
+		return nil, NewConfigError(fmt.Sprintf("profile %q not found in configuration file (available: %s)", profile, strings.Join(available, ", ")), nil)
+	}
+
+	for key, value := range overrides {
+		merged[key] = value
+	}
+	return merged, nil
+}
+
+// envReference matches ${NAME} and ${NAME:-fallback}
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// interpolateEnv replaces environment references in every string value
+func interpolateEnv(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return envReference.ReplaceAllStringFunc(v, func(ref string) string {
+			match := envReference.FindStringSubmatch(ref)
+			if resolved, ok := os.LookupEnv(match[1]); ok && resolved != "" {
+				return resolved
+			}
+			return match[2]
+		})
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = interpolateEnv(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = interpolateEnv(item)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// configFileField applies one configuration file key to a Config
+type configFileField func(c *Config, value interface{}) error
+
+// configFileFields maps configuration file keys to Config fields
+var configFileFields = map[string]configFileField{
+	"googleApiKey":               stringConfigField(func(c *Config) *string { return &c.GoogleAPIKey }),
+	"projectId":                  stringConfigField(func(c *Config) *string { return &c.ProjectID }),
+	"location":                   stringConfigField(func(c *Config) *string { return &c.Location }),
+	"googleBaseUrl":              stringConfigField(func(c *Config) *string { return &c.GoogleBaseURL }),
+	"reveniumApiKey":             stringConfigField(func(c *Config) *string { return &c.ReveniumAPIKey }),
+	"reveniumBaseUrl":            stringConfigField(func(c *Config) *string { return &c.ReveniumBaseURL }),
+	"vertexDisabled":             boolConfigField(func(c *Config) *bool { return &c.VertexDisabled }),
+	"debug":                      boolConfigField(func(c *Config) *bool { return &c.Debug }),
+	"logLevel":                   stringConfigField(func(c *Config) *string { return &c.LogLevel }),
+	"logFormat":                  stringConfigField(func(c *Config) *string { return &c.LogFormat }),
+	"redactPayloads":             boolConfigField(func(c *Config) *bool { return &c.RedactPayloads }),
+	"capturePrompts":             boolConfigField(func(c *Config) *bool { return &c.CapturePrompts }),
+	"maxPromptLength":            intConfigField(func(c *Config) *int { return &c.MaxPromptLength }),
+	"maxInputMessagesLength":     intConfigField(func(c *Config) *int { return &c.MaxInputMessagesLength }),
+	"promptTruncationStrategy":   truncationStrategyConfigField,
+	"dryRun":                     boolConfigField(func(c *Config) *bool { return &c.DryRun }),
+	"dryRunFile":                 stringConfigField(func(c *Config) *string { return &c.DryRunFile }),
+	"shadowBaseUrl":              stringConfigField(func(c *Config) *string { return &c.ShadowBaseURL }),
+	"shadowApiKey":               stringConfigField(func(c *Config) *string { return &c.ShadowAPIKey }),
+	"retryMaxAttempts":           intConfigField(func(c *Config) *int { return &c.RetryPolicy.MaxAttempts }),
+	"retryBaseDelay":             durationConfigField(func(c *Config) *time.Duration { return &c.RetryPolicy.BaseDelay }),
+	"retryMaxDelay":              durationConfigField(func(c *Config) *time.Duration { return &c.RetryPolicy.MaxDelay }),
+	"retryAttemptTimeout":        durationConfigField(func(c *Config) *time.Duration { return &c.RetryPolicy.AttemptTimeout }),
+	"retryDeadline":              durationConfigField(func(c *Config) *time.Duration { return &c.RetryPolicy.Deadline }),
+	"generationRetryMaxAttempts": intConfigField(func(c *Config) *int { return &c.GenerationRetry.MaxAttempts }),
+	"generationRetryBaseDelay":   durationConfigField(func(c *Config) *time.Duration { return &c.GenerationRetry.BaseDelay }),
+	"generationRetryMaxDelay":    durationConfigField(func(c *Config) *time.Duration { return &c.GenerationRetry.MaxDelay }),
+	"fallbackModels":             fallbackModelsConfigField,
+	"rateLimitRpm":               intConfigField(func(c *Config) *int { return &c.RateLimits.Default.RequestsPerMinute }),
+	"rateLimitTpm":               intConfigField(func(c *Config) *int { return &c.RateLimits.Default.TokensPerMinute }),
+	"rateLimitFailFast":          boolConfigField(func(c *Config) *bool { return &c.RateLimits.FailFast }),
+	"rateLimitModels":            rateLimitMapConfigField(func(c *Config) *map[string]RateLimit { return &c.RateLimits.Models }),
+	"rateLimitOrganizationRpm":   intConfigField(func(c *Config) *int { return &c.RateLimits.PerOrganization.RequestsPerMinute }),
+	"rateLimitOrganizationTpm":   intConfigField(func(c *Config) *int { return &c.RateLimits.PerOrganization.TokensPerMinute }),
+	"rateLimitOrganizations":     rateLimitMapConfigField(func(c *Config) *map[string]RateLimit { return &c.RateLimits.Organizations }),
+	"streamStallThreshold":       durationConfigField(func(c *Config) *time.Duration { return &c.StreamStallThreshold }),
+	"circuitBreakerDisabled":     boolConfigField(func(c *Config) *bool { return &c.CircuitBreaker.Disabled }),
+	"circuitBreakerThreshold":    intConfigField(func(c *Config) *int { return &c.CircuitBreaker.FailureThreshold }),
+	"circuitBreakerCooldown":     durationConfigField(func(c *Config) *time.Duration { return &c.CircuitBreaker.Cooldown }),
+	"meteringProxyUrl":           stringConfigField(func(c *Config) *string { return &c.MeteringProxyURL }),
+	"meteringCaFile":             stringConfigField(func(c *Config) *string { return &c.MeteringCAFile }),
+	"meteringClientCertFile":     stringConfigField(func(c *Config) *string { return &c.MeteringClientCertFile }),
+	"meteringClientKeyFile":      stringConfigField(func(c *Config) *string { return &c.MeteringClientKeyFile }),
+	"meteringConnectTimeout":     durationConfigField(func(c *Config) *time.Duration { return &c.MeteringConnectTimeout }),
+	"meteringGzip":               boolConfigField(func(c *Config) *bool { return &c.MeteringGzip }),
+	"transportMetering":          boolConfigField(func(c *Config) *bool { return &c.TransportMetering }),
+	"responseCacheTtl":           durationConfigField(func(c *Config) *time.Duration { return &c.ResponseCacheTTL }),
+	"responseCacheNamespace":     stringConfigField(func(c *Config) *string { return &c.ResponseCacheNamespace }),
+	"coalesceRequests":           boolConfigField(func(c *Config) *bool { return &c.CoalesceRequests }),
+}
+
+// applyConfigValues applies every known key and returns a problem for each bad or unknown key
+func (c *Config) applyConfigValues(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var problems []string
+	for _, key := range keys {
+		field, ok := configFileFields[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown key %q", key))
+			continue
+		}
+		if err := field(c, values[key]); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+	return problems
+}
+
+func stringConfigField(target func(c *Config) *string) configFileField {
+	return func(c *Config, value interface{}) error {
+		switch v := value.(type) {
+		case string:
+			*target(c) = v
+		case nil:
+			*target(c) = ""
+		default:
+			*target(c) = fmt.Sprint(v)
+		}
+		return nil
+	}
+}
+
+func boolConfigField(target func(c *Config) *bool) configFileField {
+	return func(c *Config, value interface{}) error {
+		switch v := value.(type) {
+		case bool:
+			*target(c) = v
+		case string:
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("expected a boolean, got %q", v)
+			}
+			*target(c) = parsed
+		default:
+			return fmt.Errorf("expected a boolean, got %v", value)
+		}
+		return nil
+	}
+}
+
+func intConfigField(target func(c *Config) *int) configFileField {
+	return func(c *Config, value interface{}) error {
+		parsed, err := parseConfigInt(value)
+		if err != nil {
+			return err
+		}
+		*target(c) = parsed
+		return nil
+	}
+}
+
+// parseConfigInt accepts an integer written as a number or a string
+func parseConfigInt(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case float64:
+		if v != float64(int(v)) {
+			return 0, fmt.Errorf("expected an integer, got %v", v)
+		}
+		return int(v), nil
+	case string:
+		parsed, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("expected an integer, got %q", v)
+		}
+		return parsed, nil
+	default:
+		return 0, fmt.Errorf("expected an integer, got %v", value)
+	}
+}
+
+// rateLimitMapConfigField reads a map of names to {rpm, tpm} limits, for example
+//
+//	rateLimitModels:
+//	  gemini-2.5-pro: {rpm: 60, tpm: 100000}
+func rateLimitMapConfigField(target func(c *Config) *map[string]RateLimit) configFileField {
+	return func(c *Config, value interface{}) error {
+		entries, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected a map of names to rpm and tpm limits, got %v", value)
+		}
+
+		limits := make(map[string]RateLimit, len(entries))
+		for name, entry := range entries {
+			fields, ok := entry.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s: expected rpm and tpm limits, got %v", name, entry)
+			}
+			var limit RateLimit
+			for key, field := range fields {
+				parsed, err := parseConfigInt(field)
+				if err != nil {
+					return fmt.Errorf("%s.%s: %v", name, key, err)
+				}
+				switch key {
+				case "rpm":
+					limit.RequestsPerMinute = parsed
+				case "tpm":
+					limit.TokensPerMinute = parsed
+				default:
+					return fmt.Errorf("%s: unknown key %q (expected rpm or tpm)", name, key)
+				}
+			}
+			limits[name] = limit
+		}
+		*target(c) = limits
+		return nil
+	}
+}
+
+func durationConfigField(target func(c *Config) *time.Duration) configFileField {
+	return func(c *Config, value interface{}) error {
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected a duration such as \"500ms\", got %v", value)
+		}
+		parsed, err := time.ParseDuration(text)
+		if err != nil {
+			return fmt.Errorf("expected a duration such as \"500ms\", got %q", text)
+		}
+		*target(c) = parsed
+		return nil
+	}
+}
+
+func fallbackModelsConfigField(c *Config, value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		c.GenerationRetry.Fallbacks = parseFallbackModels(v)
+	case []interface{}:
+		c.GenerationRetry.Fallbacks = nil
+		for _, item := range v {
+			model, ok := item.(string)
+			if !ok || model == "" {
+				return fmt.Errorf("expected a list of model names, got %v", value)
+			}
+			c.GenerationRetry.Fallbacks = append(c.GenerationRetry.Fallbacks, FallbackTarget{Model: model})
+		}
+	default:
+		return fmt.Errorf("expected a list of model names, got %v", value)
+	}
+	return nil
+}
+
+func truncationStrategyConfigField(c *Config, value interface{}) error {
+	name, _ := value.(string)
+	strategy, ok := ParseTruncationStrategy(name)
+	if !ok {
+		return fmt.Errorf("unknown truncation strategy %v", value)
+	}
+	c.PromptTruncationStrategy = strategy
+	return nil
+}
@@ -0,0 +1,265 @@
+package revenium
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfigYAML = `
+reveniumApiKey: ${TEST_REVENIUM_KEY}
+reveniumBaseUrl: ${TEST_REVENIUM_URL:-https://staging.revenium.example}
+capturePrompts: true
+maxPromptLength: 2048
+defaultProfile: dev
+profiles:
+  dev:
+    debug: true
+    logLevel: debug
+  prod:
+    redactPayloads: true
+    promptTruncationStrategy: tail
+`
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigFile_YAMLProfiles(t *testing.T) {
+	t.Setenv("TEST_REVENIUM_KEY", "hak_from_file")
+	t.Setenv("REVENIUM_PROFILE", "")
+	path := writeConfigFile(t, "revenium.yaml", testConfigYAML)
+
+	tests := []struct {
+		name       string
+		profile    string
+		wantDebug  bool
+		wantRedact bool
+		wantStrat  TruncationStrategy
+	}{
+		{"default profile", "", true, false, ""},
+		{"prod profile", "prod", false, true, TruncateTail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfigFileProfile(path, tt.profile)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.ReveniumAPIKey != "hak_from_file" {
+				t.Errorf("expected interpolated API key, got %q", cfg.ReveniumAPIKey)
+			}
+			if cfg.ReveniumBaseURL != "https://staging.revenium.example" {
+				t.Errorf("expected fallback base URL, got %q", cfg.ReveniumBaseURL)
+			}
+			if !cfg.CapturePrompts || cfg.MaxPromptLength != 2048 {
+				t.Errorf("top-level keys not applied: capture=%v max=%d", cfg.CapturePrompts, cfg.MaxPromptLength)
+			}
+			if cfg.Debug != tt.wantDebug || cfg.RedactPayloads != tt.wantRedact || cfg.PromptTruncationStrategy != tt.wantStrat {
+				t.Errorf("profile overrides not applied: debug=%v redact=%v strategy=%q",
+					cfg.Debug, cfg.RedactPayloads, cfg.PromptTruncationStrategy)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFile_JSONAndEnvOverride(t *testing.T) {
+	t.Setenv("REVENIUM_PROFILE", "")
+	t.Setenv("REVENIUM_CAPTURE_PROMPTS", "false")
+	path := writeConfigFile(t, "revenium.json", `{
+		"reveniumApiKey": "hak_from_json",
+		"capturePrompts": true,
+		"maxInputMessagesLength": "4096"
+	}`)
+
+	cfg, err := LoadConfigFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ReveniumAPIKey != "hak_from_json" {
+		t.Errorf("expected key from file, got %q", cfg.ReveniumAPIKey)
+	}
+	if cfg.CapturePrompts {
+		t.Error("environment variable should override the file")
+	}
+	if cfg.MaxInputMessagesLength != 4096 {
+		t.Errorf("expected 4096, got %d", cfg.MaxInputMessagesLength)
+	}
+}
+
+func TestLoadConfigFile_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		profile string
+		want    []string
+	}{
+		{
+			name:    "unknown keys and bad values",
+			content: "reveniumKey: x\ndebug: maybe\nmaxPromptLength: 1.5\n",
+			want:    []string{`unknown key "reveniumKey"`, "debug: expected a boolean", "maxPromptLength: expected an integer"},
+		},
+		{
+			name:    "bad rate limit maps",
+			content: "rateLimitModels:\n  gemini-2.5-pro: {rpm: fast}\nrateLimitOrganizations:\n  org-1: {rps: 1}\n",
+			want:    []string{"rateLimitModels: gemini-2.5-pro.rpm: expected an integer", `rateLimitOrganizations: org-1: unknown key "rps"`},
+		},
+		{
+			name:    "missing profile",
+			content: "profiles:\n  dev:\n    debug: true\n",
+			profile: "qa",
+			want:    []string{`profile "qa" not found`, "available: dev"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfigFile(t, "revenium.yml", tt.content)
+			_, err := LoadConfigFileProfile(path, tt.profile)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q should contain %q", err.Error(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewConfig_ConfigFileFromEnv(t *testing.T) {
+	path := writeConfigFile(t, "revenium.yaml", "reveniumApiKey: hak_from_file\nlocation: europe-west4\n")
+	t.Setenv("REVENIUM_CONFIG_FILE", path)
+	t.Setenv("REVENIUM_PROFILE", "")
+	t.Setenv("GOOGLE_CLOUD_LOCATION", "")
+
+	cfg, err := newConfig(WithReveniumAPIKey("hak_from_option"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Location != "europe-west4" {
+		t.Errorf("expected location from file, got %q", cfg.Location)
+	}
+	if cfg.ReveniumAPIKey != "hak_from_option" {
+		t.Errorf("explicit option should override the file, got %q", cfg.ReveniumAPIKey)
+	}
+}
+
+func TestLoadConfigFile_RateLimitsAndCacheTTL(t *testing.T) {
+	path := writeConfigFile(t, "revenium.yaml", `
+responseCacheTtl: 10m
+rateLimitModels:
+  gemini-2.5-pro: {rpm: 60, tpm: 100000}
+rateLimitOrganizationRpm: 30
+rateLimitOrganizations:
+  org-big: {rpm: 300}
+`)
+
+	cfg, err := LoadConfigFileProfile(path, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := RateLimitConfig{
+		Models:          map[string]RateLimit{"gemini-2.5-pro": {RequestsPerMinute: 60, TokensPerMinute: 100000}},
+		PerOrganization: RateLimit{RequestsPerMinute: 30},
+		Organizations:   map[string]RateLimit{"org-big": {RequestsPerMinute: 300}},
+	}
+	if !reflect.DeepEqual(cfg.RateLimits, want) {
+		t.Errorf("RateLimits = %+v, want %+v", cfg.RateLimits, want)
+	}
+	if cfg.ResponseCacheTTL != 10*time.Minute {
+		t.Errorf("ResponseCacheTTL = %v, want 10m", cfg.ResponseCacheTTL)
+	}
+}
+
+// codeOnlyConfigFields hold Go values that a configuration file cannot express
+var codeOnlyConfigFields = map[string]bool{
+	"GoogleHTTPClient":     true,
+	"GenaiClientConfig":    true,
+	"GenaiClient":          true,
+	"Logger":               true,
+	"MeteringSink":         true,
+	"ResponseCache":        true,
+	"MeteringHTTPClient":   true,
+	"MeteringRoundTripper": true,
+}
+
+func TestConfigFileFields_CoverEveryConfigField(t *testing.T) {
+	// Sample values tried in turn until a key accepts one and changes the Config
+	samples := []interface{}{
+		map[string]interface{}{"sample": map[string]interface{}{"rpm": 1}},
+		true, 7, "7s", "tail",
+	}
+
+	covered := map[string]bool{}
+	for key, field := range configFileFields {
+		changed := false
+		for _, sample := range samples {
+			var cfg Config
+			if field(&cfg, sample) != nil {
+				continue
+			}
+			for _, path := range changedConfigFields(reflect.ValueOf(cfg), reflect.ValueOf(Config{}), "") {
+				covered[path], changed = true, true
+			}
+			if changed {
+				break
+			}
+		}
+		if !changed {
+			t.Errorf("configuration file key %q does not set any Config field", key)
+		}
+	}
+
+	for _, path := range configFieldPaths(reflect.TypeOf(Config{}), "") {
+		if !covered[path] && !codeOnlyConfigFields[path] {
+			t.Errorf("Config field %s has no configuration file key", path)
+		}
+	}
+}
+
+// configFieldPaths lists the exported leaf fields of t, descending into nested structs
+func configFieldPaths(t reflect.Type, prefix string) []string {
+	var paths []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct {
+			paths = append(paths, configFieldPaths(field.Type, prefix+field.Name+".")...)
+			continue
+		}
+		paths = append(paths, prefix+field.Name)
+	}
+	return paths
+}
+
+// changedConfigFields lists the leaf fields that differ between a and b
+func changedConfigFields(a, b reflect.Value, prefix string) []string {
+	var paths []string
+	for i := 0; i < a.NumField(); i++ {
+		field := a.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct {
+			paths = append(paths, changedConfigFields(a.Field(i), b.Field(i), prefix+field.Name+".")...)
+			continue
+		}
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			paths = append(paths, prefix+field.Name)
+		}
+	}
+	return paths
+}
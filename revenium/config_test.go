@@ -0,0 +1,119 @@
+package revenium
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNewConfig_Precedence(t *testing.T) {
+	t.Setenv("REVENIUM_METERING_API_KEY", "hak_from_env")
+	t.Setenv("REVENIUM_METERING_BASE_URL", "https://env.revenium.example/meter/v2")
+	t.Setenv("GOOGLE_API_KEY", "google-from-env")
+	t.Setenv("REVENIUM_CAPTURE_PROMPTS", "true")
+
+	cfg, err := newConfig(WithReveniumAPIKey("hak_from_option"))
+	if err != nil {
+		t.Fatalf("unexpected env error: %v", err)
+	}
+
+	if cfg.ReveniumAPIKey != "hak_from_option" {
+		t.Errorf("explicit option should override env, got %q", cfg.ReveniumAPIKey)
+	}
+	if cfg.GoogleAPIKey != "google-from-env" {
+		t.Errorf("env should override defaults, got %q", cfg.GoogleAPIKey)
+	}
+	if cfg.ReveniumBaseURL != "https://env.revenium.example" {
+		t.Errorf("base URL should be normalized, got %q", cfg.ReveniumBaseURL)
+	}
+	if !cfg.CapturePrompts {
+		t.Error("CapturePrompts should be read from env")
+	}
+}
+
+func TestNewConfig_Defaults(t *testing.T) {
+	t.Setenv("REVENIUM_METERING_BASE_URL", "")
+
+	cfg, _ := newConfig()
+	if cfg.ReveniumBaseURL != defaultReveniumBaseURL {
+		t.Errorf("expected default base URL, got %q", cfg.ReveniumBaseURL)
+	}
+}
+
+func TestNewConfig_MalformedEnv(t *testing.T) {
+	t.Setenv("REVENIUM_MAX_PROMPT_LENGTH", "lots")
+	t.Setenv("REVENIUM_PROMPT_TRUNCATION_STRATEGY", "middle")
+
+	_, err := newConfig()
+	if err == nil {
+		t.Fatal("expected an error for malformed env values")
+	}
+	if !strings.Contains(err.Error(), "REVENIUM_MAX_PROMPT_LENGTH") || !strings.Contains(err.Error(), "REVENIUM_PROMPT_TRUNCATION_STRATEGY") {
+		t.Errorf("error should name every malformed variable: %v", err)
+	}
+}
+
+func TestConfigValidate_ListsEveryProblem(t *testing.T) {
+	cfg := &Config{
+		ReveniumAPIKey:  "not-a-key",
+		ReveniumBaseURL: "ftp://api.revenium.ai",
+		ProjectID:       "my-project",
+		LogFormat:       "xml",
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	if !IsConfigError(err) {
+		t.Errorf("expected config error, got %T", err)
+	}
+
+	for _, want := range []string{"API key format", "http or https", "GOOGLE_CLOUD_LOCATION", "log format"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %q: %v", want, err)
+		}
+	}
+
+	problems, _ := err.(*ReveniumError).GetDetails()["problems"].([]string)
+	if len(problems) != 4 {
+		t.Errorf("expected 4 problems, got %d: %v", len(problems), problems)
+	}
+}
+
+func TestConfigValidate_Valid(t *testing.T) {
+	cfg := &Config{
+		ReveniumAPIKey:  "hak_valid",
+		ReveniumBaseURL: "https://api.revenium.ai",
+		GoogleAPIKey:    "google-key",
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNew_Validates(t *testing.T) {
+	t.Setenv("REVENIUM_METERING_API_KEY", "")
+	t.Setenv("GOOGLE_API_KEY", "")
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+
+	_, err := New(context.Background(), WithReveniumBaseURL("api.revenium.ai"))
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"REVENIUM_METERING_API_KEY", "GOOGLE_API_KEY", "http or https"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %q: %v", want, err)
+		}
+	}
+}
+
+func TestNewReveniumGoogle_FillsDefaultBaseURL(t *testing.T) {
+	client, err := NewReveniumGoogle(&Config{ReveniumAPIKey: "hak_test", GoogleAPIKey: "google-key"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := client.GetConfig().ReveniumBaseURL; got != defaultReveniumBaseURL {
+		t.Errorf("expected default base URL, got %q", got)
+	}
+}
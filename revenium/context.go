@@ -11,7 +11,9 @@ const (
 	usageMetadataKey contextKey = "revenium_usage_metadata"
 )
 
-// WithUsageMetadata returns a new context with usage metadata
+// WithUsageMetadata returns a new context with usage metadata. A "transactionId"
+// entry becomes the transaction ID of every call made with the context; Revenium
+// deduplicates by idempotency key, which stays unique per event.
 func WithUsageMetadata(ctx context.Context, metadata map[string]interface{}) context.Context {
 	return context.WithValue(ctx, usageMetadataKey, metadata)
 }
@@ -0,0 +1,167 @@
+package revenium
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requiredPayloadFields are present in every metering payload
+var requiredPayloadFields = []string{
+	"model", "provider", "transactionId", "costType", "operationType", "stopReason",
+	"requestTime", "responseTime", "middlewareSource",
+}
+
+// countPayloadFields are the non-negative counters checked for each event type
+var countPayloadFields = map[MeteringEventType][]string{
+	MeteringEventCompletion: {"inputTokenCount", "outputTokenCount", "totalTokenCount", "requestDuration"},
+	MeteringEventImage:      {"actualImageCount", "requestedImageCount", "requestDuration"},
+	MeteringEventVideo:      {"actualVideoCount", "requestedVideoCount", "requestDuration"},
+}
+
+// Validate checks that the event has a known type, every required payload field,
+// parseable timestamps and non-negative counters. Every problem is reported: the
+// returned *ReveniumError lists them in its message and in Details["problems"].
+func (e MeteringEvent) Validate() error {
+	var problems []string
+
+	counters, ok := countPayloadFields[e.Type]
+	if !ok {
+		problems = append(problems, fmt.Sprintf("unknown event type %q", e.Type))
+	}
+
+	for _, field := range requiredPayloadFields {
+		if value, ok := e.Payload[field]; !ok || value == nil || value == "" {
+			problems = append(problems, fmt.Sprintf("%s is required", field))
+		}
+	}
+
+	for _, field := range []string{"requestTime", "responseTime", "completionStartTime"} {
+		if value, ok := e.Payload[field].(string); ok && value != "" {
+			if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s is not an RFC 3339 timestamp: %q", field, value))
+			}
+		}
+	}
+
+	for _, field := range counters {
+		value, ok := e.Payload[field]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s is required", field))
+			continue
+		}
+		if number, ok := payloadNumber(value); !ok {
+			problems = append(problems, fmt.Sprintf("%s must be a number, got %T", field, value))
+		} else if number < 0 {
+			problems = append(problems, fmt.Sprintf("%s must not be negative, got %v", field, value))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	noun := "problems"
+	if len(problems) == 1 {
+		noun = "problem"
+	}
+	return NewValidationError(fmt.Sprintf("invalid %s metering event (%d %s): %s", e.Type, len(problems), noun, strings.Join(problems, "; ")), nil).
+		WithDetails("problems", problems)
+}
+
+// payloadNumber converts the numeric types used in payloads to float64
+func payloadNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// dryRunSink validates events and logs them instead of sending them. When a file is
+// configured, events are also appended to it as JSON lines.
+type dryRunSink struct {
+	config func() *Config
+	logger Logger
+
+	fileOnce sync.Once
+	file     *JSONLSink
+	fileErr  error
+}
+
+// Send validates, logs and optionally records the event
+func (s *dryRunSink) Send(ctx context.Context, event MeteringEvent) error {
+	config := s.config()
+	logger := payloadLogger(s.logger, event.Payload)
+
+	jsonData, err := json.Marshal(event.Payload)
+	if err != nil {
+		return NewMeteringError("failed to marshal metering payload", err)
+	}
+
+	validationErr := event.Validate()
+	if validationErr != nil {
+		logger.Warn("[METERING] Dry run: %v", validationErr)
+	}
+	logger.Info("[METERING] Dry run, %s event not sent: %s", event.Type, describePayload(config, jsonData))
+
+	if config.DryRunFile != "" {
+		s.fileOnce.Do(func() {
+			s.file, s.fileErr = NewJSONLFileSink(config.DryRunFile)
+		})
+		if s.fileErr != nil {
+			return s.fileErr
+		}
+		if err := s.file.Send(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	return validationErr
+}
+
+// Close closes the dry-run file, if one was opened
+func (s *dryRunSink) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+// shadowSink sends every event to the primary sink and, concurrently, to the current
+// shadow sink, if any. Only the primary result is returned; shadow failures are logged.
+type shadowSink struct {
+	primary MeteringSink
+	shadow  func() MeteringSink
+	logger  Logger
+}
+
+// Send delivers the event to both sinks and waits for both
+func (s *shadowSink) Send(ctx context.Context, event MeteringEvent) error {
+	shadow := s.shadow()
+	if shadow == nil {
+		return s.primary.Send(ctx, event)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := shadow.Send(ctx, event); err != nil {
+			payloadLogger(s.logger, event.Payload).Warn("[METERING] Shadow metering failed: %v", err)
+		}
+	}()
+
+	err := s.primary.Send(ctx, event)
+	wg.Wait()
+	return err
+}
@@ -0,0 +1,177 @@
+package revenium
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+func validCompletionEvent() MeteringEvent {
+	now := time.Now()
+	resp := &genai.GenerateContentResponse{
+		UsageMetadata: &genai.GenerateContentResponseUsageMetadata{PromptTokenCount: 10, CandidatesTokenCount: 5, TotalTokenCount: 15},
+	}
+	payload := buildGoogleMeteringPayloadWithTimingAndVision(resp, "gemini-2.0-flash", nil, false, now, now, now, "Google", nil, nil, VisionDetectionResult{}, NewDefaultLogger())
+	return MeteringEvent{Type: MeteringEventCompletion, Payload: payload}
+}
+
+func TestMeteringEvent_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		mutate   func(event *MeteringEvent)
+		wantProb []string
+	}{
+		{"built payload is valid", func(event *MeteringEvent) {}, nil},
+		{"missing model", func(event *MeteringEvent) { delete(event.Payload, "model") }, []string{"model is required"}},
+		{"negative tokens", func(event *MeteringEvent) { event.Payload["outputTokenCount"] = int64(-1) }, []string{"outputTokenCount must not be negative, got -1"}},
+		{"bad timestamp", func(event *MeteringEvent) { event.Payload["requestTime"] = "yesterday" }, []string{`requestTime is not an RFC 3339 timestamp: "yesterday"`}},
+		{"unknown type", func(event *MeteringEvent) { event.Type = "audio" }, []string{`unknown event type "audio"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := validCompletionEvent()
+			tt.mutate(&event)
+
+			err := event.Validate()
+			if tt.wantProb == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !IsValidationError(err) {
+				t.Fatalf("expected validation error, got %v", err)
+			}
+			problems, _ := err.(*ReveniumError).Details["problems"].([]string)
+			if strings.Join(problems, "|") != strings.Join(tt.wantProb, "|") {
+				t.Errorf("expected problems %v, got %v", tt.wantProb, problems)
+			}
+		})
+	}
+}
+
+func TestDryRun_WritesEventsWithoutSending(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("dry run must not send metering requests, got %s", r.URL.Path)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "dry-run.jsonl")
+	client, err := NewReveniumGoogle(&Config{
+		ReveniumAPIKey:  "hak_dry_run",
+		ReveniumBaseURL: server.URL,
+		GoogleAPIKey:    "google-key",
+		DryRun:          true,
+		DryRunFile:      path,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	valid := validCompletionEvent()
+	if err := client.metering.send(context.Background(), valid.Type, valid.Payload); err != nil {
+		t.Fatalf("unexpected error for valid event: %v", err)
+	}
+	invalid := testEvent(MeteringEventImage)
+	if err := client.metering.send(context.Background(), invalid.Type, invalid.Payload); !IsValidationError(err) {
+		t.Fatalf("expected validation error for invalid event, got %v", err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open dry-run file: %v", err)
+	}
+	defer file.Close()
+
+	var types []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event MeteringEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		types = append(types, string(event.Type))
+	}
+	if strings.Join(types, ",") != "completion,image" {
+		t.Errorf("expected both events in the dry-run file, got %v", types)
+	}
+}
+
+func TestDryRun_DoesNotRequireAPIKey(t *testing.T) {
+	cfg := &Config{GoogleAPIKey: "google-key", DryRun: true}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("dry run without a Revenium API key should be valid, got %v", err)
+	}
+}
+
+func TestShadowMetering(t *testing.T) {
+	newServer := func(status int, keys *[]string, mu *sync.Mutex) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			*keys = append(*keys, r.Header.Get("x-api-key"))
+			mu.Unlock()
+			w.WriteHeader(status)
+		}))
+	}
+
+	tests := []struct {
+		name         string
+		shadowKey    string
+		shadowStatus int
+		wantShadow   string
+	}{
+		{"shadow uses primary key by default", "", http.StatusOK, "hak_primary"},
+		{"shadow uses its own key", "hak_shadow", http.StatusOK, "hak_shadow"},
+		{"shadow failure does not fail primary", "hak_shadow", http.StatusBadRequest, "hak_shadow"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var primaryKeys, shadowKeys []string
+			primary := newServer(http.StatusOK, &primaryKeys, &mu)
+			defer primary.Close()
+			shadow := newServer(tt.shadowStatus, &shadowKeys, &mu)
+			defer shadow.Close()
+
+			client, err := NewReveniumGoogle(&Config{
+				ReveniumAPIKey:  "hak_primary",
+				ReveniumBaseURL: primary.URL,
+				GoogleAPIKey:    "google-key",
+				ShadowBaseURL:   shadow.URL,
+				ShadowAPIKey:    tt.shadowKey,
+			})
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+
+			event := validCompletionEvent()
+			if err := client.metering.send(context.Background(), event.Type, event.Payload); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if strings.Join(primaryKeys, ",") != "hak_primary" {
+				t.Errorf("expected one primary request with the primary key, got %v", primaryKeys)
+			}
+			if strings.Join(shadowKeys, ",") != tt.wantShadow {
+				t.Errorf("expected one shadow request with key %q, got %v", tt.wantShadow, shadowKeys)
+			}
+		})
+	}
+}
@@ -32,6 +32,9 @@ const (
 
 	// Internal errors
 	ErrorTypeInternal ErrorType = "INTERNAL_ERROR"
+
+	// Client-side rate limit errors
+	ErrorTypeRateLimit ErrorType = "RATE_LIMIT_ERROR"
 )
 
 // ReveniumError is the base error type for all Revenium middleware errors
@@ -77,6 +80,8 @@ func (e *ReveniumError) GetStatusCode() int {
 		return 400
 	case ErrorTypeAuth:
 		return 401
+	case ErrorTypeRateLimit:
+		return 429
 	case ErrorTypeProvider:
 		return 502
 	case ErrorTypeNetwork:
@@ -177,6 +182,15 @@ func NewInternalError(message string, err error) *ReveniumError {
 	}
 }
 
+// NewRateLimitError creates a new client-side rate limit error
+func NewRateLimitError(message string, err error) *ReveniumError {
+	return &ReveniumError{
+		Type:    ErrorTypeRateLimit,
+		Message: message,
+		Err:     err,
+	}
+}
+
 // IsConfigError checks if an error is a configuration error
 func IsConfigError(err error) bool {
 	var revErr *ReveniumError
@@ -219,6 +233,12 @@ func IsValidationError(err error) bool {
 	return errors.As(err, &revErr) && revErr.Type == ErrorTypeValidation
 }
 
+// IsRateLimitError checks if an error is a client-side rate limit error
+func IsRateLimitError(err error) bool {
+	var revErr *ReveniumError
+	return errors.As(err, &revErr) && revErr.Type == ErrorTypeRateLimit
+}
+
 // IsReveniumError checks if an error is a ReveniumError
 func IsReveniumError(err error) bool {
 	var revErr *ReveniumError
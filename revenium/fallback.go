@@ -0,0 +1,334 @@
+package revenium
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// FallbackTarget is one entry of a fallback chain: another model, another genai
+// client (for example Vertex AI in another region), or both
+type FallbackTarget struct {
+	// Model replaces the requested model ("" keeps it)
+	Model string
+	// Client sends the attempt through another genai client (nil uses the client's own).
+	// The metered provider follows the client's backend.
+	Client *genai.Client
+}
+
+// GenerationRetryPolicy controls retries of Google generation calls made through
+// ModelsInterface. Retries and fallbacks are off until MaxAttempts is above 1 or
+// Fallbacks are set. Every attempt is metered on its own with a rising retryNumber;
+// retries carry the first attempt's transaction ID as parentTransactionId.
+type GenerationRetryPolicy struct {
+	// MaxAttempts is the number of attempts per target, including the first
+	MaxAttempts int
+	// BaseDelay is the backoff cap for the first retry; it doubles on each retry
+	BaseDelay time.Duration
+	// MaxDelay bounds the backoff cap
+	MaxDelay time.Duration
+	// Fallbacks are tried in order once the attempts on the requested model are
+	// exhausted by retryable errors
+	Fallbacks []FallbackTarget
+}
+
+// DefaultGenerationRetryPolicy returns the delays used when retries are enabled
+// without them: 500ms base delay and 10s max delay, one attempt per target
+func DefaultGenerationRetryPolicy() GenerationRetryPolicy {
+	return GenerationRetryPolicy{
+		MaxAttempts: 1,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+	}
+}
+
+// WithGenerationRetry retries retryable Google errors (request timeouts, rate limits,
+// server and network errors) and walks the policy's fallback chain
+func WithGenerationRetry(policy GenerationRetryPolicy) Option {
+	return func(c *Config) {
+		c.GenerationRetry = policy
+	}
+}
+
+// normalized returns the policy with defaults filled in for unset fields
+func (p GenerationRetryPolicy) normalized() GenerationRetryPolicy {
+	defaults := DefaultGenerationRetryPolicy()
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaults.MaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = defaults.BaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = defaults.MaxDelay
+	}
+	if p.MaxDelay < p.BaseDelay {
+		p.MaxDelay = p.BaseDelay
+	}
+	return p
+}
+
+// validationProblems describes every invalid field
+func (p GenerationRetryPolicy) validationProblems() []string {
+	var problems []string
+	if p.MaxAttempts < 0 {
+		problems = append(problems, "GenerationRetry.MaxAttempts must not be negative")
+	}
+	if p.BaseDelay < 0 {
+		problems = append(problems, "GenerationRetry.BaseDelay must not be negative")
+	}
+	if p.MaxDelay < 0 {
+		problems = append(problems, "GenerationRetry.MaxDelay must not be negative")
+	}
+	for i, target := range p.Fallbacks {
+		if target.Model == "" && target.Client == nil {
+			problems = append(problems, fmt.Sprintf("GenerationRetry.Fallbacks[%d] must set Model or Client", i))
+		}
+	}
+	return problems
+}
+
+// backoff returns the full-jitter delay before retry number retry
+func (p GenerationRetryPolicy) backoff(retry int) time.Duration {
+	return RetryPolicy{BaseDelay: p.BaseDelay, MaxDelay: p.MaxDelay}.backoff(retry)
+}
+
+// parseFallbackModels splits a comma-separated list of models into fallback targets
+func parseFallbackModels(value string) []FallbackTarget {
+	var targets []FallbackTarget
+	for _, model := range strings.Split(value, ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			targets = append(targets, FallbackTarget{Model: model})
+		}
+	}
+	return targets
+}
+
+// isRetryableGenaiError reports whether a Google call failed in a way worth retrying:
+// request timeouts, rate limits, server errors and network errors
+func isRetryableGenaiError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		return isRetryableStatus(apiErr.Code)
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// generationAttempt is one planned call: the target and its metering metadata
+type generationAttempt struct {
+	models        *ModelsInterface
+	model         string
+	ctx           context.Context
+	transactionID string
+	retryNumber   int
+}
+
+// generationPlan walks the requested model and the fallback chain attempt by attempt
+type generationPlan struct {
+	m        *ModelsInterface
+	ctx      context.Context
+	policy   GenerationRetryPolicy
+	targets  []FallbackTarget
+	metadata map[string]interface{}
+
+	target        int
+	targetAttempt int
+	retryNumber   int
+	parentID      string
+}
+
+// newGenerationPlan starts a plan for model with the client's current retry policy
+func (m *ModelsInterface) newGenerationPlan(ctx context.Context, model string) *generationPlan {
+	policy := m.parent.GetConfig().GenerationRetry.normalized()
+	targets := append([]FallbackTarget{{Model: model}}, policy.Fallbacks...)
+	return &generationPlan{m: m, ctx: ctx, policy: policy, targets: targets, metadata: GetUsageMetadata(ctx)}
+}
+
+// first returns the first attempt, which keeps the caller's metadata as is
+func (p *generationPlan) first() generationAttempt {
+	metadata, id := withTransactionID(p.metadata)
+	p.parentID = id
+	return p.attempt(metadata, id)
+}
+
+// next waits before the next attempt and returns it, or reports false when the
+// attempts are exhausted, lastErr is not retryable or ctx is done
+func (p *generationPlan) next(lastErr error) (generationAttempt, bool) {
+	if !isRetryableGenaiError(lastErr) {
+		return generationAttempt{}, false
+	}
+
+	if p.targetAttempt+1 < p.policy.MaxAttempts {
+		p.targetAttempt++
+		delay := p.policy.backoff(p.targetAttempt)
+		p.m.logger.Warn("Google call failed (%v); retrying in %v", lastErr, delay)
+		if err := sleepContext(p.ctx, delay); err != nil {
+			return generationAttempt{}, false
+		}
+	} else if p.target+1 < len(p.targets) {
+		p.target++
+		p.targetAttempt = 0
+		p.m.logger.Warn("Google call failed (%v); falling back to %s", lastErr, p.describeTarget())
+	} else {
+		return generationAttempt{}, false
+	}
+
+	p.retryNumber++
+	metadata := make(map[string]interface{}, len(p.metadata)+2)
+	for key, value := range p.metadata {
+		metadata[key] = value
+	}
+	metadata["retryNumber"] = p.retryNumber
+	metadata["parentTransactionId"] = p.parentID
+	delete(metadata, "transactionId")
+	metadata, id := withTransactionID(metadata)
+	return p.attempt(metadata, id), true
+}
+
+// attempt builds the attempt for the current target
+func (p *generationPlan) attempt(metadata map[string]interface{}, transactionID string) generationAttempt {
+	target := p.targets[p.target]
+	models := p.m
+	if target.Client != nil {
+		models = &ModelsInterface{
+			client:   target.Client,
+			provider: providerForBackend(target.Client.ClientConfig().Backend),
+			logger:   p.m.logger,
+			parent:   p.m.parent,
+		}
+	}
+
+	model := target.Model
+	if model == "" {
+		model = p.targets[0].Model
+	}
+	return generationAttempt{
+		models:        models,
+		model:         model,
+		ctx:           WithUsageMetadata(p.ctx, metadata),
+		transactionID: transactionID,
+		retryNumber:   p.retryNumber,
+	}
+}
+
+// describeTarget names the current target for logs
+func (p *generationPlan) describeTarget() string {
+	target := p.targets[p.target]
+	model := target.Model
+	if model == "" {
+		model = p.targets[0].Model
+	}
+	if target.Client != nil {
+		return fmt.Sprintf("%s on %s", model, providerForBackend(target.Client.ClientConfig().Backend))
+	}
+	return model
+}
+
+// generateWithRetries answers from the response cache when it can, joins an identical
+// in-flight call when coalescing is on, and otherwise runs GenerateContent attempts
+// until one succeeds or the plan is exhausted. It returns the transaction ID of the
+// cache hit, the coalesced event or the last attempt, or "" when the last attempt was
+// rejected before it was metered (for example by a fail-fast rate limit).
+func (m *ModelsInterface) generateWithRetries(
+	ctx context.Context,
+	model string,
+	contents []*genai.Content,
+	config *genai.GenerateContentConfig,
+) (*genai.GenerateContentResponse, string, error) {
+	cfg := m.parent.GetConfig()
+	var key string
+	if cfg.ResponseCache != nil || cfg.CoalesceRequests {
+		var err error
+		if key, err = responseCacheKey(m.cacheScope(cfg.ResponseCacheNamespace), model, contents, config); err != nil {
+			m.logger.Warn("Response cannot be cached or coalesced: %v", err)
+		}
+	}
+
+	if key != "" && cfg.ResponseCache != nil {
+		requestTime := time.Now()
+		if resp, ok := m.cachedResponse(ctx, cfg.ResponseCache, key); ok {
+			metadata, transactionID := withTransactionID(GetUsageMetadata(ctx))
+			resp.SDKHTTPResponse = stampTransactionID(resp.SDKHTTPResponse, transactionID)
+			m.logger.Debug("GenerateContent answered from the response cache")
+			m.meterCacheHit(ctx, resp, model, metadata, requestTime, config)
+			return resp, transactionID, nil
+		}
+	}
+
+	generate := func(ctx context.Context) (*genai.GenerateContentResponse, string, error) {
+		plan := m.newGenerationPlan(ctx, model)
+		attempt := plan.first()
+		for {
+			resp, metered, err := attempt.models.generateContentAttempt(attempt.ctx, attempt.model, contents, config)
+			if !metered {
+				return nil, "", err
+			}
+			if err == nil {
+				if key != "" && cfg.ResponseCache != nil && cacheable(resp) {
+					m.storeResponse(ctx, cfg, key, resp)
+				}
+				return resp, attempt.transactionID, nil
+			}
+
+			next, ok := plan.next(err)
+			if !ok {
+				return nil, attempt.transactionID, err
+			}
+			attempt = next
+		}
+	}
+
+	if key != "" && cfg.CoalesceRequests {
+		return m.coalesce(ctx, key, model, config, generate)
+	}
+	return generate(ctx)
+}
+
+// streamWithRetries runs GenerateContentStream attempts. An attempt is retried only
+// when it fails before its first chunk; once content has been yielded, errors are
+// passed to the caller.
+func (m *ModelsInterface) streamWithRetries(
+	ctx context.Context,
+	model string,
+	contents []*genai.Content,
+	config *genai.GenerateContentConfig,
+) iter.Seq2[*genai.GenerateContentResponse, error] {
+	return func(yield func(*genai.GenerateContentResponse, error) bool) {
+		plan := m.newGenerationPlan(ctx, model)
+		attempt := plan.first()
+		for {
+			var retryErr error
+			yielded := false
+			for resp, err := range attempt.models.generateContentStreamAttempt(attempt.ctx, attempt.model, contents, config) {
+				if err != nil && !yielded && isRetryableGenaiError(err) {
+					retryErr = err
+					break
+				}
+				yielded = true
+				if !yield(resp, err) {
+					return
+				}
+			}
+			if retryErr == nil {
+				return
+			}
+
+			next, ok := plan.next(retryErr)
+			if !ok {
+				yield(nil, retryErr)
+				return
+			}
+			attempt = next
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package revenium
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestIsRetryableGenaiError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rate limited", genai.APIError{Code: 429}, true},
+		{"unavailable", fmt.Errorf("wrapped: %w", genai.APIError{Code: 503}), true},
+		{"request timeout", genai.APIError{Code: 408}, true},
+		{"invalid argument", genai.APIError{Code: 400}, false},
+		{"network", fmt.Errorf("doRequest: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), true},
+		{"cancelled", context.Canceled, false},
+		{"other", errors.New("unmarshal failed"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryableGenaiError(tt.err); got != tt.want {
+				t.Errorf("isRetryableGenaiError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerationRetry_Config(t *testing.T) {
+	t.Setenv("REVENIUM_GENERATION_RETRY_MAX_ATTEMPTS", "3")
+	t.Setenv("REVENIUM_FALLBACK_MODELS", "gemini-2.5-flash, gemini-2.0-flash")
+
+	cfg, err := newConfig()
+	if err != nil {
+		t.Fatalf("unexpected env error: %v", err)
+	}
+	policy := cfg.GenerationRetry
+	if policy.MaxAttempts != 3 || len(policy.Fallbacks) != 2 || policy.Fallbacks[1].Model != "gemini-2.0-flash" {
+		t.Errorf("unexpected policy from env: %+v", policy)
+	}
+
+	problems := GenerationRetryPolicy{MaxAttempts: -1, Fallbacks: []FallbackTarget{{}}}.validationProblems()
+	if joined := strings.Join(problems, "; "); !strings.Contains(joined, "MaxAttempts") || !strings.Contains(joined, "Fallbacks[0]") {
+		t.Errorf("expected problems for MaxAttempts and the empty fallback, got %v", problems)
+	}
+}
@@ -0,0 +1,113 @@
+package revenium
+
+import (
+	"context"
+
+	"google.golang.org/genai"
+)
+
+// WithGenaiClientConfig passes a full or partial genai.ClientConfig to the Google
+// client, for example to set HTTPOptions (API version, headers, timeout), an
+// HTTPClient or explicit Credentials such as a service account or impersonated
+// credentials. Fields left empty are filled from the Revenium configuration.
+func WithGenaiClientConfig(clientConfig *genai.ClientConfig) Option {
+	return func(c *Config) {
+		c.GenaiClientConfig = clientConfig
+	}
+}
+
+// WithGenaiClient wraps an already-built genai client instead of creating one. The
+// provider is taken from the client's backend and the Google settings in Config are
+// not used.
+func WithGenaiClient(client *genai.Client) Option {
+	return func(c *Config) {
+		c.GenaiClient = client
+	}
+}
+
+// genaiClientConfig returns the genai.ClientConfig the client is created with:
+// GenaiClientConfig (when set) with its empty fields filled from the Revenium
+// settings for the given provider
+func (c *Config) genaiClientConfig(provider Provider) *genai.ClientConfig {
+	var clientConfig genai.ClientConfig
+	if c.GenaiClientConfig != nil {
+		clientConfig = *c.GenaiClientConfig
+	}
+
+	if clientConfig.Backend == genai.BackendUnspecified {
+		clientConfig.Backend = genai.BackendGeminiAPI
+		if provider.IsVertexAI() {
+			clientConfig.Backend = genai.BackendVertexAI
+		}
+	}
+
+	if clientConfig.Backend == genai.BackendVertexAI {
+		if clientConfig.Project == "" {
+			clientConfig.Project = c.ProjectID
+		}
+		if clientConfig.Location == "" {
+			clientConfig.Location = c.Location
+		}
+	} else if clientConfig.APIKey == "" {
+		clientConfig.APIKey = c.GoogleAPIKey
+	}
+
+	if clientConfig.HTTPClient == nil {
+		clientConfig.HTTPClient = c.GoogleHTTPClient
+	}
+	if clientConfig.HTTPOptions.BaseURL == "" {
+		clientConfig.HTTPOptions.BaseURL = c.GoogleBaseURL
+	}
+	return &clientConfig
+}
+
+// googleProblems describes missing Google settings for the detected provider
+func (c *Config) googleProblems() []string {
+	if c.GenaiClient != nil {
+		var problems []string
+		if c.GenaiClientConfig != nil {
+			problems = append(problems, "set either GenaiClient or GenaiClientConfig, not both")
+		}
+		if c.TransportMetering {
+			problems = append(problems, "TransportMetering cannot be used with GenaiClient; the metering transport is only installed into clients the middleware creates")
+		}
+		return problems
+	}
+
+	var problems []string
+	clientConfig := c.genaiClientConfig(DetectProvider(c))
+	if clientConfig.Backend == genai.BackendVertexAI {
+		// Vertex AI express mode authenticates with an API key alone
+		if clientConfig.APIKey != "" {
+			return nil
+		}
+		if clientConfig.Project == "" {
+			problems = append(problems, "GOOGLE_CLOUD_PROJECT is required for Vertex AI")
+		}
+		if clientConfig.Location == "" {
+			problems = append(problems, "GOOGLE_CLOUD_LOCATION is required for Vertex AI")
+		}
+	} else if clientConfig.APIKey == "" {
+		problems = append(problems, "GOOGLE_API_KEY is required for Google AI")
+	}
+	return problems
+}
+
+// createGenaiClient creates a Google Genai client based on the provider configuration,
+// or returns the client passed with WithGenaiClient
+func createGenaiClient(ctx context.Context, cfg *Config, provider Provider) (*genai.Client, error) {
+	if cfg.GenaiClient != nil {
+		return cfg.GenaiClient, nil
+	}
+
+	if problems := cfg.googleProblems(); len(problems) > 0 {
+		return nil, NewConfigError(problems[0], nil)
+	}
+	clientConfig := cfg.genaiClientConfig(provider)
+	if cfg.TransportMetering && clientConfig.HTTPClient != nil {
+		// The metering transport is installed into this copy, never into the caller's client
+		httpClient := *clientConfig.HTTPClient
+		clientConfig.HTTPClient = &httpClient
+	}
+	return genai.NewClient(ctx, clientConfig)
+}
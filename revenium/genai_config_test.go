@@ -0,0 +1,98 @@
+package revenium
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestGenaiClientConfig_Merge(t *testing.T) {
+	httpClient := &http.Client{}
+	cfg := &Config{
+		GoogleAPIKey:     "google-key",
+		ProjectID:        "project",
+		Location:         "us-central1",
+		GoogleBaseURL:    "https://google.example",
+		GoogleHTTPClient: httpClient,
+		GenaiClientConfig: &genai.ClientConfig{
+			Location:    "europe-west4",
+			HTTPOptions: genai.HTTPOptions{APIVersion: "v1", Headers: http.Header{"X-Team": []string{"search"}}},
+		},
+	}
+
+	merged := cfg.genaiClientConfig(ProviderVertexAI)
+	if merged.Backend != genai.BackendVertexAI {
+		t.Errorf("backend should follow the provider, got %v", merged.Backend)
+	}
+	if merged.Project != "project" {
+		t.Errorf("empty project should be filled, got %q", merged.Project)
+	}
+	if merged.Location != "europe-west4" {
+		t.Errorf("explicit location should win, got %q", merged.Location)
+	}
+	if merged.APIKey != "" {
+		t.Errorf("Vertex AI should not receive the Gemini API key, got %q", merged.APIKey)
+	}
+	if merged.HTTPClient != httpClient || merged.HTTPOptions.BaseURL != "https://google.example" {
+		t.Errorf("HTTP client and base URL should be filled, got %+v", merged)
+	}
+	if merged.HTTPOptions.APIVersion != "v1" || merged.HTTPOptions.Headers.Get("X-Team") != "search" {
+		t.Errorf("HTTP options should be passed through, got %+v", merged.HTTPOptions)
+	}
+	if cfg.GenaiClientConfig.Project != "" {
+		t.Error("the caller's ClientConfig must not be modified")
+	}
+}
+
+func TestDetectProvider_GenaiClientConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *Config
+		want Provider
+	}{
+		{"explicit Vertex backend", &Config{VertexDisabled: true, GenaiClientConfig: &genai.ClientConfig{Backend: genai.BackendVertexAI}}, ProviderVertexAI},
+		{"explicit Gemini backend", &Config{ProjectID: "project", GenaiClientConfig: &genai.ClientConfig{Backend: genai.BackendGeminiAPI}}, ProviderGoogleAI},
+		{"unspecified backend", &Config{ProjectID: "project", GenaiClientConfig: &genai.ClientConfig{}}, ProviderVertexAI},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectProvider(tt.cfg); got != tt.want {
+				t.Errorf("DetectProvider() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoogleProblems(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		problem string
+	}{
+		{"Gemini API key", &Config{GoogleAPIKey: "key"}, ""},
+		{"missing Gemini API key", &Config{}, "GOOGLE_API_KEY"},
+		{"Vertex project and location", &Config{ProjectID: "project", Location: "us-central1"}, ""},
+		{"missing Vertex location", &Config{ProjectID: "project"}, "GOOGLE_CLOUD_LOCATION"},
+		{"Vertex express mode", &Config{GenaiClientConfig: &genai.ClientConfig{Backend: genai.BackendVertexAI, APIKey: "key"}}, ""},
+		{"API key from ClientConfig", &Config{GenaiClientConfig: &genai.ClientConfig{APIKey: "key"}}, ""},
+		{"client and config", &Config{GenaiClient: &genai.Client{}, GenaiClientConfig: &genai.ClientConfig{}}, "not both"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := tt.cfg.googleProblems()
+			if tt.problem == "" {
+				if len(problems) > 0 {
+					t.Errorf("unexpected problems: %v", problems)
+				}
+				return
+			}
+			if len(problems) == 0 || !strings.Contains(strings.Join(problems, "; "), tt.problem) {
+				t.Errorf("expected a problem mentioning %q, got %v", tt.problem, problems)
+			}
+		})
+	}
+}
@@ -1,14 +1,22 @@
 package revenium
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
 // Logger interface defines the logging methods
-// debug only shows if REVENIUM_DEBUG=true or config.debug=true
+// debug only shows if REVENIUM_DEBUG=true, config.debug=true or the log level is debug
+//
+// Messages are printf-style format strings. A *slog.Logger also satisfies this
+// interface; pass it to SetLogger and it is wrapped in a SlogLogger automatically.
 type Logger interface {
 	Debug(message string, args ...interface{})
 	Info(message string, args ...interface{})
@@ -16,8 +24,32 @@ type Logger interface {
 	Error(message string, args ...interface{})
 }
 
+// FieldLogger is implemented by loggers that can attach structured fields
+// (key-value pairs, as in slog) to every record they emit
+type FieldLogger interface {
+	Logger
+	With(args ...interface{}) Logger
+}
+
+// Structured field names used by the middleware
+const (
+	LogFieldModel         = "model"
+	LogFieldTransactionID = "transactionId"
+	LogFieldProvider      = "provider"
+	LogFieldAttempt       = "attempt"
+	LogFieldStatus        = "status"
+)
+
+// Log output formats
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
 // DefaultLogger is the default console logger implementation
 type DefaultLogger struct {
+	fields []interface{}
+	gate   *logGate // nil uses the package-level debug flag and level
 }
 
 // NewDefaultLogger creates a new default logger
@@ -28,21 +60,26 @@ func NewDefaultLogger() *DefaultLogger {
 // Debug logs a debug message
 // Debug messages are ONLY shown if:
 // 1. The global config has Debug=true, OR
-// 2. The REVENIUM_DEBUG environment variable is set to "true"
+// 2. The REVENIUM_DEBUG environment variable is set to "true", OR
+// 3. The minimum log level is debug
 func (l *DefaultLogger) Debug(message string, args ...interface{}) {
-	if getGlobalDebugFlag() || os.Getenv("REVENIUM_DEBUG") == "true" {
+	if l.gate.enabled(slog.LevelDebug) {
 		l.log("Debug", message, args...)
 	}
 }
 
-// Info logs an info message (always shown)
+// Info logs an info message (shown unless the minimum level is above info)
 func (l *DefaultLogger) Info(message string, args ...interface{}) {
-	l.log("", message, args...)
+	if l.gate.enabled(slog.LevelInfo) {
+		l.log("", message, args...)
+	}
 }
 
-// Warn logs a warning message (always shown)
+// Warn logs a warning message (shown unless the minimum level is above warn)
 func (l *DefaultLogger) Warn(message string, args ...interface{}) {
-	l.log("Warning", message, args...)
+	if l.gate.enabled(slog.LevelWarn) {
+		l.log("Warning", message, args...)
+	}
 }
 
 // Error logs an error message (always shown)
@@ -50,6 +87,14 @@ func (l *DefaultLogger) Error(message string, args ...interface{}) {
 	l.log("Error", message, args...)
 }
 
+// With returns a logger that appends the given key-value fields to every message
+func (l *DefaultLogger) With(args ...interface{}) Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(args))
+	fields = append(fields, l.fields...)
+	fields = append(fields, args...)
+	return &DefaultLogger{fields: fields, gate: l.gate}
+}
+
 // log is the internal logging method
 func (l *DefaultLogger) log(level, message string, args ...interface{}) {
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
@@ -64,9 +109,108 @@ func (l *DefaultLogger) log(level, message string, args ...interface{}) {
 		message = fmt.Sprintf(message, args...)
 	}
 
+	if len(l.fields) > 0 {
+		message += formatLogFields(l.fields)
+	}
+
 	log.Printf("%s %s", prefix, message)
 }
 
+// formatLogFields renders key-value pairs as " key=value" for the text logger
+func formatLogFields(fields []interface{}) string {
+	var b strings.Builder
+	for i := 0; i < len(fields); i += 2 {
+		key := fmt.Sprint(fields[i])
+		var value interface{} = "<missing>"
+		if i+1 < len(fields) {
+			value = fields[i+1]
+		}
+		fmt.Fprintf(&b, " %s=%v", key, value)
+	}
+	return b.String()
+}
+
+// SlogLogger adapts a *slog.Logger to the Logger interface.
+// Messages are formatted printf-style; fields added with With become slog attributes.
+type SlogLogger struct {
+	logger *slog.Logger
+	gate   *logGate // nil uses the package-level debug flag and level
+	custom bool     // a caller-supplied logger whose handler alone decides the level
+}
+
+// NewSlogLogger wraps an slog logger. A nil logger uses slog.Default().
+// The handler's Enabled method decides which records are emitted; the debug flag
+// and LogLevel, including their runtime changes, do not filter it. Use a
+// slog.LevelVar in the handler options to change its level at runtime.
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogLogger{logger: logger, custom: true}
+}
+
+// NewJSONLogger creates a logger that writes one JSON object per record to w
+// (os.Stderr when w is nil). Records below the minimum log level are dropped.
+func NewJSONLogger(w io.Writer) *SlogLogger {
+	if w == nil {
+		w = os.Stderr
+	}
+	return &SlogLogger{logger: newJSONSlog(w)}
+}
+
+// newJSONSlog creates the slog logger behind NewJSONLogger
+func newJSONSlog(w io.Writer) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
+	return slog.New(handler).With("component", "revenium")
+}
+
+// Slog returns the underlying slog logger
+func (l *SlogLogger) Slog() *slog.Logger {
+	return l.logger
+}
+
+// Debug logs a debug message
+func (l *SlogLogger) Debug(message string, args ...interface{}) {
+	l.log(slog.LevelDebug, message, args...)
+}
+
+// Info logs an info message
+func (l *SlogLogger) Info(message string, args ...interface{}) {
+	l.log(slog.LevelInfo, message, args...)
+}
+
+// Warn logs a warning message
+func (l *SlogLogger) Warn(message string, args ...interface{}) {
+	l.log(slog.LevelWarn, message, args...)
+}
+
+// Error logs an error message
+func (l *SlogLogger) Error(message string, args ...interface{}) {
+	l.log(slog.LevelError, message, args...)
+}
+
+// With returns a logger that attaches the given key-value fields to every record
+func (l *SlogLogger) With(args ...interface{}) Logger {
+	return &SlogLogger{logger: l.logger.With(args...), gate: l.gate, custom: l.custom}
+}
+
+// log is the internal logging method
+func (l *SlogLogger) log(level slog.Level, message string, args ...interface{}) {
+	if !l.custom && level < slog.LevelError && !l.gate.enabled(level) {
+		return
+	}
+
+	ctx := context.Background()
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+
+	if len(args) > 0 {
+		message = fmt.Sprintf(message, args...)
+	}
+	l.logger.Log(ctx, level, message)
+}
+
 // Global logger instance
 var globalLogger Logger = NewDefaultLogger()
 
@@ -76,15 +220,62 @@ func GetLogger() Logger {
 }
 
 // SetLogger sets a custom global logger
+// A *slog.Logger is accepted directly and wrapped in a SlogLogger.
 func SetLogger(logger Logger) {
+	if sl, ok := logger.(*slog.Logger); ok {
+		logger = NewSlogLogger(sl)
+	}
+	if logger == nil {
+		logger = NewDefaultLogger()
+	}
 	globalLogger = logger
 }
 
+// loggerWith returns the global logger with structured fields attached
+func loggerWith(args ...interface{}) Logger {
+	return withLogFields(globalLogger, args...)
+}
+
+// withLogFields attaches structured fields to a logger.
+// Loggers that do not implement FieldLogger are returned unchanged.
+func withLogFields(logger Logger, args ...interface{}) Logger {
+	if fl, ok := logger.(FieldLogger); ok {
+		return fl.With(args...)
+	}
+	return logger
+}
+
 // InitializeLogger initializes the logger from environment variables
-// Only debug mode can be toggled via REVENIUM_DEBUG
+// REVENIUM_DEBUG enables debug output, REVENIUM_LOG_LEVEL sets the minimum level
+// and REVENIUM_LOG_FORMAT=json switches the default logger to JSON output.
 func InitializeLogger() {
-	// Logger is ready to use, no initialization needed
-	// Debug mode is controlled by REVENIUM_DEBUG environment variable
+	if level, ok := ParseLogLevel(os.Getenv("REVENIUM_LOG_LEVEL")); ok {
+		SetLogLevel(level)
+	}
+	if os.Getenv("REVENIUM_DEBUG") == "true" || os.Getenv("REVENIUM_DEBUG") == "1" {
+		SetGlobalDebug(true)
+	}
+	if strings.EqualFold(os.Getenv("REVENIUM_LOG_FORMAT"), LogFormatJSON) {
+		useJSONLoggerIfDefault()
+	}
+}
+
+// configureLogging applies the logging settings from a configuration
+func configureLogging(cfg *Config) {
+	SetGlobalDebug(cfg.Debug)
+	if level, ok := ParseLogLevel(cfg.LogLevel); ok {
+		SetLogLevel(level)
+	}
+	if strings.EqualFold(cfg.LogFormat, LogFormatJSON) {
+		useJSONLoggerIfDefault()
+	}
+}
+
+// useJSONLoggerIfDefault switches to the JSON logger unless a custom logger was set
+func useJSONLoggerIfDefault() {
+	if _, ok := globalLogger.(*DefaultLogger); ok {
+		globalLogger = NewJSONLogger(os.Stderr)
+	}
 }
 
 // Convenience functions for global logger
@@ -107,16 +298,183 @@ func Error(message string, args ...interface{}) {
 // getGlobalDebugFlag returns the debug flag from the global configuration
 // This is used by the logger to check if debug mode is enabled programmatically
 func getGlobalDebugFlag() bool {
-	// This function will be implemented to access the global config
-	// For now, we'll use a package-level variable that can be set
-	return globalDebugEnabled
+	return globalLogGate.debug.Load()
 }
 
-// Package-level variable to track debug state
-var globalDebugEnabled bool
+// logGate holds a debug flag and a minimum log level.
+// Each client owns one; the package-level logger uses globalLogGate.
+type logGate struct {
+	debug    atomic.Bool
+	minLevel atomic.Int64
+}
+
+// newLogGate creates a gate with the given debug flag and level name
+func newLogGate(debug bool, level string) *logGate {
+	g := &logGate{}
+	g.debug.Store(debug)
+	g.minLevel.Store(int64(slog.LevelInfo))
+	if parsed, ok := ParseLogLevel(level); ok {
+		g.minLevel.Store(int64(parsed))
+	}
+	return g
+}
+
+// level returns the effective minimum level; a nil gate uses the package-level gate
+func (g *logGate) level() slog.Level {
+	if g == nil {
+		g = globalLogGate
+	}
+	level := slog.Level(g.minLevel.Load())
+	if g.debug.Load() && level > slog.LevelDebug {
+		return slog.LevelDebug
+	}
+	return level
+}
+
+// enabled reports whether records at the given level should be emitted
+func (g *logGate) enabled(level slog.Level) bool {
+	return level >= g.level()
+}
+
+// update replaces the debug flag and, when the name parses, the minimum level.
+// A nil gate updates the package-level gate.
+func (g *logGate) update(debug bool, level string) {
+	if g == nil {
+		g = globalLogGate
+	}
+	g.debug.Store(debug)
+	if parsed, ok := ParseLogLevel(level); ok {
+		g.minLevel.Store(int64(parsed))
+	}
+}
+
+// gateOf returns the gate filtering a logger, or nil for loggers that follow
+// the package-level gate
+func gateOf(logger Logger) *logGate {
+	switch l := logger.(type) {
+	case *DefaultLogger:
+		return l.gate
+	case *SlogLogger:
+		return l.gate
+	case *gatedLogger:
+		return l.gate
+	}
+	return nil
+}
+
+// Package-level state for debug mode and the minimum log level.
+// The debug flag is read from REVENIUM_DEBUG once at startup instead of on every call.
+var globalLogGate = newLogGate(false, "")
+
+func init() {
+	InitializeLogger()
+}
 
 // SetGlobalDebug sets the global debug flag
-// This is called when the configuration is initialized
+// This affects the package-level logger and the client created by Initialize;
+// clients created with NewReveniumGoogle use their own Config.Debug.
 func SetGlobalDebug(enabled bool) {
-	globalDebugEnabled = enabled
+	globalLogGate.debug.Store(enabled)
+}
+
+// SetLogLevel sets the minimum level for package-level log output
+// Debug output is also enabled by the debug flag regardless of this level.
+func SetLogLevel(level slog.Level) {
+	globalLogGate.minLevel.Store(int64(level))
+}
+
+// GetLogLevel returns the effective package-level minimum log level
+func GetLogLevel() slog.Level {
+	return globalLogGate.level()
+}
+
+// gatedLogger filters a custom logger by a client's debug flag and level
+type gatedLogger struct {
+	next Logger
+	gate *logGate
+}
+
+func (l *gatedLogger) Debug(message string, args ...interface{}) {
+	if l.gate.enabled(slog.LevelDebug) {
+		l.next.Debug(message, args...)
+	}
+}
+
+func (l *gatedLogger) Info(message string, args ...interface{}) {
+	if l.gate.enabled(slog.LevelInfo) {
+		l.next.Info(message, args...)
+	}
+}
+
+func (l *gatedLogger) Warn(message string, args ...interface{}) {
+	if l.gate.enabled(slog.LevelWarn) {
+		l.next.Warn(message, args...)
+	}
+}
+
+func (l *gatedLogger) Error(message string, args ...interface{}) {
+	l.next.Error(message, args...)
+}
+
+// With attaches fields when the wrapped logger supports them
+func (l *gatedLogger) With(args ...interface{}) Logger {
+	return &gatedLogger{next: withLogFields(l.next, args...), gate: l.gate}
+}
+
+// globalLoggerProxy forwards to whatever the package-level logger is at call time,
+// so SetLogger keeps affecting the client created by Initialize
+type globalLoggerProxy struct{}
+
+func (globalLoggerProxy) Debug(message string, args ...interface{}) { Debug(message, args...) }
+func (globalLoggerProxy) Info(message string, args ...interface{})  { Info(message, args...) }
+func (globalLoggerProxy) Warn(message string, args ...interface{})  { Warn(message, args...) }
+func (globalLoggerProxy) Error(message string, args ...interface{}) { Error(message, args...) }
+
+// With attaches fields to the current package-level logger
+func (globalLoggerProxy) With(args ...interface{}) Logger {
+	return loggerWith(args...)
+}
+
+// filteredByHandler reports whether logger is a caller-supplied slog logger, which
+// the debug flag and log level do not filter
+func filteredByHandler(logger Logger) bool {
+	l, ok := logger.(*SlogLogger)
+	return ok && l.custom
+}
+
+// newClientLogger builds the logger owned by one client. It honours Config.Logger,
+// Config.LogFormat, Config.Debug and Config.LogLevel without touching package state.
+func newClientLogger(cfg *Config) Logger {
+	gate := newLogGate(cfg.Debug, cfg.LogLevel)
+
+	switch base := cfg.Logger.(type) {
+	case nil:
+		if strings.EqualFold(cfg.LogFormat, LogFormatJSON) {
+			return &SlogLogger{logger: newJSONSlog(os.Stderr), gate: gate}
+		}
+		return &DefaultLogger{gate: gate}
+	case *slog.Logger:
+		return &SlogLogger{logger: base, gate: gate, custom: true}
+	case *SlogLogger:
+		return &SlogLogger{logger: base.logger, gate: gate, custom: base.custom}
+	case *DefaultLogger:
+		return &DefaultLogger{fields: base.fields, gate: gate}
+	default:
+		return &gatedLogger{next: base, gate: gate}
+	}
+}
+
+// ParseLogLevel parses "debug", "info", "warn"/"warning" or "error" (case-insensitive)
+func ParseLogLevel(value string) (slog.Level, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "debug":
+		return slog.LevelDebug, true
+	case "info":
+		return slog.LevelInfo, true
+	case "warn", "warning":
+		return slog.LevelWarn, true
+	case "error":
+		return slog.LevelError, true
+	}
+	return slog.LevelInfo, false
 }
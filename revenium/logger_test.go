@@ -0,0 +1,153 @@
+package revenium
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// withTestLogger swaps the global logger and level for the duration of a test
+func withTestLogger(t *testing.T, logger Logger, level slog.Level) {
+	t.Helper()
+	prevLogger := globalLogger
+	prevLevel := slog.Level(globalLogGate.minLevel.Load())
+	prevDebug := getGlobalDebugFlag()
+	t.Cleanup(func() {
+		globalLogger = prevLogger
+		SetLogLevel(prevLevel)
+		SetGlobalDebug(prevDebug)
+	})
+	SetLogger(logger)
+	SetLogLevel(level)
+	SetGlobalDebug(false)
+}
+
+func TestJSONLogger_StructuredFields(t *testing.T) {
+	var buf bytes.Buffer
+	withTestLogger(t, NewJSONLogger(&buf), slog.LevelDebug)
+
+	logger := loggerWith(LogFieldModel, "gemini-2.0-flash", LogFieldTransactionID, "tx-1")
+	withLogFields(logger, LogFieldAttempt, 2).Debug("sending %s", "payload")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("log output is not JSON: %v (%q)", err, buf.String())
+	}
+	if record["msg"] != "sending payload" {
+		t.Errorf("msg: got %v", record["msg"])
+	}
+	if record["level"] != "DEBUG" {
+		t.Errorf("level: got %v", record["level"])
+	}
+	if record[LogFieldModel] != "gemini-2.0-flash" || record[LogFieldTransactionID] != "tx-1" || record[LogFieldAttempt] != float64(2) {
+		t.Errorf("missing structured fields: %v", record)
+	}
+}
+
+func TestLogLevelFiltering(t *testing.T) {
+	var buf bytes.Buffer
+	withTestLogger(t, NewJSONLogger(&buf), slog.LevelWarn)
+
+	Debug("debug message")
+	Info("info message")
+	Warn("warn message")
+	Error("error message")
+
+	out := buf.String()
+	if strings.Contains(out, "debug message") || strings.Contains(out, "info message") {
+		t.Errorf("records below the minimum level were emitted: %s", out)
+	}
+	if !strings.Contains(out, "warn message") || !strings.Contains(out, "error message") {
+		t.Errorf("records at or above the minimum level were dropped: %s", out)
+	}
+
+	buf.Reset()
+	SetGlobalDebug(true)
+	Debug("debug enabled")
+	if !strings.Contains(buf.String(), "debug enabled") {
+		t.Error("debug flag should enable debug output regardless of the minimum level")
+	}
+}
+
+func TestSetLogger_AcceptsSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	sl := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	withTestLogger(t, sl, slog.LevelInfo)
+
+	if _, ok := GetLogger().(*SlogLogger); !ok {
+		t.Fatalf("expected *slog.Logger to be wrapped, got %T", GetLogger())
+	}
+
+	Info("model %s ready", "gemini")
+	if !strings.Contains(buf.String(), "model gemini ready") {
+		t.Errorf("expected printf-style formatting, got %q", buf.String())
+	}
+}
+
+func TestSlogLogger_DefersToCustomHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		handlerLevel slog.Level
+		packageLevel slog.Level
+		wantDebug    bool
+	}{
+		{name: "handler more verbose than LogLevel", handlerLevel: slog.LevelDebug, packageLevel: slog.LevelWarn, wantDebug: true},
+		{name: "handler less verbose than LogLevel", handlerLevel: slog.LevelInfo, packageLevel: slog.LevelDebug, wantDebug: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			sl := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: tt.handlerLevel}))
+			withTestLogger(t, sl, tt.packageLevel)
+
+			Debug("debug message")
+			if got := strings.Contains(buf.String(), "debug message"); got != tt.wantDebug {
+				t.Errorf("debug emitted = %v, want %v (%q)", got, tt.wantDebug, buf.String())
+			}
+
+			client := newClientLogger(&Config{Logger: sl, LogLevel: "error"})
+			buf.Reset()
+			client.Debug("client debug message")
+			if got := strings.Contains(buf.String(), "client debug message"); got != tt.wantDebug {
+				t.Errorf("client debug emitted = %v, want %v", got, tt.wantDebug)
+			}
+		})
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   slog.Level
+		wantOK bool
+	}{
+		{"debug", slog.LevelDebug, true},
+		{"INFO", slog.LevelInfo, true},
+		{"warning", slog.LevelWarn, true},
+		{"error", slog.LevelError, true},
+		{"verbose", slog.LevelInfo, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseLogLevel(tt.input)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ParseLogLevel(%q) = %v, %v; want %v, %v", tt.input, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestDescribePayload_Redaction(t *testing.T) {
+	body := []byte(`{"inputMessages":"secret prompt"}`)
+
+	if got := describePayload(&Config{}, body); got != string(body) {
+		t.Errorf("expected full payload, got %q", got)
+	}
+
+	got := describePayload(&Config{RedactPayloads: true}, body)
+	if strings.Contains(got, "secret prompt") {
+		t.Errorf("payload body leaked into logs: %q", got)
+	}
+}
@@ -0,0 +1,184 @@
+package revenium
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const defaultMeteringTimeout = 10 * time.Second
+
+// meteringTransport holds the per-client resources used to deliver metering payloads.
+// Each ReveniumGoogle owns one, so clients never share HTTP connections, API keys or loggers.
+// The configuration is swapped atomically on reload; every delivery attempt reads the
+// current one, so retries of in-flight events use a rotated API key.
+type meteringTransport struct {
+	config     atomic.Pointer[Config]
+	httpClient *http.Client
+	logger     Logger
+	sink       MeteringSink
+
+	// primaryBreaker guards deliveries to the Revenium API; nil with custom sinks and
+	// dry runs
+	primaryBreaker *circuitBreaker
+	// shadow is the route to the shadow endpoint, rebuilt when the shadow settings
+	// are reloaded; nil when no shadow endpoint is configured
+	shadow atomic.Pointer[shadowRoute]
+}
+
+// shadowRoute delivers events to the shadow endpoint behind its own circuit breaker
+type shadowRoute struct {
+	sink    MeteringSink
+	breaker *circuitBreaker
+}
+
+// newMeteringTransport creates the metering transport for one client. In dry-run mode
+// events are only validated and logged. Otherwise they go to cfg.MeteringSink when set,
+// or to the Revenium metering API and, when a shadow base URL is configured, also to
+// the shadow endpoint, each behind its own circuit breaker. Every endpoint shares one
+// HTTP client built from the metering HTTP settings. The shadow endpoint can be added,
+// changed or removed by a reload.
+func newMeteringTransport(cfg *Config, logger Logger) (*meteringTransport, error) {
+	httpClient, err := newMeteringHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &meteringTransport{
+		httpClient: httpClient,
+		logger:     logger,
+		sink:       cfg.MeteringSink,
+	}
+	t.config.Store(cfg)
+
+	switch {
+	case cfg.DryRun:
+		t.sink = &dryRunSink{config: t.currentConfig, logger: logger}
+	case t.sink != nil:
+	default:
+		t.primaryBreaker = newCircuitBreaker("Revenium", t.currentConfig, logger)
+		t.sink = &shadowSink{
+			primary: &breakerSink{next: &reveniumSink{config: t.currentConfig, httpClient: t.httpClient, logger: logger}, breaker: t.primaryBreaker},
+			shadow:  t.currentShadowSink,
+			logger:  logger,
+		}
+		t.buildShadowRoute(cfg)
+	}
+	return t, nil
+}
+
+// buildShadowRoute replaces the shadow route with a fresh one for cfg, or removes it
+// when cfg has no shadow base URL
+func (t *meteringTransport) buildShadowRoute(cfg *Config) {
+	if cfg.ShadowBaseURL == "" {
+		t.shadow.Store(nil)
+		return
+	}
+	breaker := newCircuitBreaker("Shadow", t.currentConfig, t.logger)
+	shadowLogger := withLogFields(t.logger, "shadow", true)
+	t.shadow.Store(&shadowRoute{
+		sink:    &breakerSink{next: &reveniumSink{config: t.shadowConfig, httpClient: t.httpClient, logger: shadowLogger}, breaker: breaker},
+		breaker: breaker,
+	})
+}
+
+// currentShadowSink returns the current shadow route's sink, or nil without one
+func (t *meteringTransport) currentShadowSink() MeteringSink {
+	if route := t.shadow.Load(); route != nil {
+		return route.sink
+	}
+	return nil
+}
+
+// health reports the state of the transport's circuit breakers
+func (t *meteringTransport) health() MeteringHealth {
+	health := MeteringHealth{Primary: CircuitHealth{State: CircuitClosed}}
+	if t.primaryBreaker != nil {
+		health.Primary = t.primaryBreaker.health()
+	}
+	if route := t.shadow.Load(); route != nil {
+		shadow := route.breaker.health()
+		health.Shadow = &shadow
+	}
+	return health
+}
+
+// currentConfig returns the configuration used for the next delivery attempt
+func (t *meteringTransport) currentConfig() *Config {
+	return t.config.Load()
+}
+
+// shadowConfig returns the current configuration pointed at the shadow endpoint. The
+// primary API key is used unless a shadow API key is set.
+func (t *meteringTransport) shadowConfig() *Config {
+	shadow := *t.currentConfig()
+	shadow.ReveniumBaseURL = NormalizeReveniumBaseURL(shadow.ShadowBaseURL)
+	if shadow.ShadowAPIKey != "" {
+		shadow.ReveniumAPIKey = shadow.ShadowAPIKey
+	}
+	return &shadow
+}
+
+// setConfig replaces the configuration used for subsequent delivery attempts. A
+// changed shadow base URL or API key rebuilds the shadow route, so its circuit starts
+// closed.
+func (t *meteringTransport) setConfig(cfg *Config) {
+	previous := t.config.Swap(cfg)
+	if t.primaryBreaker == nil || previous == nil {
+		return
+	}
+	if previous.ShadowBaseURL != cfg.ShadowBaseURL || previous.ShadowAPIKey != cfg.ShadowAPIKey {
+		t.buildShadowRoute(cfg)
+	}
+}
+
+// send delivers one event to the client's sink. Delivery runs on a context detached
+// from the caller's, so cancelling the request that produced the event does not drop
+// it, bounded by the retry policy deadline so a stuck sink cannot hold Close forever.
+// When ctx carries a receipt hook, the hook gets the event's receipt and the
+// delivery outcome is reported through it, also when the sink panics.
+func (t *meteringTransport) send(ctx context.Context, eventType MeteringEventType, payload map[string]interface{}) (err error) {
+	event := MeteringEvent{Type: eventType, Payload: payload, IdempotencyKey: newTransactionID()}
+
+	deadline := DefaultRetryPolicy().Deadline
+	if config := t.currentConfig(); config != nil {
+		deadline = config.RetryPolicy.normalized().Deadline
+	}
+	sendCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), deadline)
+	defer cancel()
+
+	hook := meteringReceiptHook(ctx)
+	if hook == nil {
+		return t.sink.Send(sendCtx, event)
+	}
+
+	receipt := newMeteringReceipt(event)
+	hook(receipt)
+	defer func() {
+		if r := recover(); r != nil {
+			err = NewMeteringError(fmt.Sprintf("metering sink panic: %v", r), nil)
+		}
+		receipt.complete(err)
+	}()
+	return t.sink.Send(sendCtx, event)
+}
+
+// close releases resources held by the sink the transport created, such as the
+// dry-run file. Sinks supplied through WithMeteringSink are owned by the caller.
+func (t *meteringTransport) close() error {
+	if sink, ok := t.sink.(*dryRunSink); ok {
+		return sink.Close()
+	}
+	return nil
+}
+
+// payloadLogger returns logger with the payload's identifying fields attached
+func payloadLogger(logger Logger, payload map[string]interface{}) Logger {
+	return withLogFields(logger,
+		LogFieldModel, payload["model"],
+		LogFieldTransactionID, payload["transactionId"],
+		LogFieldProvider, payload["provider"],
+	)
+}
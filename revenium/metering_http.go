@@ -0,0 +1,136 @@
+package revenium
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// gzipMinBytes is the smallest metering body compressed when MeteringGzip is set;
+// smaller bodies gain nothing from compression
+const gzipMinBytes = 1024
+
+// defaultMeteringKeepAlive matches the keep-alive of http.DefaultTransport
+const defaultMeteringKeepAlive = 30 * time.Second
+
+// usesCustomMeteringHTTP reports whether the caller supplied the metering client or
+// round tripper, in which case proxy, TLS and connect timeout settings do not apply
+func (c *Config) usesCustomMeteringHTTP() bool {
+	return c.MeteringHTTPClient != nil || c.MeteringRoundTripper != nil
+}
+
+// meteringHTTPProblems describes invalid metering HTTP settings
+func (c *Config) meteringHTTPProblems() []string {
+	var problems []string
+
+	if c.MeteringProxyURL != "" {
+		if problem := validateBaseURL(c.MeteringProxyURL); problem != "" {
+			problems = append(problems, "REVENIUM_METERING_PROXY_URL "+problem)
+		}
+	}
+	if (c.MeteringClientCertFile == "") != (c.MeteringClientKeyFile == "") {
+		problems = append(problems, "REVENIUM_METERING_CLIENT_CERT_FILE and REVENIUM_METERING_CLIENT_KEY_FILE must be set together")
+	}
+	if c.MeteringConnectTimeout < 0 {
+		problems = append(problems, "MeteringConnectTimeout must not be negative")
+	}
+	if c.usesCustomMeteringHTTP() && (c.MeteringProxyURL != "" || c.MeteringCAFile != "" ||
+		c.MeteringClientCertFile != "" || c.MeteringConnectTimeout != 0) {
+		problems = append(problems, "metering proxy, TLS and connect timeout settings cannot be combined with a custom metering HTTP client or round tripper")
+	}
+	if c.MeteringHTTPClient != nil && c.MeteringRoundTripper != nil {
+		problems = append(problems, "set either MeteringHTTPClient or MeteringRoundTripper, not both")
+	}
+
+	return problems
+}
+
+// newMeteringHTTPClient builds the HTTP client shared by every metering endpoint.
+// A configured client is used as is; otherwise the client wraps the configured round
+// tripper or a transport built from the proxy, CA bundle, client certificate and
+// connect timeout settings. Attempt timeouts come from the RetryPolicy.
+func newMeteringHTTPClient(cfg *Config) (*http.Client, error) {
+	if cfg.MeteringHTTPClient != nil {
+		return cfg.MeteringHTTPClient, nil
+	}
+	if cfg.MeteringRoundTripper != nil {
+		return &http.Client{Transport: cfg.MeteringRoundTripper}, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.MeteringProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.MeteringProxyURL)
+		if err != nil {
+			return nil, NewConfigError("invalid metering proxy URL", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if cfg.MeteringConnectTimeout > 0 {
+		dialer := &net.Dialer{Timeout: cfg.MeteringConnectTimeout, KeepAlive: defaultMeteringKeepAlive}
+		transport.DialContext = dialer.DialContext
+		transport.TLSHandshakeTimeout = cfg.MeteringConnectTimeout
+	}
+
+	if cfg.MeteringCAFile != "" || cfg.MeteringClientCertFile != "" {
+		tlsConfig, err := meteringTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// meteringTLSConfig loads the CA bundle (added to the system roots) and the client
+// certificate for mTLS
+func meteringTLSConfig(cfg *Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.MeteringCAFile != "" {
+		pem, err := os.ReadFile(cfg.MeteringCAFile)
+		if err != nil {
+			return nil, NewConfigError(fmt.Sprintf("failed to read metering CA bundle %s", cfg.MeteringCAFile), err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, NewConfigError(fmt.Sprintf("metering CA bundle %s contains no PEM certificates", cfg.MeteringCAFile), nil)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.MeteringClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.MeteringClientCertFile, cfg.MeteringClientKeyFile)
+		if err != nil {
+			return nil, NewConfigError("failed to load metering client certificate", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// gzipBody compresses a metering request body
+func gzipBody(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,194 @@
+package revenium
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// roundTripperFunc adapts a function to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestMeteringHTTP_SharedRoundTripper(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	var requests atomic.Int32
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		requests.Add(1)
+		return http.DefaultTransport.RoundTrip(req)
+	})
+
+	client, err := NewReveniumGoogle(&Config{
+		ReveniumAPIKey:       "hak_transport",
+		ReveniumBaseURL:      server.URL,
+		GoogleAPIKey:         "google-key",
+		MeteringRoundTripper: transport,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	for _, eventType := range []MeteringEventType{MeteringEventCompletion, MeteringEventImage, MeteringEventVideo} {
+		event := testEvent(eventType)
+		if err := client.metering.send(context.Background(), event.Type, event.Payload); err != nil {
+			t.Fatalf("%s: unexpected error: %v", eventType, err)
+		}
+	}
+	if requests.Load() != 3 {
+		t.Errorf("expected all three endpoints to use the custom transport, got %d requests", requests.Load())
+	}
+}
+
+func TestMeteringHTTP_Gzip(t *testing.T) {
+	tests := []struct {
+		name         string
+		promptLength int
+		wantGzip     bool
+	}{
+		{"small payloads are sent as is", 10, false},
+		{"large payloads are compressed", 4 * gzipMinBytes, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotGzip atomic.Bool
+			var gotPayload atomic.Value
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body := io.Reader(r.Body)
+				if r.Header.Get("Content-Encoding") == "gzip" {
+					gotGzip.Store(true)
+					reader, err := gzip.NewReader(r.Body)
+					if err != nil {
+						t.Errorf("invalid gzip body: %v", err)
+						return
+					}
+					body = reader
+				}
+				var payload map[string]interface{}
+				if err := json.NewDecoder(body).Decode(&payload); err != nil {
+					t.Errorf("invalid JSON body: %v", err)
+				}
+				gotPayload.Store(payload)
+				w.WriteHeader(http.StatusCreated)
+			}))
+			defer server.Close()
+
+			sink := NewReveniumSink(&Config{ReveniumAPIKey: "hak_gzip", ReveniumBaseURL: server.URL, MeteringGzip: true})
+			event := testEvent(MeteringEventCompletion)
+			event.Payload["systemPrompt"] = strings.Repeat("a", tt.promptLength)
+			if err := sink.Send(context.Background(), event); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if gotGzip.Load() != tt.wantGzip {
+				t.Errorf("gzip = %v, want %v", gotGzip.Load(), tt.wantGzip)
+			}
+			if payload, _ := gotPayload.Load().(map[string]interface{}); payload["transactionId"] != "txn-1" {
+				t.Errorf("payload did not round-trip: %v", payload)
+			}
+		})
+	}
+}
+
+func TestMeteringHTTP_CABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatalf("failed to write CA bundle: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		caFile  string
+		wantErr bool
+	}{
+		{"private CA is trusted", caFile, false},
+		{"unknown CA is rejected", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := NewReveniumSink(&Config{
+				ReveniumAPIKey:  "hak_tls",
+				ReveniumBaseURL: server.URL,
+				MeteringCAFile:  tt.caFile,
+				RetryPolicy:     RetryPolicy{MaxAttempts: 1},
+			})
+			err := sink.Send(context.Background(), testEvent(MeteringEventCompletion))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMeteringHTTP_Proxy(t *testing.T) {
+	var proxied atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Store(r.URL.String())
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer proxy.Close()
+
+	sink := NewReveniumSink(&Config{
+		ReveniumAPIKey:   "hak_proxy",
+		ReveniumBaseURL:  "http://metering.revenium.invalid",
+		MeteringProxyURL: proxy.URL,
+	})
+	if err := sink.Send(context.Background(), testEvent(MeteringEventCompletion)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, _ := proxied.Load().(string); !strings.HasPrefix(got, "http://metering.revenium.invalid/") {
+		t.Errorf("expected the request to go through the proxy, got %q", got)
+	}
+}
+
+func TestMeteringHTTP_Validation(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         Config
+		wantProblem string
+	}{
+		{"cert without key", Config{MeteringClientCertFile: "client.pem"}, "must be set together"},
+		{"bad proxy URL", Config{MeteringProxyURL: "proxy:3128"}, "REVENIUM_METERING_PROXY_URL"},
+		{"TLS with custom client", Config{MeteringHTTPClient: &http.Client{}, MeteringCAFile: "ca.pem"}, "cannot be combined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.ReveniumAPIKey = "hak_test"
+			cfg.GoogleAPIKey = "google-key"
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.wantProblem) {
+				t.Errorf("expected a problem containing %q, got %v", tt.wantProblem, err)
+			}
+		})
+	}
+
+	_, err := NewReveniumGoogle(&Config{ReveniumAPIKey: "hak_test", GoogleAPIKey: "google-key", MeteringCAFile: "/nonexistent/ca.pem"})
+	if !IsConfigError(err) {
+		t.Errorf("expected a config error for a missing CA bundle, got %v", err)
+	}
+}
@@ -1,13 +1,10 @@
 package revenium
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"iter"
-	"net/http"
 	"sync"
 	"time"
 
@@ -27,8 +24,12 @@ type ReveniumGoogle struct {
 	client   *genai.Client
 	config   *Config
 	provider Provider
+	logger   Logger
+	metering *meteringTransport
+	limiter  *rateLimiter
+	inflight callGroup
 	mu       sync.RWMutex
-	wg       sync.WaitGroup
+	wg       pendingGroup
 }
 
 var (
@@ -37,32 +38,9 @@ var (
 	initialized  bool
 )
 
-// createGenaiClient creates a Google Genai client based on the provider configuration
-func createGenaiClient(ctx context.Context, cfg *Config, provider Provider) (*genai.Client, error) {
-	if provider.IsVertexAI() {
-		if cfg.ProjectID == "" {
-			return nil, NewConfigError("GOOGLE_CLOUD_PROJECT is required for Vertex AI", nil)
-		}
-		if cfg.Location == "" {
-			return nil, NewConfigError("GOOGLE_CLOUD_LOCATION is required for Vertex AI", nil)
-		}
-		return genai.NewClient(ctx, &genai.ClientConfig{
-			Project:  cfg.ProjectID,
-			Location: cfg.Location,
-			Backend:  genai.BackendVertexAI,
-		})
-	}
-
-	if cfg.GoogleAPIKey == "" {
-		return nil, NewConfigError("GOOGLE_API_KEY is required for Google AI", nil)
-	}
-	return genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey:  cfg.GoogleAPIKey,
-		Backend: genai.BackendGeminiAPI,
-	})
-}
-
 // Initialize sets up the global Revenium middleware with configuration
+// Configuration is resolved in the same order as New: defaults, then environment
+// variables, then the given options.
 func Initialize(opts ...Option) error {
 	globalMu.Lock()
 	defer globalMu.Unlock()
@@ -74,19 +52,12 @@ func Initialize(opts ...Option) error {
 	InitializeLogger()
 	Info("Initializing Revenium middleware...")
 
-	cfg := &Config{}
-	for _, opt := range opts {
-		opt(cfg)
-	}
-
-	if err := cfg.loadFromEnv(); err != nil {
-		Warn("Failed to load configuration from environment: %v", err)
-	}
+	cfg, envErr := newConfig(opts...)
 
-	SetGlobalDebug(cfg.Debug)
+	configureLogging(cfg)
 
-	if cfg.ReveniumAPIKey == "" {
-		return NewConfigError("REVENIUM_METERING_API_KEY is required", nil)
+	if err := joinConfigErrors(envErr, cfg.Validate()); err != nil {
+		return err
 	}
 
 	provider := DetectProvider(cfg)
@@ -97,14 +68,20 @@ func Initialize(opts ...Option) error {
 		return NewProviderError("failed to create Google Genai client", err)
 	}
 
-	globalClient = &ReveniumGoogle{
-		client:   genaiClient,
-		config:   cfg,
-		provider: provider,
+	// The global client logs through the package-level logger so SetLogger and
+	// SetGlobalDebug keep working for it, unless a logger was passed explicitly
+	var logger Logger = globalLoggerProxy{}
+	if cfg.Logger != nil {
+		logger = newClientLogger(cfg)
 	}
+	client, err := newReveniumGoogle(genaiClient, cfg, provider, logger)
+	if err != nil {
+		return err
+	}
+	globalClient = client
 
 	initialized = true
-	Info("Revenium middleware initialized successfully with provider: %s", provider.String())
+	logger.Info("Revenium middleware initialized successfully with provider: %s", provider.String())
 	return nil
 }
 
@@ -127,30 +104,98 @@ func GetClient() (*ReveniumGoogle, error) {
 	return globalClient, nil
 }
 
+// New creates a new Revenium client from functional options
+// Configuration is resolved in a fixed order: defaults, then environment variables
+// (and .env files), then the explicit options, so options always win. The result is
+// validated and every problem is reported in a single error.
+func New(ctx context.Context, opts ...Option) (*ReveniumGoogle, error) {
+	cfg, envErr := newConfig(opts...)
+
+	if err := joinConfigErrors(envErr, cfg.Validate()); err != nil {
+		return nil, err
+	}
+
+	return newFromValidConfig(ctx, cfg)
+}
+
 // NewReveniumGoogle creates a new Revenium client with explicit configuration
+// The client owns its logger, debug flag, HTTP client and metering pipeline; nothing
+// is shared with other clients or with the package-level state used by Initialize.
+// Environment variables are not read; the default base URL is filled in and the
+// configuration is validated.
 func NewReveniumGoogle(cfg *Config) (*ReveniumGoogle, error) {
 	if cfg == nil {
 		return nil, NewConfigError("config cannot be nil", nil)
 	}
 
-	// Validate required fields
-	if cfg.ReveniumAPIKey == "" {
-		return nil, NewConfigError("REVENIUM_METERING_API_KEY is required", nil)
+	resolved := *cfg
+	if resolved.ReveniumBaseURL == "" {
+		resolved.ReveniumBaseURL = defaultReveniumBaseURL
 	}
+	resolved.ReveniumBaseURL = NormalizeReveniumBaseURL(resolved.ReveniumBaseURL)
 
+	if err := resolved.Validate(); err != nil {
+		return nil, err
+	}
+
+	return newFromValidConfig(context.Background(), &resolved)
+}
+
+// newFromValidConfig creates the genai client and assembles a ReveniumGoogle
+func newFromValidConfig(ctx context.Context, cfg *Config) (*ReveniumGoogle, error) {
 	provider := DetectProvider(cfg)
 
-	ctx := context.Background()
 	genaiClient, err := createGenaiClient(ctx, cfg, provider)
 	if err != nil {
 		return nil, NewProviderError("failed to create Google Genai client", err)
 	}
 
-	return &ReveniumGoogle{
-		client:   genaiClient,
+	return newReveniumGoogle(genaiClient, cfg, provider, newClientLogger(cfg))
+}
+
+// joinConfigErrors merges environment and validation errors into one configuration
+// error so every problem is listed together
+func joinConfigErrors(errs ...error) error {
+	var problems []string
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		var revErr *ReveniumError
+		if errors.As(err, &revErr) {
+			if list, ok := revErr.GetDetails()["problems"].([]string); ok {
+				problems = append(problems, list...)
+				continue
+			}
+		}
+		problems = append(problems, err.Error())
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return newConfigValidationError("invalid configuration", problems)
+}
+
+// newReveniumGoogle assembles a client with its own logger and metering transport
+func newReveniumGoogle(client *genai.Client, cfg *Config, provider Provider, logger Logger) (*ReveniumGoogle, error) {
+	metering, err := newMeteringTransport(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &ReveniumGoogle{
+		client:   client,
 		config:   cfg,
 		provider: provider,
-	}, nil
+		logger:   logger,
+		metering: metering,
+	}
+	r.limiter = newRateLimiter(r.GetConfig)
+	if cfg.TransportMetering && client != nil {
+		r.installTransportMetering(client)
+	}
+	return r, nil
 }
 
 // GetConfig returns the configuration
@@ -181,8 +226,8 @@ func (r *ReveniumGoogle) Models() *ModelsInterface {
 
 	return &ModelsInterface{
 		client:   r.client,
-		config:   r.config,
 		provider: r.provider,
+		logger:   r.logger,
 		parent:   r,
 	}
 }
@@ -190,187 +235,288 @@ func (r *ReveniumGoogle) Models() *ModelsInterface {
 // Flush waits for all pending metering requests to complete
 // This should be called before the application exits to ensure all metering data is sent
 func (r *ReveniumGoogle) Flush() {
-	Debug("Flushing pending metering requests...")
+	r.logger.Debug("Flushing pending metering requests...")
 	r.wg.Wait()
-	Debug("All metering requests completed")
+	r.logger.Debug("All metering requests completed")
 }
 
 // Close closes the client and cleans up resources
-// It waits for all pending metering requests to complete before returning
+// It waits for all pending metering requests to complete before returning. Each
+// delivery is bounded by the retry policy deadline; use Shutdown to bound the wait.
 func (r *ReveniumGoogle) Close() error {
+	// Wait for all pending metering requests without holding the lock, which
+	// metering goroutines may need
+	r.Flush()
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	// Wait for all pending metering requests
-	r.Flush()
-
 	// Google Genai client doesn't have a Close method
+	if r.metering != nil {
+		return r.metering.close()
+	}
 	return nil
 }
 
 // ModelsInterface provides methods for generating content with metering
+// The capture policy is read from the parent client on every call, so a long-lived
+// ModelsInterface picks up configuration reloads.
 type ModelsInterface struct {
 	client   *genai.Client
-	config   *Config
 	provider Provider
+	logger   Logger
 	parent   *ReveniumGoogle // Reference to parent for WaitGroup access
 }
 
 // GenerateContent generates content with automatic metering
+// With a GenerationRetryPolicy, retryable errors are retried and the fallback chain
+// is walked; every attempt is metered.
 func (m *ModelsInterface) GenerateContent(
 	ctx context.Context,
 	model string,
 	contents []*genai.Content,
 	config *genai.GenerateContentConfig,
 ) (*genai.GenerateContentResponse, error) {
+	resp, _, err := m.generateWithRetries(ctx, model, contents, config)
+	return resp, err
+}
+
+// generateContentAttempt makes and meters a single GenerateContent call. It reports
+// false when the call was rejected before reaching Google, so nothing was metered.
+func (m *ModelsInterface) generateContentAttempt(
+	ctx context.Context,
+	model string,
+	contents []*genai.Content,
+	config *genai.GenerateContentConfig,
+) (*genai.GenerateContentResponse, bool, error) {
+	// Wait for the rate limiter; the wait is metered as mediationLatency
+	ctx, reservation, err := m.parent.limiter.acquire(ctx, model, estimateTokens(contents, config))
+	if err != nil {
+		return nil, false, err
+	}
+
 	// Extract metadata from context
-	metadata := GetUsageMetadata(ctx)
+	metadata, transactionID := withTransactionID(GetUsageMetadata(ctx))
 
-	Debug("GenerateContent called with model: %s", model)
+	m.logger.Debug("GenerateContent called with model: %s", model)
 
 	// Detect vision content in the request
 	visionResult := DetectVisionContent(contents)
 	if visionResult.HasVisionContent {
-		Debug("Vision content detected: %d images, %d bytes", visionResult.ImageCount, visionResult.TotalImageSizeBytes)
+		m.logger.Debug("Vision content detected: %d images, %d bytes", visionResult.ImageCount, visionResult.TotalImageSizeBytes)
 	}
 
 	// Extract prompts if capture is enabled
+	cfg := m.parent.GetConfig()
 	var promptData *PromptData
-	if m.config.CapturePrompts {
-		data := ExtractPromptsFromRequest(contents, config)
+	if cfg.CapturePrompts {
+		data := extractPromptsFromRequest(contents, config, cfg.PromptLimits(), m.logger)
 		promptData = &data
-		Debug("Prompt capture enabled, extracted prompts")
+		m.logger.Debug("Prompt capture enabled, extracted prompts")
 	}
 
 	// Record start time for duration calculation
 	requestTime := time.Now()
 
 	// Call Google Genai API
-	resp, err := m.client.Models.GenerateContent(ctx, model, contents, config)
+	resp, err := m.client.Models.GenerateContent(skipTransportMetering(ctx), model, contents, config)
+	if err != nil {
+		reservation.settle(0)
+	} else if resp.UsageMetadata != nil {
+		reservation.settle(int64(resp.UsageMetadata.TotalTokenCount))
+	}
 
 	// Record completion time
 	completionStartTime := time.Now()
 	responseTime := completionStartTime
 
 	if err != nil {
-		Debug("GenerateContent error: %v", err)
+		m.logger.Debug("GenerateContent error: %v", err)
 		// Send metering for failed request
 		m.parent.wg.Add(1)
 		go func() {
 			defer m.parent.wg.Done()
-			m.sendMeteringDataWithPrompts(ctx, nil, model, metadata, false, requestTime, completionStartTime, responseTime, config, err, visionResult, promptData)
+			m.sendMeteringDataWithPrompts(ctx, nil, model, metadata, false, requestTime, completionStartTime, responseTime, config, err, visionResult, promptData, nil)
 		}()
-		return nil, err
+		return nil, true, err
 	}
+	resp.SDKHTTPResponse = stampTransactionID(resp.SDKHTTPResponse, transactionID)
 
 	// Extract response content for prompt capture
 	if promptData != nil {
-		responseData := ExtractResponseContent(resp, promptData.PromptsTruncated)
+		responseData := extractResponseContent(resp, promptData.PromptsTruncated, cfg.PromptLimits(), m.logger)
 		promptData.OutputResponse = responseData.OutputResponse
+		promptData.ThoughtSummary = responseData.ThoughtSummary
 		promptData.PromptsTruncated = responseData.PromptsTruncated
 	}
 
 	// Calculate duration
 	duration := time.Since(requestTime)
 
-	Debug("GenerateContent completed in %v, tokens: %d", duration, resp.UsageMetadata.TotalTokenCount)
+	m.logger.Debug("GenerateContent completed in %v, tokens: %d", duration, resp.UsageMetadata.TotalTokenCount)
 
 	// Send metering data asynchronously (fire-and-forget)
 	m.parent.wg.Add(1)
 	go func() {
 		defer m.parent.wg.Done()
-		m.sendMeteringDataWithPrompts(ctx, resp, model, metadata, false, requestTime, completionStartTime, responseTime, config, nil, visionResult, promptData)
+		m.sendMeteringDataWithPrompts(ctx, resp, model, metadata, false, requestTime, completionStartTime, responseTime, config, nil, visionResult, promptData, nil)
 	}()
 
-	return resp, nil
+	return resp, true, nil
 }
 
 // GenerateContentStream generates streaming content with automatic metering
+// With a GenerationRetryPolicy, attempts that fail before their first chunk are
+// retried and the fallback chain is walked; every attempt is metered.
 func (m *ModelsInterface) GenerateContentStream(
 	ctx context.Context,
 	model string,
 	contents []*genai.Content,
 	config *genai.GenerateContentConfig,
 ) iter.Seq2[*genai.GenerateContentResponse, error] {
-	// Extract metadata from context
-	metadata := GetUsageMetadata(ctx)
+	return m.streamWithRetries(ctx, model, contents, config)
+}
 
-	Debug("GenerateContentStream called with model: %s", model)
+// generateContentStreamAttempt makes and meters a single GenerateContentStream call
+func (m *ModelsInterface) generateContentStreamAttempt(
+	ctx context.Context,
+	model string,
+	contents []*genai.Content,
+	config *genai.GenerateContentConfig,
+) iter.Seq2[*genai.GenerateContentResponse, error] {
+	m.logger.Debug("GenerateContentStream called with model: %s", model)
 
 	// Detect vision content in the request
 	visionResult := DetectVisionContent(contents)
 	if visionResult.HasVisionContent {
-		Debug("Vision content detected: %d images, %d bytes", visionResult.ImageCount, visionResult.TotalImageSizeBytes)
+		m.logger.Debug("Vision content detected: %d images, %d bytes", visionResult.ImageCount, visionResult.TotalImageSizeBytes)
 	}
 
 	// Extract prompts if capture is enabled
+	cfg := m.parent.GetConfig()
 	var promptData *PromptData
-	if m.config.CapturePrompts {
-		data := ExtractPromptsFromRequest(contents, config)
+	if cfg.CapturePrompts {
+		data := extractPromptsFromRequest(contents, config, cfg.PromptLimits(), m.logger)
 		promptData = &data
-		Debug("Prompt capture enabled for streaming, extracted prompts")
+		m.logger.Debug("Prompt capture enabled for streaming, extracted prompts")
 	}
 
-	// Record start time for duration calculation
-	requestTime := time.Now()
-
-	// Call Google Genai API
-	stream := m.client.Models.GenerateContentStream(ctx, model, contents, config)
-
 	// Wrap the stream to capture usage metadata and send metering
 	return func(yield func(*genai.GenerateContentResponse, error) bool) {
+		// Wait for the rate limiter; the wait is metered as mediationLatency
+		limitedCtx, reservation, err := m.parent.limiter.acquire(ctx, model, estimateTokens(contents, config))
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		// Every iteration is a separate call with its own transaction ID
+		metadata, transactionID := withTransactionID(GetUsageMetadata(limitedCtx))
+
+		// Record start time for duration calculation
+		requestTime := time.Now()
+		timer := &streamTimer{start: requestTime}
+
+		// Call Google Genai API
+		stream := m.client.Models.GenerateContentStream(skipTransportMetering(limitedCtx), model, contents, config)
+
 		var lastUsage *genai.GenerateContentResponseUsageMetadata
+		var estimatedUsage *genai.GenerateContentResponseUsageMetadata
+		defer func() {
+			if lastUsage != nil {
+				reservation.settle(int64(lastUsage.TotalTokenCount))
+			} else if estimatedUsage != nil {
+				reservation.settle(int64(estimatedUsage.TotalTokenCount))
+			}
+		}()
 		var completionStartTime time.Time
 		var firstTokenReceived bool
-		var accumulatedContent string
+		accumulator := NewStreamAccumulator()
 		chunkCount := 0
 
+		finalizePromptData := func(output, thoughts string) *PromptData {
+			if promptData == nil {
+				return nil
+			}
+			limits := cfg.PromptLimits()
+			streamData := extractStreamingResponseContent(output, promptData.PromptsTruncated, limits, m.logger)
+			thoughtSummary, truncated := captureThoughtSummary(thoughts, streamData.PromptsTruncated, limits)
+			return &PromptData{
+				SystemPrompt:     promptData.SystemPrompt,
+				InputMessages:    promptData.InputMessages,
+				OutputResponse:   streamData.OutputResponse,
+				ThoughtSummary:   thoughtSummary,
+				PromptsTruncated: truncated,
+			}
+		}
+
+		// meter sends the stream's metering event in the background, based on the merged
+		// response. A stream that delivered chunks but no usage metadata was still
+		// billed by Google, so it is metered with estimated token counts.
+		meter := func(err error) {
+			responseTime := time.Now()
+			if !firstTokenReceived {
+				completionStartTime = responseTime
+			}
+
+			var resp *genai.GenerateContentResponse
+			var outputTokens int64
+			merged := accumulator.Response()
+			output, thoughts := renderResponseForCapture(merged)
+			attributes := map[string]interface{}{}
+			switch {
+			case lastUsage != nil:
+				resp = merged
+				outputTokens = int64(lastUsage.CandidatesTokenCount + lastUsage.ThoughtsTokenCount)
+			case chunkCount > 0:
+				estimatedUsage = estimateStreamUsage(contents, config, int64(len(output)), int64(len(thoughts)))
+				resp = merged
+				resp.UsageMetadata = estimatedUsage
+				outputTokens = int64(estimatedUsage.CandidatesTokenCount + estimatedUsage.ThoughtsTokenCount)
+				attributes[tokenCountEstimatedAttribute] = true
+				m.logger.Debug("Stream ended without usage metadata; metering %d estimated tokens", estimatedUsage.TotalTokenCount)
+			case err == nil:
+				// Nothing was generated and nothing failed
+				return
+			}
+
+			// Latency statistics go into the payload and to the caller's hook
+			stats := timer.stats(responseTime, outputTokens, cfg.StreamStallThreshold)
+			stats.TransactionID, stats.Model = transactionID, model
+			if stats.StallCount > 0 {
+				m.logger.Debug("Stream stalled %d times (longest gap %v)", stats.StallCount, stats.MaxChunkGap)
+			}
+			for key, value := range stats.attributes() {
+				attributes[key] = value
+			}
+			if hook := streamStatsHook(limitedCtx); hook != nil {
+				hook(stats)
+			}
+
+			// Finalize prompt data with the merged output
+			finalPromptData := finalizePromptData(output, thoughts)
+			m.parent.wg.Add(1)
+			go func() {
+				defer m.parent.wg.Done()
+				m.sendMeteringDataWithPrompts(limitedCtx, resp, model, metadata, true, requestTime, completionStartTime, responseTime, config, err, visionResult, finalPromptData, attributes)
+			}()
+		}
+
 		for resp, err := range stream {
 			if err != nil {
-				Debug("Stream error after %d chunks: %v", chunkCount, err)
-				responseTime := time.Now()
-
-				// Finalize prompt data with accumulated content
-				var finalPromptData *PromptData
-				if promptData != nil {
-					streamData := ExtractStreamingResponseContent(accumulatedContent, promptData.PromptsTruncated)
-					finalPromptData = &PromptData{
-						SystemPrompt:     promptData.SystemPrompt,
-						InputMessages:    promptData.InputMessages,
-						OutputResponse:   streamData.OutputResponse,
-						PromptsTruncated: streamData.PromptsTruncated,
-					}
-				}
-
+				m.logger.Debug("Stream error after %d chunks: %v", chunkCount, err)
 				// Send metering before yielding error
-				if lastUsage != nil {
-					if !firstTokenReceived {
-						completionStartTime = responseTime
-					}
-					m.parent.wg.Add(1)
-					go func() {
-						defer m.parent.wg.Done()
-						m.sendMeteringDataWithPrompts(ctx, &genai.GenerateContentResponse{UsageMetadata: lastUsage}, model, metadata, true, requestTime, completionStartTime, responseTime, config, err, visionResult, finalPromptData)
-					}()
-				} else {
-					// No usage data, but still send error metering
-					m.parent.wg.Add(1)
-					go func() {
-						defer m.parent.wg.Done()
-						m.sendMeteringDataWithPrompts(ctx, nil, model, metadata, true, requestTime, responseTime, responseTime, config, err, visionResult, finalPromptData)
-					}()
-				}
-				if !yield(nil, err) {
-					return
-				}
+				meter(err)
+				yield(nil, err)
 				return
 			}
 
 			chunkCount++
+			received := time.Now()
+			timer.chunk(received)
 
 			// Record time of first token
 			if !firstTokenReceived {
-				completionStartTime = time.Now()
+				completionStartTime = received
 				firstTokenReceived = true
 			}
 
@@ -379,64 +525,23 @@ func (m *ModelsInterface) GenerateContentStream(
 				lastUsage = resp.UsageMetadata
 			}
 
-			// Accumulate content for prompt capture
-			if promptData != nil {
-				accumulatedContent += resp.Text()
-			}
-
-			// Yield the response
+			// Yield the response, tagged with the call's transaction ID; the accumulator
+			// keeps a copy for prompt capture and metering
+			resp.SDKHTTPResponse = stampTransactionID(resp.SDKHTTPResponse, transactionID)
+			accumulator.Add(resp)
 			if !yield(resp, nil) {
 				// Stream was stopped, send metering
-				Debug("Stream stopped by consumer after %d chunks", chunkCount)
-				responseTime := time.Now()
-
-				// Finalize prompt data with accumulated content
-				var finalPromptData *PromptData
-				if promptData != nil {
-					streamData := ExtractStreamingResponseContent(accumulatedContent, promptData.PromptsTruncated)
-					finalPromptData = &PromptData{
-						SystemPrompt:     promptData.SystemPrompt,
-						InputMessages:    promptData.InputMessages,
-						OutputResponse:   streamData.OutputResponse,
-						PromptsTruncated: streamData.PromptsTruncated,
-					}
-				}
-
-				if lastUsage != nil {
-					m.parent.wg.Add(1)
-					go func() {
-						defer m.parent.wg.Done()
-						m.sendMeteringDataWithPrompts(ctx, &genai.GenerateContentResponse{UsageMetadata: lastUsage}, model, metadata, true, requestTime, completionStartTime, responseTime, config, nil, visionResult, finalPromptData)
-					}()
-				}
+				m.logger.Debug("Stream stopped by consumer after %d chunks", chunkCount)
+				meter(nil)
 				return
 			}
 		}
 
 		// Stream completed successfully, send final metering
-		responseTime := time.Now()
-
-		// Finalize prompt data with accumulated content
-		var finalPromptData *PromptData
-		if promptData != nil {
-			streamData := ExtractStreamingResponseContent(accumulatedContent, promptData.PromptsTruncated)
-			finalPromptData = &PromptData{
-				SystemPrompt:     promptData.SystemPrompt,
-				InputMessages:    promptData.InputMessages,
-				OutputResponse:   streamData.OutputResponse,
-				PromptsTruncated: streamData.PromptsTruncated,
-			}
-		}
-
 		if lastUsage != nil {
-			duration := time.Since(requestTime)
-			Debug("Stream completed: %d chunks, %d total tokens in %v", chunkCount, lastUsage.TotalTokenCount, duration)
-			m.parent.wg.Add(1)
-			go func() {
-				defer m.parent.wg.Done()
-				m.sendMeteringDataWithPrompts(ctx, &genai.GenerateContentResponse{UsageMetadata: lastUsage}, model, metadata, true, requestTime, completionStartTime, responseTime, config, nil, visionResult, finalPromptData)
-			}()
+			m.logger.Debug("Stream completed: %d chunks, %d total tokens in %v", chunkCount, lastUsage.TotalTokenCount, time.Since(requestTime))
 		}
+		meter(nil)
 	}
 }
 
@@ -474,7 +579,8 @@ func (m *ModelsInterface) sendMeteringDataWithTimingAndVision(
 ) {
 	defer func() {
 		if r := recover(); r != nil {
-			Error("Metering goroutine panic: %v", r)
+			m.logger.Error("Metering goroutine panic: %v", r)
+			failMeteringReceipt(ctx, metadata, model, NewInternalError(fmt.Sprintf("metering event could not be built: %v", r), nil))
 		}
 	}()
 
@@ -491,14 +597,15 @@ func (m *ModelsInterface) sendMeteringDataWithTimingAndVision(
 		config,
 		err,
 		visionResult,
+		m.logger,
 	)
 
 	// Send to Revenium API with retry logic
-	Debug("[METERING] About to send metering data...")
-	if err := sendMeteringWithRetry(m.config, payload); err != nil {
-		Error("Failed to send metering data: %v", err)
+	m.logger.Debug("[METERING] About to send metering data...")
+	if err := m.parent.metering.send(ctx, MeteringEventCompletion, payload); err != nil {
+		m.logger.Error("Failed to send metering data: %v", err)
 	} else {
-		Debug("[METERING] Metering data sent successfully")
+		m.logger.Debug("[METERING] Metering data sent successfully")
 	}
 }
 
@@ -516,10 +623,12 @@ func (m *ModelsInterface) sendMeteringDataWithPrompts(
 	err error,
 	visionResult VisionDetectionResult,
 	promptData *PromptData,
+	attributes map[string]interface{},
 ) {
 	defer func() {
 		if r := recover(); r != nil {
-			Error("Metering goroutine panic: %v", r)
+			m.logger.Error("Metering goroutine panic: %v", r)
+			failMeteringReceipt(ctx, metadata, model, NewInternalError(fmt.Sprintf("metering event could not be built: %v", r), nil))
 		}
 	}()
 
@@ -536,27 +645,26 @@ func (m *ModelsInterface) sendMeteringDataWithPrompts(
 		config,
 		err,
 		visionResult,
+		m.logger,
 	)
 
 	// Add prompt capture data if provided
 	if promptData != nil {
 		AddPromptDataToPayload(payload, *promptData)
 	}
+	if len(attributes) > 0 {
+		addPayloadAttributes(payload, attributes)
+	}
 
 	// Send to Revenium API with retry logic
-	Debug("[METERING] About to send metering data...")
-	if err := sendMeteringWithRetry(m.config, payload); err != nil {
-		Error("Failed to send metering data: %v", err)
+	m.logger.Debug("[METERING] About to send metering data...")
+	if err := m.parent.metering.send(ctx, MeteringEventCompletion, payload); err != nil {
+		m.logger.Error("Failed to send metering data: %v", err)
 	} else {
-		Debug("[METERING] Metering data sent successfully")
+		m.logger.Debug("[METERING] Metering data sent successfully")
 	}
 }
 
-// generateRequestID generates a unique request ID
-func generateRequestID() string {
-	return fmt.Sprintf("%d-%d", time.Now().UnixNano(), time.Now().UnixNano()%1000)
-}
-
 // buildGoogleMeteringPayloadWithTiming builds a metering payload with precise timing information
 // Deprecated: Use buildGoogleMeteringPayloadWithTimingAndVision for vision support
 func buildGoogleMeteringPayloadWithTiming(
@@ -570,12 +678,14 @@ func buildGoogleMeteringPayloadWithTiming(
 	provider string,
 	config *genai.GenerateContentConfig,
 	err error,
+	logger Logger,
 ) map[string]interface{} {
 	// Delegate to vision-aware version with empty vision result
-	return buildGoogleMeteringPayloadWithTimingAndVision(resp, model, metadata, isStreamed, requestTime, completionStartTime, responseTime, provider, config, err, VisionDetectionResult{})
+	return buildGoogleMeteringPayloadWithTimingAndVision(resp, model, metadata, isStreamed, requestTime, completionStartTime, responseTime, provider, config, err, VisionDetectionResult{}, logger)
 }
 
-// buildGoogleMeteringPayloadWithTimingAndVision builds a metering payload with timing and vision information
+// buildGoogleMeteringPayloadWithTimingAndVision builds a metering payload with timing and vision
+// information, logging unknown finish reasons to logger
 func buildGoogleMeteringPayloadWithTimingAndVision(
 	resp *genai.GenerateContentResponse,
 	model string,
@@ -588,11 +698,12 @@ func buildGoogleMeteringPayloadWithTimingAndVision(
 	config *genai.GenerateContentConfig,
 	err error,
 	visionResult VisionDetectionResult,
+	logger Logger,
 ) map[string]interface{} {
 	// Format timestamps as ISO 8601
-	requestTimeISO := requestTime.UTC().Format(time.RFC3339)
-	completionStartTimeISO := completionStartTime.UTC().Format(time.RFC3339)
-	responseTimeISO := responseTime.UTC().Format(time.RFC3339)
+	requestTimeISO := formatMeteringTime(requestTime)
+	completionStartTimeISO := formatMeteringTime(completionStartTime)
+	responseTimeISO := formatMeteringTime(responseTime)
 
 	// Calculate durations
 	requestDuration := responseTime.Sub(requestTime).Milliseconds()
@@ -624,7 +735,7 @@ func buildGoogleMeteringPayloadWithTimingAndVision(
 
 	// Extract and map finish reason to stop reason
 	finishReason := ExtractFinishReason(resp)
-	stopReason := string(MapGoogleFinishReason(finishReason, StopReasonEnd))
+	stopReason := string(mapGoogleFinishReason(finishReason, StopReasonEnd, logger))
 
 	payload := map[string]interface{}{
 		"stopReason":              stopReason,
@@ -638,7 +749,7 @@ func buildGoogleMeteringPayloadWithTimingAndVision(
 		"cacheReadTokenCount":     cachedTokens,
 		"totalTokenCount":         totalTokens,
 		"model":                   model,
-		"transactionId":           generateRequestID(),
+		"transactionId":           newTransactionID(),
 		"responseTime":            responseTimeISO,
 		"requestDuration":         requestDuration,
 		"provider":                provider,
@@ -748,98 +859,6 @@ func buildGoogleMeteringPayloadWithTimingAndVision(
 	return payload
 }
 
-// sendMeteringWithRetry sends metering data with exponential backoff retry
-func sendMeteringWithRetry(config *Config, payload map[string]interface{}) error {
-	const maxRetries = 3
-	const initialBackoff = 100 * time.Millisecond
-
-	var lastErr error
-	backoff := initialBackoff
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			time.Sleep(backoff)
-			backoff *= 2 // Exponential backoff
-		}
-
-		err := sendMeteringRequest(config, payload)
-		if err == nil {
-			return nil // Success
-		}
-
-		lastErr = err
-
-		// Don't retry on validation errors
-		if IsValidationError(err) {
-			return err
-		}
-	}
-
-	return NewMeteringError("metering failed after retries", fmt.Errorf("retries: %d, last error: %w", maxRetries, lastErr))
-}
-
-// sendMeteringRequest sends a single metering request to Revenium API
-func sendMeteringRequest(config *Config, payload map[string]interface{}) error {
-	if config == nil || config.ReveniumAPIKey == "" {
-		return NewConfigError("metering not configured", nil)
-	}
-
-	url := config.ReveniumBaseURL + meteringEndpoint
-
-	// Marshal payload to JSON
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return NewMeteringError("failed to marshal metering payload", err)
-	}
-
-	// Log the exact payload being sent
-	Debug("[METERING] Sending payload to %s: %s", url, string(jsonData))
-
-	// Create HTTP request
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return NewMeteringError("failed to create metering request", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-	req.Header.Set("x-api-key", config.ReveniumAPIKey)
-	req.Header.Set("User-Agent", GetUserAgent())
-
-	// Send request with timeout
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		Error("[METERING] Network error: %v", err)
-		return NewNetworkError("metering request failed", err)
-	}
-	defer resp.Body.Close()
-
-	// Read response body for error details
-	body, _ := io.ReadAll(resp.Body)
-
-	Debug("[METERING] Response status: %d, body: %s", resp.StatusCode, string(body))
-
-	// Check response status
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		// Log response for debugging
-		Error("[METERING] API error response (status %d): %s", resp.StatusCode, string(body))
-		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-			// Validation error - don't retry
-			return NewValidationError(
-				fmt.Sprintf("metering API returned %d: %s", resp.StatusCode, string(body)),
-				nil,
-			)
-		}
-		return NewMeteringError("metering API error", fmt.Errorf("status %d: %s", resp.StatusCode, string(body)))
-	}
-
-	Debug("[METERING] Successfully sent metering data (status %d)", resp.StatusCode)
-	return nil
-}
-
 // Reset resets the global middleware state for testing
 func Reset() {
 	globalMu.Lock()
@@ -0,0 +1,60 @@
+package revenium
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewReveniumGoogle_PerClientState(t *testing.T) {
+	var bufA, bufB bytes.Buffer
+	loggerA := slog.New(slog.NewTextHandler(&bufA, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	// A custom slog logger is filtered by its handler, not by Config.Debug
+	loggerB := slog.New(slog.NewTextHandler(&bufB, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	clientA, err := NewReveniumGoogle(&Config{
+		ReveniumAPIKey: "hak_tenant_a",
+		GoogleAPIKey:   "google-key-a",
+		Debug:          true,
+		Logger:         loggerA,
+	})
+	if err != nil {
+		t.Fatalf("client A: %v", err)
+	}
+	clientB, err := NewReveniumGoogle(&Config{
+		ReveniumAPIKey: "hak_tenant_b",
+		GoogleAPIKey:   "google-key-b",
+		Logger:         loggerB,
+	})
+	if err != nil {
+		t.Fatalf("client B: %v", err)
+	}
+
+	clientA.logger.Debug("tenant A debug")
+	clientB.logger.Debug("tenant B debug")
+	clientB.logger.Info("tenant B info")
+
+	if !strings.Contains(bufA.String(), "tenant A debug") {
+		t.Errorf("client A debug output missing: %q", bufA.String())
+	}
+	if strings.Contains(bufA.String(), "tenant B") {
+		t.Errorf("client B output leaked into client A logger: %q", bufA.String())
+	}
+	if strings.Contains(bufB.String(), "tenant B debug") {
+		t.Errorf("client B's handler filters debug but debug output was logged: %q", bufB.String())
+	}
+	if !strings.Contains(bufB.String(), "tenant B info") {
+		t.Errorf("client B info output missing: %q", bufB.String())
+	}
+
+	if clientA.metering == clientB.metering || clientA.metering.httpClient == clientB.metering.httpClient {
+		t.Error("clients must not share a metering transport or HTTP client")
+	}
+	if clientA.metering.currentConfig().ReveniumAPIKey != "hak_tenant_a" || clientB.metering.currentConfig().ReveniumAPIKey != "hak_tenant_b" {
+		t.Error("metering transports must carry their own client's API key")
+	}
+	if getGlobalDebugFlag() {
+		t.Error("creating a client must not change the global debug flag")
+	}
+}
@@ -0,0 +1,282 @@
+package revenium
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// capturedMessage is the structured capture format for one conversation turn in inputMessages.
+// Content holds the joined text parts so readers that only understand {role, content} keep working;
+// the remaining fields preserve non-text parts with their types.
+type capturedMessage struct {
+	Role        string               `json:"role"`
+	Content     string               `json:"content"`
+	ToolCalls   []capturedToolCall   `json:"toolCalls,omitempty"`
+	ToolResults []capturedToolResult `json:"toolResults,omitempty"`
+	Media       []capturedMedia      `json:"media,omitempty"`
+	Code        []capturedCode       `json:"executableCode,omitempty"`
+	CodeResults []capturedCodeResult `json:"codeExecutionResults,omitempty"`
+	Thoughts    string               `json:"thoughts,omitempty"`
+}
+
+// capturedToolCall is a function call requested by the model
+type capturedToolCall struct {
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// capturedToolResult is the result of a function call sent back to the model
+type capturedToolResult struct {
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name"`
+	Response string `json:"response,omitempty"`
+}
+
+// capturedMedia is a placeholder for inline or URI-based media; the bytes are never captured
+type capturedMedia struct {
+	Type        string `json:"type"`
+	MIMEType    string `json:"mimeType,omitempty"`
+	SizeBytes   int    `json:"sizeBytes,omitempty"`
+	FileURI     string `json:"fileUri,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+// capturedCode is code generated by the model for the code execution tool
+type capturedCode struct {
+	Language string `json:"language,omitempty"`
+	Code     string `json:"code"`
+}
+
+// capturedCodeResult is the output of executing model-generated code
+type capturedCodeResult struct {
+	Outcome string `json:"outcome,omitempty"`
+	Output  string `json:"output,omitempty"`
+}
+
+const (
+	mediaTypeInlineData = "inline_data"
+	mediaTypeFileData   = "file_data"
+)
+
+// buildCapturedMessage converts a genai Content into the structured capture format
+func buildCapturedMessage(content *genai.Content) capturedMessage {
+	role := content.Role
+	if role == "" {
+		role = "user" // Default to user if not specified
+	}
+
+	msg := capturedMessage{Role: role}
+	var texts, thoughts []string
+
+	for _, part := range content.Parts {
+		if part == nil {
+			continue
+		}
+
+		if part.Text != "" {
+			if part.Thought {
+				thoughts = append(thoughts, part.Text)
+			} else {
+				texts = append(texts, part.Text)
+			}
+		}
+		if part.FunctionCall != nil {
+			msg.ToolCalls = append(msg.ToolCalls, capturedToolCall{
+				ID:        part.FunctionCall.ID,
+				Name:      part.FunctionCall.Name,
+				Arguments: marshalCaptureValue(part.FunctionCall.Args),
+			})
+		}
+		if part.FunctionResponse != nil {
+			msg.ToolResults = append(msg.ToolResults, capturedToolResult{
+				ID:       part.FunctionResponse.ID,
+				Name:     part.FunctionResponse.Name,
+				Response: marshalCaptureValue(part.FunctionResponse.Response),
+			})
+		}
+		if part.InlineData != nil {
+			msg.Media = append(msg.Media, capturedMedia{
+				Type:        mediaTypeInlineData,
+				MIMEType:    part.InlineData.MIMEType,
+				SizeBytes:   len(part.InlineData.Data),
+				DisplayName: part.InlineData.DisplayName,
+			})
+		}
+		if part.FileData != nil {
+			msg.Media = append(msg.Media, capturedMedia{
+				Type:        mediaTypeFileData,
+				MIMEType:    part.FileData.MIMEType,
+				FileURI:     part.FileData.FileURI,
+				DisplayName: part.FileData.DisplayName,
+			})
+		}
+		if part.ExecutableCode != nil {
+			msg.Code = append(msg.Code, capturedCode{
+				Language: string(part.ExecutableCode.Language),
+				Code:     part.ExecutableCode.Code,
+			})
+		}
+		if part.CodeExecutionResult != nil {
+			msg.CodeResults = append(msg.CodeResults, capturedCodeResult{
+				Outcome: string(part.CodeExecutionResult.Outcome),
+				Output:  part.CodeExecutionResult.Output,
+			})
+		}
+	}
+
+	msg.Content = strings.Join(texts, "\n")
+	msg.Thoughts = strings.Join(thoughts, "\n")
+	return msg
+}
+
+// textFields returns pointers to every free-text field of the message, in order,
+// so the truncation budget can be applied to all of them
+func (m *capturedMessage) textFields() []*string {
+	fields := []*string{&m.Content}
+	for i := range m.ToolCalls {
+		fields = append(fields, &m.ToolCalls[i].Arguments)
+	}
+	for i := range m.ToolResults {
+		fields = append(fields, &m.ToolResults[i].Response)
+	}
+	for i := range m.Code {
+		fields = append(fields, &m.Code[i].Code)
+	}
+	for i := range m.CodeResults {
+		fields = append(fields, &m.CodeResults[i].Output)
+	}
+	fields = append(fields, &m.Thoughts)
+	return fields
+}
+
+// captureSize returns the number of text bytes the message contributes to the budget
+func (m *capturedMessage) captureSize() int {
+	size := 0
+	for _, field := range m.textFields() {
+		size += len(*field)
+	}
+	return size
+}
+
+// truncateTo shrinks the message's text fields to fit maxBytes in total, sharing the
+// allowance fairly between fields. A field whose share cannot hold more than the
+// truncation marker is emptied, starting from the last, and its share goes to the
+// others. It reports whether anything was cut.
+func (m *capturedMessage) truncateTo(maxBytes int, strategy TruncationStrategy) bool {
+	fields := m.textFields()
+	lengths := make([]int, len(fields))
+	for i, field := range fields {
+		lengths[i] = len(*field)
+	}
+
+	truncated := false
+	allocations := allocateMessageBudget(lengths, maxBytes, TruncateHead)
+	for dropped := lastMarkerOnlyField(lengths, allocations); dropped >= 0; dropped = lastMarkerOnlyField(lengths, allocations) {
+		*fields[dropped] = ""
+		lengths[dropped] = 0
+		truncated = true
+		allocations = allocateMessageBudget(lengths, maxBytes, TruncateHead)
+	}
+	for i, field := range fields {
+		value, wasTruncated := truncateWithStrategy(*field, allocations[i], strategy)
+		if wasTruncated {
+			*field = value
+			truncated = true
+		}
+	}
+	return truncated
+}
+
+// lastMarkerOnlyField returns the index of the last field that must be cut to an
+// allocation too small to keep any content next to the marker, or -1
+func lastMarkerOnlyField(lengths, allocations []int) int {
+	for i := len(lengths) - 1; i >= 0; i-- {
+		if lengths[i] > allocations[i] && allocations[i] <= len(TruncationMarker) {
+			return i
+		}
+	}
+	return -1
+}
+
+// marshalCaptureValue serializes a structured tool argument or result for capture
+func marshalCaptureValue(value map[string]any) string {
+	if len(value) == 0 {
+		return ""
+	}
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(jsonBytes)
+}
+
+// renderResponseForCapture renders the first candidate of a response for outputResponse.
+// Text parts are concatenated as returned by the model; non-text parts are rendered as
+// bracketed placeholders on their own line. Thought parts are returned separately.
+func renderResponseForCapture(resp *genai.GenerateContentResponse) (output string, thoughts string) {
+	if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0] == nil || resp.Candidates[0].Content == nil {
+		return "", ""
+	}
+
+	var out, thought strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if part == nil {
+			continue
+		}
+
+		if part.Text != "" {
+			if part.Thought {
+				thought.WriteString(part.Text)
+			} else {
+				out.WriteString(part.Text)
+			}
+		}
+		if part.FunctionCall != nil {
+			writePlaceholder(&out, fmt.Sprintf("[function_call%s name=%s args=%s]",
+				idAttr(part.FunctionCall.ID), part.FunctionCall.Name, marshalCaptureValue(part.FunctionCall.Args)))
+		}
+		if part.FunctionResponse != nil {
+			writePlaceholder(&out, fmt.Sprintf("[function_response%s name=%s response=%s]",
+				idAttr(part.FunctionResponse.ID), part.FunctionResponse.Name, marshalCaptureValue(part.FunctionResponse.Response)))
+		}
+		if part.InlineData != nil {
+			writePlaceholder(&out, fmt.Sprintf("[%s mimeType=%s sizeBytes=%d]",
+				mediaTypeInlineData, part.InlineData.MIMEType, len(part.InlineData.Data)))
+		}
+		if part.FileData != nil {
+			writePlaceholder(&out, fmt.Sprintf("[%s mimeType=%s uri=%s]",
+				mediaTypeFileData, part.FileData.MIMEType, part.FileData.FileURI))
+		}
+		if part.ExecutableCode != nil {
+			writePlaceholder(&out, fmt.Sprintf("[executable_code language=%s]\n%s",
+				part.ExecutableCode.Language, part.ExecutableCode.Code))
+		}
+		if part.CodeExecutionResult != nil {
+			writePlaceholder(&out, fmt.Sprintf("[code_execution_result outcome=%s]\n%s",
+				part.CodeExecutionResult.Outcome, part.CodeExecutionResult.Output))
+		}
+	}
+
+	return out.String(), thought.String()
+}
+
+// writePlaceholder appends a placeholder on its own line
+func writePlaceholder(b *strings.Builder, placeholder string) {
+	if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString(placeholder)
+	b.WriteString("\n")
+}
+
+// idAttr formats an optional id attribute for a placeholder
+func idAttr(id string) string {
+	if id == "" {
+		return ""
+	}
+	return " id=" + id
+}
@@ -0,0 +1,183 @@
+package revenium
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestExtractPromptsFromRequest_NonTextParts(t *testing.T) {
+	contents := []*genai.Content{
+		{
+			Role: "user",
+			Parts: []*genai.Part{
+				{Text: "What's the weather in Paris? See the photo."},
+				{InlineData: &genai.Blob{MIMEType: "image/png", Data: make([]byte, 1024)}},
+				{FileData: &genai.FileData{MIMEType: "application/pdf", FileURI: "gs://bucket/report.pdf"}},
+			},
+		},
+		{
+			Role: "model",
+			Parts: []*genai.Part{
+				{Text: "Planning the lookup", Thought: true},
+				{FunctionCall: &genai.FunctionCall{ID: "call-1", Name: "get_weather", Args: map[string]any{"city": "Paris"}}},
+			},
+		},
+		{
+			Role: "user",
+			Parts: []*genai.Part{
+				{FunctionResponse: &genai.FunctionResponse{ID: "call-1", Name: "get_weather", Response: map[string]any{"tempC": 21}}},
+			},
+		},
+	}
+
+	result := ExtractPromptsFromRequest(contents, nil)
+
+	var messages []capturedMessage
+	if err := json.Unmarshal([]byte(result.InputMessages), &messages); err != nil {
+		t.Fatalf("input messages are not valid JSON: %v", err)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(messages))
+	}
+
+	media := messages[0].Media
+	if len(media) != 2 || media[0].Type != mediaTypeInlineData || media[0].MIMEType != "image/png" || media[0].SizeBytes != 1024 {
+		t.Errorf("unexpected inline media placeholder: %+v", media)
+	}
+	if len(media) == 2 && (media[1].Type != mediaTypeFileData || media[1].FileURI != "gs://bucket/report.pdf") {
+		t.Errorf("unexpected file media placeholder: %+v", media[1])
+	}
+	if strings.Contains(result.InputMessages, "AAAA") {
+		t.Error("inline media bytes must not be captured")
+	}
+
+	model := messages[1]
+	if model.Content != "" || model.Thoughts != "Planning the lookup" {
+		t.Errorf("thoughts should be kept apart from content: %+v", model)
+	}
+	if len(model.ToolCalls) != 1 || model.ToolCalls[0].Name != "get_weather" || model.ToolCalls[0].Arguments != `{"city":"Paris"}` {
+		t.Errorf("unexpected tool calls: %+v", model.ToolCalls)
+	}
+
+	results := messages[2].ToolResults
+	if len(results) != 1 || results[0].ID != "call-1" || results[0].Response != `{"tempC":21}` {
+		t.Errorf("unexpected tool results: %+v", results)
+	}
+}
+
+func TestExtractResponseContent_NonTextParts(t *testing.T) {
+	resp := &genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{
+			{
+				Content: &genai.Content{
+					Role: "model",
+					Parts: []*genai.Part{
+						{Text: "Thinking about tools", Thought: true},
+						{Text: "Let me check."},
+						{FunctionCall: &genai.FunctionCall{Name: "get_weather", Args: map[string]any{"city": "Paris"}}},
+						{ExecutableCode: &genai.ExecutableCode{Language: genai.LanguagePython, Code: "print(1)"}},
+					},
+				},
+			},
+		},
+	}
+
+	result := ExtractResponseContent(resp, false)
+
+	if !strings.HasPrefix(result.OutputResponse, "Let me check.\n") {
+		t.Errorf("expected text first, got %q", result.OutputResponse)
+	}
+	if !strings.Contains(result.OutputResponse, `[function_call name=get_weather args={"city":"Paris"}]`) {
+		t.Errorf("expected function call placeholder, got %q", result.OutputResponse)
+	}
+	if !strings.Contains(result.OutputResponse, "[executable_code language=PYTHON]\nprint(1)") {
+		t.Errorf("expected executable code placeholder, got %q", result.OutputResponse)
+	}
+	if strings.Contains(result.OutputResponse, "Thinking about tools") {
+		t.Error("thoughts must not appear in the output response")
+	}
+	if result.ThoughtSummary != "Thinking about tools" {
+		t.Errorf("ThoughtSummary: got %q", result.ThoughtSummary)
+	}
+
+	payload := make(map[string]interface{})
+	AddPromptDataToPayload(payload, result)
+	attrs, ok := payload["attributes"].(map[string]interface{})
+	if !ok || attrs["thoughtSummary"] != "Thinking about tools" {
+		t.Errorf("thought summary not added to attributes: %v", payload["attributes"])
+	}
+}
+
+func TestCapturedMessageTruncation_ToolResults(t *testing.T) {
+	contents := []*genai.Content{
+		{
+			Role: "user",
+			Parts: []*genai.Part{
+				{FunctionResponse: &genai.FunctionResponse{Name: "search", Response: map[string]any{"doc": strings.Repeat("x", 5000)}}},
+			},
+		},
+		{Role: "user", Parts: []*genai.Part{{Text: "Summarize it"}}},
+	}
+
+	limits := PromptLimits{MaxInputMessagesLength: 500}
+	result := ExtractPromptsFromRequestWithLimits(contents, nil, limits)
+
+	if !result.PromptsTruncated {
+		t.Error("expected PromptsTruncated to be true")
+	}
+
+	var messages []capturedMessage
+	if err := json.Unmarshal([]byte(result.InputMessages), &messages); err != nil {
+		t.Fatalf("input messages are not valid JSON: %v", err)
+	}
+	if got := len(messages[0].ToolResults[0].Response); got > limits.MaxInputMessagesLength {
+		t.Errorf("tool result not truncated: %d bytes", got)
+	}
+	if messages[1].Content != "Summarize it" {
+		t.Errorf("latest turn should be kept whole, got %q", messages[1].Content)
+	}
+}
+
+func TestCapturedMessageTruncateTo_NoMarkerFragments(t *testing.T) {
+	newMessage := func(calls int) capturedMessage {
+		msg := capturedMessage{Role: "model", Content: strings.Repeat("c", 200)}
+		for i := 0; i < calls; i++ {
+			msg.ToolCalls = append(msg.ToolCalls, capturedToolCall{Name: "lookup", Arguments: strings.Repeat("a", 200)})
+		}
+		return msg
+	}
+
+	tests := []struct {
+		name        string
+		calls       int
+		maxBytes    int
+		wantContent bool
+	}{
+		{"shares too small for every field", 6, 60, true},
+		{"budget below the marker", 2, 5, false},
+		{"every field fits a share", 2, 150, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := newMessage(tt.calls)
+			if !msg.truncateTo(tt.maxBytes, TruncateHead) {
+				t.Fatal("expected the message to be truncated")
+			}
+			for _, field := range msg.textFields() {
+				if *field != "" && len(*field) <= len(TruncationMarker) {
+					t.Errorf("field %q holds only part of the marker", *field)
+				}
+			}
+			if size := msg.captureSize(); size > tt.maxBytes {
+				t.Errorf("captured %d bytes, budget %d", size, tt.maxBytes)
+			}
+			if got := strings.HasPrefix(msg.Content, "c"); got != tt.wantContent {
+				t.Errorf("content kept = %v, want %v (%q)", got, tt.wantContent, msg.Content)
+			}
+		})
+	}
+}
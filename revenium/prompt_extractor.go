@@ -42,8 +42,9 @@ func truncateUTF8Safe(s string, maxBytes int) string {
 }
 
 const (
-	// MaxPromptLength is the maximum length for captured prompts/responses
-	// Fields exceeding this limit will be truncated
+	// MaxPromptLength is the default maximum length for captured prompts/responses
+	// and the default total budget for input messages. Override it with
+	// Config.MaxPromptLength and Config.MaxInputMessagesLength.
 	MaxPromptLength = 50000
 
 	// TruncationMarker is appended to truncated content
@@ -54,70 +55,96 @@ const (
 type PromptData struct {
 	// SystemPrompt contains the system instruction content (if any)
 	SystemPrompt string
-	// InputMessages contains JSON-serialized user/model messages
+	// InputMessages contains JSON-serialized user/model messages, including tool calls,
+	// tool results and media placeholders
 	InputMessages string
-	// OutputResponse contains the model's response content
+	// OutputResponse contains the model's response content, with non-text parts
+	// rendered as bracketed placeholders
 	OutputResponse string
+	// ThoughtSummary contains the model's thought summaries, kept apart from the response
+	ThoughtSummary string
 	// PromptsTruncated indicates if any field was truncated
 	PromptsTruncated bool
 }
 
 // ExtractPromptsFromRequest extracts system prompt and input messages from Google AI request
+// using the default truncation limits
 func ExtractPromptsFromRequest(contents []*genai.Content, config *genai.GenerateContentConfig) PromptData {
+	return ExtractPromptsFromRequestWithLimits(contents, config, DefaultPromptLimits())
+}
+
+// ExtractPromptsFromRequestWithLimits extracts system prompt and input messages from Google AI request.
+// The system prompt is capped at limits.MaxFieldLength. Input messages share a total budget of
+// limits.MaxInputMessagesLength bytes, distributed according to limits.Strategy.
+func ExtractPromptsFromRequestWithLimits(contents []*genai.Content, config *genai.GenerateContentConfig, limits PromptLimits) PromptData {
+	return extractPromptsFromRequest(contents, config, limits, GetLogger())
+}
+
+// extractPromptsFromRequest is ExtractPromptsFromRequestWithLimits logging to logger
+func extractPromptsFromRequest(contents []*genai.Content, config *genai.GenerateContentConfig, limits PromptLimits, logger Logger) PromptData {
 	data := PromptData{}
+	limits = limits.normalized()
 
 	// Extract system instruction from config if present
 	if config != nil && config.SystemInstruction != nil {
 		systemContent := extractContentText(config.SystemInstruction)
 		if systemContent != "" {
 			// Apply truncation if needed
-			if len(systemContent) > MaxPromptLength {
-				markerLen := len(TruncationMarker)
-				truncateAt := MaxPromptLength - markerLen
-				systemContent = truncateUTF8Safe(systemContent, truncateAt) + TruncationMarker
+			truncated, wasTruncated := truncateWithStrategy(systemContent, limits.MaxFieldLength, limits.Strategy)
+			if wasTruncated {
 				data.PromptsTruncated = true
-				Debug("System prompt truncated to %d characters", MaxPromptLength)
+				logger.Debug("System prompt truncated to %d bytes", limits.MaxFieldLength)
 			}
-			data.SystemPrompt = systemContent
+			data.SystemPrompt = truncated
 		}
 	}
 
 	// Extract input messages
 	if len(contents) > 0 {
-		var messages []map[string]interface{}
-		halfLimit := MaxPromptLength / 2
-		markerLen := len(TruncationMarker)
+		var captured []capturedMessage
+		var lengths []int
 
 		for _, content := range contents {
 			if content == nil {
 				continue
 			}
 
-			role := content.Role
-			if role == "" {
-				role = "user" // Default to user if not specified
+			msg := buildCapturedMessage(content)
+			captured = append(captured, msg)
+			lengths = append(lengths, msg.captureSize())
+		}
+
+		// The newest user turn is served first so a tight budget never drops it
+		latestUser := -1
+		for idx := len(captured) - 1; idx >= 0; idx-- {
+			if captured[idx].Role == "user" {
+				latestUser = idx
+				break
 			}
+		}
 
-			text := extractContentText(content)
-			messageMap := map[string]interface{}{
-				"role":    role,
-				"content": text,
+		// Spread the budget across messages before serialization so the JSON stays valid
+		allocations := allocateMessageBudgetKeeping(lengths, limits.MaxInputMessagesLength, limits.Strategy, latestUser)
+
+		var messages []capturedMessage
+		for idx := range captured {
+			// A message that must be cut but cannot hold the marker is dropped instead
+			if allocations[idx] < 0 || (idx != latestUser && allocations[idx] < lengths[idx] && allocations[idx] < len(TruncationMarker)) {
+				data.PromptsTruncated = true
+				continue
 			}
 
-			// Truncate individual message content if too long
-			if len(text) > halfLimit {
-				truncateAt := halfLimit - markerLen
-				messageMap["content"] = truncateUTF8Safe(text, truncateAt) + TruncationMarker
+			if captured[idx].truncateTo(allocations[idx], limits.Strategy) {
 				data.PromptsTruncated = true
 			}
 
-			messages = append(messages, messageMap)
+			messages = append(messages, captured[idx])
 		}
 
 		if len(messages) > 0 {
 			jsonBytes, err := json.Marshal(messages)
 			if err != nil {
-				Warn("Failed to serialize input messages to JSON: %v", err)
+				logger.Warn("Failed to serialize input messages to JSON: %v", err)
 			} else {
 				// Note: Individual messages are already truncated above.
 				// We avoid truncating the final JSON to prevent invalid JSON.
@@ -129,7 +156,7 @@ func ExtractPromptsFromRequest(contents []*genai.Content, config *genai.Generate
 	return data
 }
 
-// extractContentText extracts all text from a Content object
+// extractContentText extracts all non-thought text from a Content object
 func extractContentText(content *genai.Content) string {
 	if content == nil || len(content.Parts) == 0 {
 		return ""
@@ -137,7 +164,7 @@ func extractContentText(content *genai.Content) string {
 
 	var result string
 	for _, part := range content.Parts {
-		if part == nil {
+		if part == nil || part.Thought {
 			continue
 		}
 		if part.Text != "" {
@@ -151,37 +178,40 @@ func extractContentText(content *genai.Content) string {
 }
 
 // ExtractResponseContent extracts output response from Google AI response
+// using the default truncation limits
 func ExtractResponseContent(resp *genai.GenerateContentResponse, promptsTruncated bool) PromptData {
-	data := PromptData{
-		PromptsTruncated: promptsTruncated,
-	}
-
-	if resp == nil {
-		return data
-	}
-
-	// Use the Text() method to get the response content
-	content := resp.Text()
-
-	if content == "" {
-		return data
-	}
+	return ExtractResponseContentWithLimits(resp, promptsTruncated, DefaultPromptLimits())
+}
 
-	// Apply truncation if needed
-	if len(content) > MaxPromptLength {
-		markerLen := len(TruncationMarker)
-		truncateAt := MaxPromptLength - markerLen
-		content = truncateUTF8Safe(content, truncateAt) + TruncationMarker
-		data.PromptsTruncated = true
-		Debug("Output response truncated to %d characters", MaxPromptLength)
-	}
+// ExtractResponseContentWithLimits extracts output response and thought summary from Google AI
+// response, capping each at limits.MaxFieldLength
+func ExtractResponseContentWithLimits(resp *genai.GenerateContentResponse, promptsTruncated bool, limits PromptLimits) PromptData {
+	return extractResponseContent(resp, promptsTruncated, limits, GetLogger())
+}
 
-	data.OutputResponse = content
+// extractResponseContent is ExtractResponseContentWithLimits logging to logger
+func extractResponseContent(resp *genai.GenerateContentResponse, promptsTruncated bool, limits PromptLimits, logger Logger) PromptData {
+	output, thoughts := renderResponseForCapture(resp)
+	data := extractStreamingResponseContent(output, promptsTruncated, limits, logger)
+	data.ThoughtSummary, data.PromptsTruncated = captureThoughtSummary(thoughts, data.PromptsTruncated, limits)
 	return data
 }
 
 // ExtractStreamingResponseContent extracts output from accumulated streaming content
+// using the default truncation limits
 func ExtractStreamingResponseContent(accumulatedContent string, promptsTruncated bool) PromptData {
+	return ExtractStreamingResponseContentWithLimits(accumulatedContent, promptsTruncated, DefaultPromptLimits())
+}
+
+// ExtractStreamingResponseContentWithLimits extracts output from accumulated streaming content,
+// capping it at limits.MaxFieldLength
+func ExtractStreamingResponseContentWithLimits(accumulatedContent string, promptsTruncated bool, limits PromptLimits) PromptData {
+	return extractStreamingResponseContent(accumulatedContent, promptsTruncated, limits, GetLogger())
+}
+
+// extractStreamingResponseContent is ExtractStreamingResponseContentWithLimits logging
+// to logger
+func extractStreamingResponseContent(accumulatedContent string, promptsTruncated bool, limits PromptLimits, logger Logger) PromptData {
 	data := PromptData{
 		PromptsTruncated: promptsTruncated,
 	}
@@ -190,21 +220,30 @@ func ExtractStreamingResponseContent(accumulatedContent string, promptsTruncated
 		return data
 	}
 
-	content := accumulatedContent
+	limits = limits.normalized()
 
 	// Apply truncation if needed
-	if len(content) > MaxPromptLength {
-		markerLen := len(TruncationMarker)
-		truncateAt := MaxPromptLength - markerLen
-		content = truncateUTF8Safe(content, truncateAt) + TruncationMarker
+	content, wasTruncated := truncateWithStrategy(accumulatedContent, limits.MaxFieldLength, limits.Strategy)
+	if wasTruncated {
 		data.PromptsTruncated = true
-		Debug("Streaming output response truncated to %d characters", MaxPromptLength)
+		logger.Debug("Output response truncated to %d bytes", limits.MaxFieldLength)
 	}
 
 	data.OutputResponse = content
 	return data
 }
 
+// captureThoughtSummary truncates accumulated thought text for capture
+func captureThoughtSummary(thoughts string, promptsTruncated bool, limits PromptLimits) (string, bool) {
+	if thoughts == "" {
+		return "", promptsTruncated
+	}
+
+	limits = limits.normalized()
+	summary, wasTruncated := truncateWithStrategy(thoughts, limits.MaxFieldLength, limits.Strategy)
+	return summary, promptsTruncated || wasTruncated
+}
+
 // AddPromptDataToPayload adds prompt capture fields to a metering payload
 func AddPromptDataToPayload(payload map[string]interface{}, data PromptData) {
 	if data.SystemPrompt != "" {
@@ -219,4 +258,12 @@ func AddPromptDataToPayload(payload map[string]interface{}, data PromptData) {
 	if data.PromptsTruncated {
 		payload["promptsTruncated"] = true
 	}
+	if data.ThoughtSummary != "" {
+		// Thought summaries travel as an attribute so they stay separate from the response
+		if attrs, ok := payload["attributes"].(map[string]interface{}); ok {
+			attrs["thoughtSummary"] = data.ThoughtSummary
+		} else {
+			payload["attributes"] = map[string]interface{}{"thoughtSummary": data.ThoughtSummary}
+		}
+	}
 }
@@ -1,6 +1,8 @@
 package revenium
 
 import (
+	"bytes"
+	"log/slog"
 	"strings"
 	"testing"
 
@@ -460,10 +462,32 @@ func TestSystemPromptTruncation(t *testing.T) {
 	}
 }
 
+func TestExtractPrompts_LogsToClientLogger(t *testing.T) {
+	var clientBuf, globalBuf bytes.Buffer
+	previous := GetLogger()
+	SetLogger(NewSlogLogger(slog.New(slog.NewTextHandler(&globalBuf, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+	defer SetLogger(previous)
+	clientLogger := NewSlogLogger(slog.New(slog.NewTextHandler(&clientBuf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	config := &genai.GenerateContentConfig{
+		SystemInstruction: &genai.Content{Parts: []*genai.Part{{Text: strings.Repeat("s", MaxPromptLength+500)}}},
+	}
+	extractPromptsFromRequest(nil, config, DefaultPromptLimits(), clientLogger)
+	extractStreamingResponseContent(strings.Repeat("o", MaxPromptLength+500), false, DefaultPromptLimits(), clientLogger)
+
+	for _, want := range []string{"System prompt truncated", "Output response truncated"} {
+		if !strings.Contains(clientBuf.String(), want) {
+			t.Errorf("client logger missing %q: %q", want, clientBuf.String())
+		}
+	}
+	if globalBuf.Len() != 0 {
+		t.Errorf("global logger should stay quiet, got %q", globalBuf.String())
+	}
+}
+
 func TestInputMessagesTruncation(t *testing.T) {
-	// Create a message with content longer than halfLimit
-	halfLimit := MaxPromptLength / 2
-	longContent := strings.Repeat("m", halfLimit+500)
+	// Create a message with content longer than the default input message budget
+	longContent := strings.Repeat("m", MaxPromptLength+500)
 
 	contents := []*genai.Content{
 		{
@@ -1,5 +1,7 @@
 package revenium
 
+import "google.golang.org/genai"
+
 type Provider string
 
 const (
@@ -12,6 +14,14 @@ func DetectProvider(cfg *Config) Provider {
 		return ProviderGoogleAI
 	}
 
+	// A wrapped client or an explicit backend decides
+	if cfg.GenaiClient != nil {
+		return providerForBackend(cfg.GenaiClient.ClientConfig().Backend)
+	}
+	if cfg.GenaiClientConfig != nil && cfg.GenaiClientConfig.Backend != genai.BackendUnspecified {
+		return providerForBackend(cfg.GenaiClientConfig.Backend)
+	}
+
 	// If Vertex is explicitly disabled, use Google AI
 	if cfg.VertexDisabled {
 		return ProviderGoogleAI
@@ -25,6 +35,14 @@ func DetectProvider(cfg *Config) Provider {
 	return ProviderGoogleAI
 }
 
+// providerForBackend maps a genai backend to the provider reported in metering
+func providerForBackend(backend genai.Backend) Provider {
+	if backend == genai.BackendVertexAI {
+		return ProviderVertexAI
+	}
+	return ProviderGoogleAI
+}
+
 func (p Provider) IsGoogleAI() bool {
 	return p == ProviderGoogleAI
 }
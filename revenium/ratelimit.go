@@ -0,0 +1,317 @@
+package revenium
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// RateLimit is a requests-per-minute and tokens-per-minute budget. Zero fields are
+// unlimited.
+type RateLimit struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+// isZero reports whether the limit is unlimited
+func (l RateLimit) isZero() bool {
+	return l.RequestsPerMinute <= 0 && l.TokensPerMinute <= 0
+}
+
+// RateLimitConfig controls the client-side rate limiter in front of ModelsInterface
+// and ImagesInterface. Buckets are kept per model; organization limits are kept per
+// model and organizationId on top of the model limits. Token counts are estimated
+// before a call and corrected with the real usage afterwards.
+type RateLimitConfig struct {
+	// Models maps a model name to its limits
+	Models map[string]RateLimit
+	// Default applies to models without an entry in Models
+	Default RateLimit
+	// PerOrganization applies to each organizationId in the usage metadata
+	PerOrganization RateLimit
+	// Organizations overrides PerOrganization for specific organizationIds
+	Organizations map[string]RateLimit
+	// FailFast returns a rate limit error instead of waiting for capacity
+	FailFast bool
+}
+
+// enabled reports whether any limit is configured
+func (c RateLimitConfig) enabled() bool {
+	if !c.Default.isZero() || !c.PerOrganization.isZero() {
+		return true
+	}
+	for _, limit := range c.Models {
+		if !limit.isZero() {
+			return true
+		}
+	}
+	for _, limit := range c.Organizations {
+		if !limit.isZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// modelLimit returns the limits for model
+func (c RateLimitConfig) modelLimit(model string) RateLimit {
+	if limit, ok := c.Models[model]; ok {
+		return limit
+	}
+	return c.Default
+}
+
+// organizationLimit returns the limits for organizationID
+func (c RateLimitConfig) organizationLimit(organizationID string) RateLimit {
+	if organizationID == "" {
+		return RateLimit{}
+	}
+	if limit, ok := c.Organizations[organizationID]; ok {
+		return limit
+	}
+	return c.PerOrganization
+}
+
+// validationProblems describes every invalid field
+func (c RateLimitConfig) validationProblems() []string {
+	var problems []string
+	check := func(name string, limit RateLimit) {
+		if limit.RequestsPerMinute < 0 || limit.TokensPerMinute < 0 {
+			problems = append(problems, fmt.Sprintf("RateLimits.%s must not be negative", name))
+		}
+	}
+	check("Default", c.Default)
+	check("PerOrganization", c.PerOrganization)
+	for model, limit := range c.Models {
+		check(fmt.Sprintf("Models[%q]", model), limit)
+	}
+	for organizationID, limit := range c.Organizations {
+		check(fmt.Sprintf("Organizations[%q]", organizationID), limit)
+	}
+	return problems
+}
+
+// WithRateLimits enables the client-side rate limiter
+func WithRateLimits(limits RateLimitConfig) Option {
+	return func(c *Config) {
+		c.RateLimits = limits
+	}
+}
+
+// rateBucketSweepInterval is how often idle buckets are evicted
+const rateBucketSweepInterval = time.Minute
+
+// bucket is a token bucket refilled continuously up to one minute's budget
+type bucket struct {
+	capacity float64
+	level    float64
+	updated  time.Time
+}
+
+// refill brings the bucket up to date for perMinute at now. A changed limit (after a
+// configuration reload) takes effect immediately.
+func (b *bucket) refill(perMinute int, now time.Time) {
+	capacity := float64(perMinute)
+	if b.capacity != capacity {
+		b.level = math.Min(b.level+capacity-b.capacity, capacity)
+		b.capacity = capacity
+	}
+	b.level = math.Min(b.capacity, b.level+now.Sub(b.updated).Minutes()*b.capacity)
+	b.updated = now
+}
+
+// fullAt reports whether the bucket has refilled to its whole budget by now, so it
+// holds no state a new bucket would not have
+func (b *bucket) fullAt(now time.Time) bool {
+	return b.level+now.Sub(b.updated).Minutes()*b.capacity >= b.capacity
+}
+
+// wait returns how long until amount is available. Requests larger than the whole
+// budget wait for a full bucket instead of forever.
+func (b *bucket) wait(amount float64) time.Duration {
+	amount = math.Min(amount, b.capacity)
+	if b.level >= amount {
+		return 0
+	}
+	return time.Duration((amount - b.level) / b.capacity * float64(time.Minute))
+}
+
+// bucketUse is one bucket a call draws from, with its per-minute budget
+type bucketUse struct {
+	key       string
+	perMinute int
+	amount    float64
+	tokens    bool
+}
+
+// rateLimiter holds the buckets of one client. Buckets are created on first use, one
+// per model and organization, and evicted once they are idle and full again, so
+// short-lived organizationIds do not accumulate.
+type rateLimiter struct {
+	config func() *Config
+	now    func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// newRateLimiter creates a limiter that reads its limits from config on every call
+func newRateLimiter(config func() *Config) *rateLimiter {
+	return &rateLimiter{config: config, now: time.Now, buckets: map[string]*bucket{}}
+}
+
+// rateReservation is the capacity taken by one call
+type rateReservation struct {
+	limiter   *rateLimiter
+	uses      []bucketUse
+	estimated int64
+}
+
+// settle corrects the token buckets with the real token count of the call
+func (r *rateReservation) settle(actualTokens int64) {
+	if r == nil || actualTokens == r.estimated {
+		return
+	}
+	r.limiter.mu.Lock()
+	defer r.limiter.mu.Unlock()
+	for _, use := range r.uses {
+		if !use.tokens {
+			continue
+		}
+		if b, ok := r.limiter.buckets[use.key]; ok {
+			b.level = math.Min(b.capacity, b.level+float64(r.estimated-actualTokens))
+		}
+	}
+}
+
+// acquire waits until the call fits every bucket for model and the organizationId
+// in ctx's usage metadata, then takes the capacity. The returned context carries the
+// wait as mediationLatency. When FailFast is set, or the wait would outlast ctx, a
+// rate limit error is returned instead.
+func (l *rateLimiter) acquire(ctx context.Context, model string, estimatedTokens int64) (context.Context, *rateReservation, error) {
+	config := l.config()
+	if config == nil || !config.RateLimits.enabled() {
+		return ctx, nil, nil
+	}
+	limits := config.RateLimits
+
+	organizationID, _ := GetUsageMetadata(ctx)["organizationId"].(string)
+	uses := rateBucketUses("model:"+model, limits.modelLimit(model), estimatedTokens)
+	uses = append(uses, rateBucketUses("organization:"+model+":"+organizationID, limits.organizationLimit(organizationID), estimatedTokens)...)
+	if len(uses) == 0 {
+		return ctx, nil, nil
+	}
+
+	start := l.now()
+	for {
+		wait := l.tryTake(uses)
+		if wait == 0 {
+			break
+		}
+
+		rateErr := NewRateLimitError(fmt.Sprintf("rate limit reached for %s", model), nil).
+			WithDetails("model", model).
+			WithDetails(retryAfterDetail, wait)
+		if organizationID != "" {
+			rateErr.WithDetails("organizationId", organizationID)
+		}
+		if limits.FailFast {
+			return ctx, nil, rateErr
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return ctx, nil, rateErr
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			rateErr.Err = err
+			return ctx, nil, rateErr
+		}
+	}
+
+	reservation := &rateReservation{limiter: l, uses: uses, estimated: estimatedTokens}
+	if waited := l.now().Sub(start); waited >= time.Millisecond {
+		ctx = withMediationLatency(ctx, waited)
+	}
+	return ctx, reservation, nil
+}
+
+// tryTake takes the capacity when every bucket has it, or returns the longest wait
+func (l *rateLimiter) tryTake(uses []bucketUse) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.swept) >= rateBucketSweepInterval {
+		l.evictFullBuckets(now)
+	}
+
+	var longest time.Duration
+	for _, use := range uses {
+		b, ok := l.buckets[use.key]
+		if !ok {
+			b = &bucket{capacity: float64(use.perMinute), level: float64(use.perMinute), updated: now}
+			l.buckets[use.key] = b
+		}
+		b.refill(use.perMinute, now)
+		if wait := b.wait(use.amount); wait > longest {
+			longest = wait
+		}
+	}
+	if longest > 0 {
+		return longest
+	}
+
+	for _, use := range uses {
+		l.buckets[use.key].level -= use.amount
+	}
+	return 0
+}
+
+// evictFullBuckets removes the buckets that have refilled completely. Must be called
+// with l.mu held.
+func (l *rateLimiter) evictFullBuckets(now time.Time) {
+	for key, b := range l.buckets {
+		if b.fullAt(now) {
+			delete(l.buckets, key)
+		}
+	}
+	l.swept = now
+}
+
+// rateBucketUses lists the request and token buckets of one limit
+func rateBucketUses(key string, limit RateLimit, estimatedTokens int64) []bucketUse {
+	var uses []bucketUse
+	if limit.RequestsPerMinute > 0 {
+		uses = append(uses, bucketUse{key: key + ":rpm", perMinute: limit.RequestsPerMinute, amount: 1})
+	}
+	if limit.TokensPerMinute > 0 {
+		uses = append(uses, bucketUse{key: key + ":tpm", perMinute: limit.TokensPerMinute, amount: float64(estimatedTokens), tokens: true})
+	}
+	return uses
+}
+
+// withMediationLatency records the rate limiter wait, in milliseconds, in the usage
+// metadata carried by ctx
+func withMediationLatency(ctx context.Context, waited time.Duration) context.Context {
+	metadata := GetUsageMetadata(ctx)
+	withLatency := make(map[string]interface{}, len(metadata)+1)
+	for key, value := range metadata {
+		withLatency[key] = value
+	}
+	withLatency["mediationLatency"] = waited.Milliseconds()
+	return WithUsageMetadata(ctx, withLatency)
+}
+
+// estimateTokens estimates the tokens a GenerateContent call will use: the prompt
+// estimate plus MaxOutputTokens
+func estimateTokens(contents []*genai.Content, config *genai.GenerateContentConfig) int64 {
+	tokens := estimatePromptTokens(contents, config)
+	if config != nil {
+		tokens += int64(config.MaxOutputTokens)
+	}
+	return tokens
+}
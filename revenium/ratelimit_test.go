@@ -0,0 +1,165 @@
+package revenium
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// newTestRateLimiter returns a limiter with limits and a clock the test controls
+func newTestRateLimiter(limits RateLimitConfig) (*rateLimiter, *time.Time) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := &Config{RateLimits: limits}
+	limiter := newRateLimiter(func() *Config { return cfg })
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+func TestRateLimiter_FailFast(t *testing.T) {
+	limiter, now := newTestRateLimiter(RateLimitConfig{
+		Models:   map[string]RateLimit{"gemini-2.0-flash": {RequestsPerMinute: 2}},
+		FailFast: true,
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := limiter.acquire(ctx, "gemini-2.0-flash", 0); err != nil {
+			t.Fatalf("request %d within the limit failed: %v", i+1, err)
+		}
+	}
+
+	_, _, err := limiter.acquire(ctx, "gemini-2.0-flash", 0)
+	if !IsRateLimitError(err) {
+		t.Fatalf("expected a rate limit error, got %v", err)
+	}
+	var revErr *ReveniumError
+	errors.As(err, &revErr)
+	if revErr.GetDetails()[retryAfterDetail] != 30*time.Second {
+		t.Errorf("expected a 30s retry-after, got %v", revErr.GetDetails()[retryAfterDetail])
+	}
+	if revErr.GetStatusCode() != 429 {
+		t.Errorf("expected status 429, got %d", revErr.GetStatusCode())
+	}
+
+	if _, _, err := limiter.acquire(ctx, "other-model", 0); err != nil {
+		t.Errorf("models without limits should not be throttled: %v", err)
+	}
+
+	*now = now.Add(30 * time.Second)
+	if _, _, err := limiter.acquire(ctx, "gemini-2.0-flash", 0); err != nil {
+		t.Errorf("capacity should refill over time: %v", err)
+	}
+}
+
+func TestRateLimiter_TokenCorrection(t *testing.T) {
+	limiter, _ := newTestRateLimiter(RateLimitConfig{Default: RateLimit{TokensPerMinute: 100}, FailFast: true})
+	ctx := context.Background()
+
+	_, reservation, err := limiter.acquire(ctx, "gemini-2.0-flash", 80)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := limiter.acquire(ctx, "gemini-2.0-flash", 70); !IsRateLimitError(err) {
+		t.Fatalf("the estimate should hold the budget until corrected, got %v", err)
+	}
+
+	reservation.settle(20)
+	if _, _, err := limiter.acquire(ctx, "gemini-2.0-flash", 70); err != nil {
+		t.Errorf("the correction should return unused tokens: %v", err)
+	}
+}
+
+func TestRateLimiter_Organizations(t *testing.T) {
+	limiter, _ := newTestRateLimiter(RateLimitConfig{
+		PerOrganization: RateLimit{RequestsPerMinute: 1},
+		Organizations:   map[string]RateLimit{"org-vip": {RequestsPerMinute: 5}},
+		FailFast:        true,
+	})
+	org := func(id string) context.Context {
+		return WithUsageMetadata(context.Background(), map[string]interface{}{"organizationId": id})
+	}
+
+	if _, _, err := limiter.acquire(org("org-a"), "gemini-2.0-flash", 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := limiter.acquire(org("org-a"), "gemini-2.0-flash", 0); !IsRateLimitError(err) {
+		t.Errorf("org-a should be over its limit, got %v", err)
+	}
+	if _, _, err := limiter.acquire(org("org-b"), "gemini-2.0-flash", 0); err != nil {
+		t.Errorf("org-b has its own budget: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, _, err := limiter.acquire(org("org-vip"), "gemini-2.0-flash", 0); err != nil {
+			t.Errorf("org-vip request %d should use its override: %v", i+1, err)
+		}
+	}
+}
+
+func TestRateLimiter_EvictsIdleBuckets(t *testing.T) {
+	limiter, now := newTestRateLimiter(RateLimitConfig{PerOrganization: RateLimit{RequestsPerMinute: 1}, FailFast: true})
+	org := func(id string) context.Context {
+		return WithUsageMetadata(context.Background(), map[string]interface{}{"organizationId": id})
+	}
+	acquire := func(id string) {
+		t.Helper()
+		if _, _, err := limiter.acquire(org(id), "gemini-2.0-flash", 0); err != nil {
+			t.Fatalf("unexpected error for %s: %v", id, err)
+		}
+	}
+
+	acquire("org-first")
+	*now = now.Add(30 * time.Second)
+	for i := 0; i < 100; i++ {
+		acquire(fmt.Sprintf("org-%d", i))
+	}
+
+	// Half a minute after their last use the buckets are still refilling
+	*now = now.Add(30 * time.Second)
+	acquire("org-latest")
+	if len(limiter.buckets) != 101 {
+		t.Errorf("refilling buckets must be kept, got %d", len(limiter.buckets))
+	}
+
+	*now = now.Add(time.Minute)
+	acquire("org-latest")
+	if len(limiter.buckets) != 1 {
+		t.Errorf("idle full buckets should be evicted, %d left", len(limiter.buckets))
+	}
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	cfg := &Config{RateLimits: RateLimitConfig{Default: RateLimit{RequestsPerMinute: 6000}}}
+	limiter := newRateLimiter(func() *Config { return cfg })
+	limiter.tryTake([]bucketUse{{key: "model:gemini-2.0-flash:rpm", perMinute: 6000, amount: 6000}})
+
+	ctx, _, err := limiter.acquire(context.Background(), "gemini-2.0-flash", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if latency, _ := GetUsageMetadata(ctx)["mediationLatency"].(int64); latency < 1 {
+		t.Errorf("expected the wait as mediationLatency, got %v", GetUsageMetadata(ctx)["mediationLatency"])
+	}
+
+	limiter.tryTake([]bucketUse{{key: "model:gemini-2.0-flash:rpm", perMinute: 6000, amount: 6000}})
+	deadlineCtx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, _, err := limiter.acquire(deadlineCtx, "gemini-2.0-flash", 0); !IsRateLimitError(err) {
+		t.Errorf("a wait beyond the deadline should fail at once, got %v", err)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	contents := []*genai.Content{{Parts: []*genai.Part{
+		{Text: "12345678"},
+		{InlineData: &genai.Blob{MIMEType: "image/png", Data: []byte{1}}},
+	}}}
+	config := &genai.GenerateContentConfig{MaxOutputTokens: 100, SystemInstruction: genai.NewContentFromText("abcd", genai.RoleUser)}
+
+	if got := estimateTokens(contents, config); got != 3+imageTokenEstimate+100 {
+		t.Errorf("estimateTokens() = %d, want %d", got, 3+imageTokenEstimate+100)
+	}
+}
@@ -0,0 +1,179 @@
+package revenium
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+const meteringReceiptHookKey contextKey = "revenium_metering_receipt_hook"
+
+// MeteringReceipt describes one metering event produced by a call: what was metered
+// and, once delivery finishes, whether it was recorded. Fields are set before the
+// receipt is handed out and never change; the delivery outcome is reported through
+// Done, Err and Wait.
+type MeteringReceipt struct {
+	// TransactionID is the ID Revenium records the event under
+	TransactionID string
+	// Type is the kind of event (completion, image or video)
+	Type     MeteringEventType
+	Model    string
+	Provider string
+
+	// Token breakdown as metered (zero for image and video events)
+	InputTokens     int64
+	OutputTokens    int64
+	TotalTokens     int64
+	CachedTokens    int64
+	ReasoningTokens int64
+
+	// Duration is the request duration and TimeToFirstToken the delay until the
+	// first response chunk, both at millisecond precision as metered
+	Duration         time.Duration
+	TimeToFirstToken time.Duration
+
+	done chan struct{}
+	err  error
+}
+
+// newMeteringReceipt builds a receipt from a metering payload
+func newMeteringReceipt(event MeteringEvent) *MeteringReceipt {
+	receipt := &MeteringReceipt{
+		TransactionID: event.TransactionID(),
+		Type:          event.Type,
+		done:          make(chan struct{}),
+	}
+	receipt.Model, _ = event.Payload["model"].(string)
+	receipt.Provider, _ = event.Payload["provider"].(string)
+
+	receipt.InputTokens = payloadInt(event.Payload, "inputTokenCount")
+	receipt.OutputTokens = payloadInt(event.Payload, "outputTokenCount")
+	receipt.TotalTokens = payloadInt(event.Payload, "totalTokenCount")
+	receipt.CachedTokens = payloadInt(event.Payload, "cacheReadTokenCount")
+	receipt.ReasoningTokens = payloadInt(event.Payload, "reasoningTokenCount")
+	receipt.Duration = time.Duration(payloadInt(event.Payload, "requestDuration")) * time.Millisecond
+	receipt.TimeToFirstToken = time.Duration(payloadInt(event.Payload, "timeToFirstToken")) * time.Millisecond
+	return receipt
+}
+
+// payloadInt returns a numeric payload field as int64, or 0 when it is absent
+func payloadInt(payload map[string]interface{}, field string) int64 {
+	number, _ := payloadNumber(payload[field])
+	return int64(number)
+}
+
+// complete records the delivery outcome and releases waiters
+func (r *MeteringReceipt) complete(err error) {
+	r.err = err
+	close(r.done)
+}
+
+// Done returns a channel that is closed when delivery has finished
+func (r *MeteringReceipt) Done() <-chan struct{} {
+	return r.done
+}
+
+// Err returns the delivery error once Done is closed; nil means the metering sink
+// accepted the event. Before Done is closed it returns nil.
+func (r *MeteringReceipt) Err() error {
+	select {
+	case <-r.done:
+		return r.err
+	default:
+		return nil
+	}
+}
+
+// Wait blocks until delivery has finished or ctx is done, and returns the delivery
+// error or ctx.Err()
+func (r *MeteringReceipt) Wait(ctx context.Context) error {
+	select {
+	case <-r.done:
+		return r.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WithMeteringReceiptHook returns a context that makes every metering event produced
+// by calls made with it invoke hook with the event's receipt. The hook runs on the
+// metering goroutine just before delivery starts, so it must not block; use the
+// receipt's Done channel or Wait to learn the outcome.
+func WithMeteringReceiptHook(ctx context.Context, hook func(receipt *MeteringReceipt)) context.Context {
+	return context.WithValue(ctx, meteringReceiptHookKey, hook)
+}
+
+// failMeteringReceipt hands the receipt hook in ctx, if any, a receipt completed with
+// err for a completion event that could not be built, so its waiters are released
+func failMeteringReceipt(ctx context.Context, metadata map[string]interface{}, model string, err error) {
+	hook := meteringReceiptHook(ctx)
+	if hook == nil {
+		return
+	}
+	transactionID, _ := metadata["transactionId"].(string)
+	receipt := &MeteringReceipt{TransactionID: transactionID, Type: MeteringEventCompletion, Model: model, done: make(chan struct{})}
+	receipt.complete(err)
+	hook(receipt)
+}
+
+// meteringReceiptHook returns the hook set with WithMeteringReceiptHook, or nil
+func meteringReceiptHook(ctx context.Context) func(receipt *MeteringReceipt) {
+	hook, _ := ctx.Value(meteringReceiptHookKey).(func(receipt *MeteringReceipt))
+	return hook
+}
+
+// GenerateContentWithReceipt is GenerateContent that also returns the receipt of the
+// call's metering event. The receipt is returned for failed calls too, since they are
+// metered. Call Wait on it to confirm that billing was recorded. The receipt is nil
+// only if the call was rejected before reaching Google (for example by a fail-fast
+// rate limit) or ctx is done before the event has been built. An event that cannot
+// be built or delivered still yields a receipt, whose Wait returns the error. With
+// retries, it is the receipt of the last attempt.
+func (m *ModelsInterface) GenerateContentWithReceipt(
+	ctx context.Context,
+	model string,
+	contents []*genai.Content,
+	config *genai.GenerateContentConfig,
+) (*genai.GenerateContentResponse, *MeteringReceipt, error) {
+	// With retries every attempt is metered; the receipt returned is the last attempt's
+	var mu sync.Mutex
+	receipts := map[string]*MeteringReceipt{}
+	arrived := make(chan struct{}, 1)
+	hook := meteringReceiptHook(ctx)
+	ctx = WithMeteringReceiptHook(ctx, func(receipt *MeteringReceipt) {
+		if hook != nil {
+			hook(receipt)
+		}
+		mu.Lock()
+		receipts[receipt.TransactionID] = receipt
+		mu.Unlock()
+		select {
+		case arrived <- struct{}{}:
+		default:
+		}
+	})
+
+	resp, transactionID, err := m.generateWithRetries(ctx, model, contents, config)
+	if transactionID == "" {
+		// The call was rejected before any event was built
+		return resp, nil, err
+	}
+
+	// The event is built right after the call returns; delivery continues in the background
+	for {
+		mu.Lock()
+		receipt := receipts[transactionID]
+		mu.Unlock()
+		if receipt != nil {
+			return resp, receipt, err
+		}
+
+		select {
+		case <-arrived:
+		case <-ctx.Done():
+			return resp, nil, err
+		}
+	}
+}
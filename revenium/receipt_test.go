@@ -0,0 +1,31 @@
+package revenium
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMeteringGoroutinePanic_FailsReceipt(t *testing.T) {
+	// A client without a metering transport panics before the event reaches the hook
+	m := &ModelsInterface{provider: ProviderGoogleAI, logger: NewDefaultLogger(), parent: &ReveniumGoogle{}}
+
+	receipts := make(chan *MeteringReceipt, 1)
+	ctx := WithMeteringReceiptHook(context.Background(), func(receipt *MeteringReceipt) {
+		receipts <- receipt
+	})
+	metadata, transactionID := withTransactionID(nil)
+	m.sendMeteringDataWithPrompts(ctx, nil, "gemini-2.0-flash", metadata, false, time.Now(), time.Now(), time.Now(), nil, nil, VisionDetectionResult{}, nil, nil)
+
+	select {
+	case receipt := <-receipts:
+		if receipt.TransactionID != transactionID || receipt.Model != "gemini-2.0-flash" {
+			t.Errorf("unexpected receipt: %+v", receipt)
+		}
+		if err := receipt.Wait(context.Background()); err == nil {
+			t.Error("expected the receipt to report the failure")
+		}
+	default:
+		t.Fatal("the receipt hook was not called")
+	}
+}
@@ -0,0 +1,251 @@
+package revenium
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+const defaultReloadInterval = 30 * time.Second
+
+// ReveniumCredentials are the metering credentials returned by a CredentialProvider.
+// An empty BaseURL keeps the current endpoint.
+type ReveniumCredentials struct {
+	APIKey  string
+	BaseURL string
+}
+
+// CredentialProvider supplies the current Revenium metering credentials, for example
+// from a secret manager. It is polled by WatchCredentials.
+type CredentialProvider interface {
+	ReveniumCredentials(ctx context.Context) (ReveniumCredentials, error)
+}
+
+// CredentialProviderFunc adapts a function to the CredentialProvider interface
+type CredentialProviderFunc func(ctx context.Context) (ReveniumCredentials, error)
+
+// ReveniumCredentials calls f(ctx)
+func (f CredentialProviderFunc) ReveniumCredentials(ctx context.Context) (ReveniumCredentials, error) {
+	return f(ctx)
+}
+
+// UpdateConfig applies update to a copy of the current configuration, validates it and
+// swaps it in. The previous configuration is left untouched, so callers holding it see
+// a consistent snapshot.
+//
+// Every setting takes effect at runtime except the Google credentials and provider
+// settings, the transport metering flag, the log format, the logger, the metering sink,
+// dry-run mode and the metering HTTP client, proxy and TLS settings. Those are fixed
+// when the client is created; changes to them are ignored with a warning naming each
+// field. A changed shadow base URL or API key rebuilds the shadow route.
+//
+// Metering events already in flight are not dropped: they stay tracked by Flush and
+// Close, and their remaining delivery attempts use the new credentials and endpoint.
+func (r *ReveniumGoogle) UpdateConfig(update func(cfg *Config)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next := *r.config
+	update(&next)
+
+	if next.ReveniumBaseURL == "" {
+		next.ReveniumBaseURL = defaultReveniumBaseURL
+	}
+	next.ReveniumBaseURL = NormalizeReveniumBaseURL(next.ReveniumBaseURL)
+	r.keepFixedSettings(&next)
+
+	if err := next.Validate(); err != nil {
+		return err
+	}
+	if (next.Debug != r.config.Debug || next.LogLevel != r.config.LogLevel) && filteredByHandler(r.logger) {
+		r.logger.Warn("Debug and LogLevel do not filter a custom slog logger; change its handler's level instead")
+	}
+
+	r.config = &next
+	r.metering.setConfig(&next)
+	gateOf(r.logger).update(next.Debug, next.LogLevel)
+
+	r.logger.Info("Configuration reloaded")
+	return nil
+}
+
+// Reload replaces the configuration with cfg, for example a Config returned by
+// LoadConfigFile, applying the same settings as UpdateConfig. Fixed settings left
+// unset in cfg and a nil ResponseCache keep their current values, since a
+// configuration file cannot carry them; use UpdateConfig to remove a response cache.
+func (r *ReveniumGoogle) Reload(cfg *Config) error {
+	if cfg == nil {
+		return NewConfigError("config cannot be nil", nil)
+	}
+
+	return r.UpdateConfig(func(current *Config) {
+		next := *cfg
+		for _, setting := range fixedSettings {
+			if setting.unset(&next) {
+				setting.restore(&next, current)
+			}
+		}
+		if next.ResponseCache == nil {
+			next.ResponseCache = current.ResponseCache
+		}
+		*current = next
+	})
+}
+
+// fixedSetting is a Config field that cannot change after the client is created
+type fixedSetting struct {
+	name    string
+	changed func(next, current *Config) bool
+	restore func(next, current *Config)
+	unset   func(cfg *Config) bool
+}
+
+// fixedConfigField describes the fixed setting stored in the field returned by field
+func fixedConfigField[T comparable](name string, field func(c *Config) *T) fixedSetting {
+	var zero T
+	return fixedSetting{
+		name:    name,
+		changed: func(next, current *Config) bool { return *field(next) != *field(current) },
+		restore: func(next, current *Config) { *field(next) = *field(current) },
+		unset:   func(cfg *Config) bool { return *field(cfg) == zero },
+	}
+}
+
+// fixedSettings lists the settings UpdateConfig and Reload keep from creation
+var fixedSettings = []fixedSetting{
+	fixedConfigField("GoogleAPIKey", func(c *Config) *string { return &c.GoogleAPIKey }),
+	fixedConfigField("ProjectID", func(c *Config) *string { return &c.ProjectID }),
+	fixedConfigField("Location", func(c *Config) *string { return &c.Location }),
+	fixedConfigField("VertexDisabled", func(c *Config) *bool { return &c.VertexDisabled }),
+	fixedConfigField("GoogleBaseURL", func(c *Config) *string { return &c.GoogleBaseURL }),
+	fixedConfigField("GoogleHTTPClient", func(c *Config) **http.Client { return &c.GoogleHTTPClient }),
+	fixedConfigField("GenaiClientConfig", func(c *Config) **genai.ClientConfig { return &c.GenaiClientConfig }),
+	fixedConfigField("GenaiClient", func(c *Config) **genai.Client { return &c.GenaiClient }),
+	fixedConfigField("TransportMetering", func(c *Config) *bool { return &c.TransportMetering }),
+	fixedConfigField("LogFormat", func(c *Config) *string { return &c.LogFormat }),
+	fixedConfigField("Logger", func(c *Config) *Logger { return &c.Logger }),
+	fixedConfigField("MeteringSink", func(c *Config) *MeteringSink { return &c.MeteringSink }),
+	fixedConfigField("DryRun", func(c *Config) *bool { return &c.DryRun }),
+	fixedConfigField("DryRunFile", func(c *Config) *string { return &c.DryRunFile }),
+	fixedConfigField("MeteringHTTPClient", func(c *Config) **http.Client { return &c.MeteringHTTPClient }),
+	fixedConfigField("MeteringRoundTripper", func(c *Config) *http.RoundTripper { return &c.MeteringRoundTripper }),
+	fixedConfigField("MeteringProxyURL", func(c *Config) *string { return &c.MeteringProxyURL }),
+	fixedConfigField("MeteringCAFile", func(c *Config) *string { return &c.MeteringCAFile }),
+	fixedConfigField("MeteringClientCertFile", func(c *Config) *string { return &c.MeteringClientCertFile }),
+	fixedConfigField("MeteringClientKeyFile", func(c *Config) *string { return &c.MeteringClientKeyFile }),
+	fixedConfigField("MeteringConnectTimeout", func(c *Config) *time.Duration { return &c.MeteringConnectTimeout }),
+}
+
+// keepFixedSettings restores settings that cannot change after creation, warning
+// about each one the update tried to change. Must be called with r.mu held.
+func (r *ReveniumGoogle) keepFixedSettings(next *Config) {
+	for _, setting := range fixedSettings {
+		if setting.changed(next, r.config) {
+			r.logger.Warn("%s cannot be reloaded; create a new client to change it", setting.name)
+			setting.restore(next, r.config)
+		}
+	}
+}
+
+// RefreshCredentials fetches credentials from provider once and applies them when
+// they differ from the current ones.
+func (r *ReveniumGoogle) RefreshCredentials(ctx context.Context, provider CredentialProvider) error {
+	creds, err := provider.ReveniumCredentials(ctx)
+	if err != nil {
+		return NewConfigError("failed to fetch Revenium credentials", err)
+	}
+	if creds.APIKey == "" {
+		return NewConfigError("credential provider returned an empty API key", nil)
+	}
+
+	current := r.GetConfig()
+	baseURL := current.ReveniumBaseURL
+	if creds.BaseURL != "" {
+		baseURL = NormalizeReveniumBaseURL(creds.BaseURL)
+	}
+	if creds.APIKey == current.ReveniumAPIKey && baseURL == current.ReveniumBaseURL {
+		return nil
+	}
+
+	r.logger.Info("Rotating Revenium metering credentials")
+	return r.UpdateConfig(func(cfg *Config) {
+		cfg.ReveniumAPIKey = creds.APIKey
+		cfg.ReveniumBaseURL = baseURL
+	})
+}
+
+// WatchCredentials polls provider every interval (30s when zero) and rotates the
+// metering credentials when they change, until ctx is cancelled. Failures are logged
+// and the current credentials stay in use.
+func (r *ReveniumGoogle) WatchCredentials(ctx context.Context, provider CredentialProvider, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := r.RefreshCredentials(ctx, provider); err != nil {
+					r.logger.Error("Credential refresh failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// WatchConfigFile checks the configuration file every interval (30s when zero) and
+// reloads the client when the file changes, until ctx is cancelled. The file is read
+// with LoadConfigFileProfile, so environment variables still override its keys.
+// Invalid files are logged and the current configuration stays in use.
+func (r *ReveniumGoogle) WatchConfigFile(ctx context.Context, path string, profile string, interval time.Duration) error {
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return NewConfigError("failed to watch configuration file", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		lastModified, lastSize := info.ModTime(), info.Size()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				r.logger.Warn("Configuration file %s unavailable: %v", path, err)
+				continue
+			}
+			if info.ModTime().Equal(lastModified) && info.Size() == lastSize {
+				continue
+			}
+			lastModified, lastSize = info.ModTime(), info.Size()
+
+			cfg, err := LoadConfigFileProfile(path, profile)
+			if err == nil {
+				err = r.Reload(cfg)
+			}
+			if err != nil {
+				r.logger.Error("Configuration reload from %s failed: %v", path, err)
+			}
+		}
+	}()
+	return nil
+}
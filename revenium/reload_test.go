@@ -0,0 +1,249 @@
+package revenium
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newReloadTestClient(t *testing.T, baseURL string) *ReveniumGoogle {
+	t.Helper()
+	client, err := NewReveniumGoogle(&Config{
+		ReveniumAPIKey:  "hak_original",
+		ReveniumBaseURL: baseURL,
+		GoogleAPIKey:    "google-key",
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return client
+}
+
+func TestUpdateConfig_RotatesKeyForInFlightRetries(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get("x-api-key"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := newReloadTestClient(t, server.URL)
+	before := client.GetConfig()
+
+	if err := client.UpdateConfig(func(cfg *Config) {
+		cfg.ReveniumAPIKey = "hak_rotated"
+		cfg.CapturePrompts = true
+		cfg.GoogleAPIKey = "ignored"
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	after := client.GetConfig()
+	if before.ReveniumAPIKey != "hak_original" || before.CapturePrompts {
+		t.Error("the previous configuration snapshot must not be modified")
+	}
+	if after.ReveniumAPIKey != "hak_rotated" || !after.CapturePrompts {
+		t.Errorf("update not applied: %+v", after)
+	}
+	if after.GoogleAPIKey != "google-key" {
+		t.Errorf("Google API key must not change at runtime, got %q", after.GoogleAPIKey)
+	}
+
+	// A metering goroutine started before the rotation uses the transport it captured
+	if err := client.metering.send(context.Background(), MeteringEventCompletion, map[string]interface{}{"model": "gemini"}); err != nil {
+		t.Fatalf("metering request failed: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(keys) != 1 || keys[0] != "hak_rotated" {
+		t.Errorf("expected the rotated key on the wire, got %v", keys)
+	}
+}
+
+func TestUpdateConfig_RejectsInvalidConfig(t *testing.T) {
+	client := newReloadTestClient(t, "")
+
+	err := client.UpdateConfig(func(cfg *Config) {
+		cfg.ReveniumAPIKey = "not-a-key"
+	})
+	if err == nil {
+		t.Fatal("expected a validation error")
+	}
+	if client.GetConfig().ReveniumAPIKey != "hak_original" {
+		t.Error("an invalid update must leave the configuration unchanged")
+	}
+}
+
+func TestUpdateConfig_DebugFlag(t *testing.T) {
+	client := newReloadTestClient(t, "")
+
+	if gateOf(client.logger).enabled(slog.LevelDebug) {
+		t.Fatal("debug should start disabled")
+	}
+	if err := client.UpdateConfig(func(cfg *Config) { cfg.Debug = true }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !gateOf(client.logger).enabled(slog.LevelDebug) {
+		t.Error("debug flag should be enabled after reload")
+	}
+	if getGlobalDebugFlag() {
+		t.Error("reloading a client must not change the global debug flag")
+	}
+}
+
+func TestRefreshCredentials(t *testing.T) {
+	client := newReloadTestClient(t, "")
+
+	tests := []struct {
+		name    string
+		creds   ReveniumCredentials
+		wantKey string
+		wantURL string
+		wantErr bool
+	}{
+		{"rotate key", ReveniumCredentials{APIKey: "hak_second"}, "hak_second", defaultReveniumBaseURL, false},
+		{"rotate endpoint", ReveniumCredentials{APIKey: "hak_second", BaseURL: "https://eu.revenium.example/meter"}, "hak_second", "https://eu.revenium.example", false},
+		{"empty key", ReveniumCredentials{}, "hak_second", "https://eu.revenium.example", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := CredentialProviderFunc(func(ctx context.Context) (ReveniumCredentials, error) {
+				return tt.creds, nil
+			})
+			err := client.RefreshCredentials(context.Background(), provider)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			cfg := client.metering.currentConfig()
+			if cfg.ReveniumAPIKey != tt.wantKey || cfg.ReveniumBaseURL != tt.wantURL {
+				t.Errorf("got key %q url %q, want %q %q", cfg.ReveniumAPIKey, cfg.ReveniumBaseURL, tt.wantKey, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestReload_AppliesLiveSettingsAndKeepsFixedOnes(t *testing.T) {
+	var logs bytes.Buffer
+	cache := NewMemoryCache(10)
+	client, err := NewReveniumGoogle(&Config{
+		ReveniumAPIKey: "hak_original",
+		GoogleAPIKey:   "google-key",
+		ResponseCache:  cache,
+		Logger:         slog.New(slog.NewTextHandler(&logs, nil)),
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	err = client.Reload(&Config{
+		ReveniumAPIKey:   "hak_reloaded",
+		GoogleAPIKey:     "google-key-2",
+		LogFormat:        "json",
+		GenerationRetry:  GenerationRetryPolicy{MaxAttempts: 3},
+		RateLimits:       RateLimitConfig{Default: RateLimit{RequestsPerMinute: 60}},
+		ResponseCacheTTL: time.Minute,
+		CoalesceRequests: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg := client.GetConfig()
+	if cfg.ReveniumAPIKey != "hak_reloaded" || cfg.GenerationRetry.MaxAttempts != 3 ||
+		cfg.RateLimits.Default.RequestsPerMinute != 60 || cfg.ResponseCacheTTL != time.Minute || !cfg.CoalesceRequests {
+		t.Errorf("live settings not reloaded: %+v", cfg)
+	}
+	if cfg.ResponseCache != cache || cfg.Logger == nil {
+		t.Error("settings a configuration file cannot carry must keep their current values")
+	}
+	if cfg.GoogleAPIKey != "google-key" || cfg.LogFormat != "" {
+		t.Errorf("fixed settings changed: key %q format %q", cfg.GoogleAPIKey, cfg.LogFormat)
+	}
+	for _, field := range []string{"GoogleAPIKey", "LogFormat"} {
+		if !strings.Contains(logs.String(), field+" cannot be reloaded") {
+			t.Errorf("expected a warning naming %s, got %q", field, logs.String())
+		}
+	}
+}
+
+func TestUpdateConfig_RebuildsShadowRoute(t *testing.T) {
+	var mu sync.Mutex
+	var shadowKeys []string
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer primary.Close()
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		shadowKeys = append(shadowKeys, r.Header.Get("x-api-key"))
+		mu.Unlock()
+	}))
+	defer shadow.Close()
+
+	client := newReloadTestClient(t, primary.URL)
+	steps := []struct {
+		name    string
+		update  func(cfg *Config)
+		wantKey string
+	}{
+		{"added", func(cfg *Config) { cfg.ShadowBaseURL = shadow.URL }, "hak_original"},
+		{"key changed", func(cfg *Config) { cfg.ShadowAPIKey = "hak_shadow" }, "hak_shadow"},
+		{"removed", func(cfg *Config) { cfg.ShadowBaseURL = "" }, ""},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			mu.Lock()
+			shadowKeys = nil
+			mu.Unlock()
+			if err := client.UpdateConfig(step.update); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			event := validCompletionEvent()
+			if err := client.metering.send(context.Background(), event.Type, event.Payload); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if got := strings.Join(shadowKeys, ","); got != step.wantKey {
+				t.Errorf("shadow requests = %q, want %q", got, step.wantKey)
+			}
+			if gotShadow := client.MeteringHealth().Shadow != nil; gotShadow != (step.wantKey != "") {
+				t.Errorf("shadow health reported = %v", gotShadow)
+			}
+		})
+	}
+}
+
+func TestUpdateConfig_WarnsWhenLevelCannotFilterCustomSlogLogger(t *testing.T) {
+	var logs bytes.Buffer
+	client, err := NewReveniumGoogle(&Config{
+		ReveniumAPIKey: "hak_original",
+		GoogleAPIKey:   "google-key",
+		Logger:         slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelInfo})),
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if err := client.UpdateConfig(func(cfg *Config) { cfg.Debug = true }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client.logger.Debug("still filtered by the handler")
+
+	if !strings.Contains(logs.String(), "do not filter a custom slog logger") {
+		t.Errorf("expected a warning about the custom logger, got %q", logs.String())
+	}
+	if strings.Contains(logs.String(), "still filtered by the handler") {
+		t.Error("the handler's level should still filter debug records")
+	}
+}
@@ -0,0 +1,153 @@
+package revenium
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how metering deliveries are retried. Zero fields use the
+// values from DefaultRetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first
+	MaxAttempts int
+	// BaseDelay is the backoff cap for the first retry; it doubles on each retry
+	BaseDelay time.Duration
+	// MaxDelay bounds the backoff cap
+	MaxDelay time.Duration
+	// AttemptTimeout bounds a single delivery attempt
+	AttemptTimeout time.Duration
+	// Deadline bounds all attempts and the waits between them
+	Deadline time.Duration
+}
+
+// DefaultRetryPolicy returns the policy used when nothing is configured: 3 attempts,
+// 100ms base delay, 5s max delay, 10s per attempt and 30s overall
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		BaseDelay:      100 * time.Millisecond,
+		MaxDelay:       5 * time.Second,
+		AttemptTimeout: defaultMeteringTimeout,
+		Deadline:       30 * time.Second,
+	}
+}
+
+// normalized returns the policy with defaults filled in for unset fields
+func (p RetryPolicy) normalized() RetryPolicy {
+	defaults := DefaultRetryPolicy()
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaults.MaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = defaults.BaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = defaults.MaxDelay
+	}
+	if p.MaxDelay < p.BaseDelay {
+		p.MaxDelay = p.BaseDelay
+	}
+	if p.AttemptTimeout <= 0 {
+		p.AttemptTimeout = defaults.AttemptTimeout
+	}
+	if p.Deadline <= 0 {
+		p.Deadline = defaults.Deadline
+	}
+	return p
+}
+
+// validationProblems describes every invalid field
+func (p RetryPolicy) validationProblems() []string {
+	var problems []string
+	if p.MaxAttempts < 0 {
+		problems = append(problems, "RetryPolicy.MaxAttempts must not be negative")
+	}
+	for _, field := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"BaseDelay", p.BaseDelay},
+		{"MaxDelay", p.MaxDelay},
+		{"AttemptTimeout", p.AttemptTimeout},
+		{"Deadline", p.Deadline},
+	} {
+		if field.value < 0 {
+			problems = append(problems, fmt.Sprintf("RetryPolicy.%s must not be negative", field.name))
+		}
+	}
+	if p.MaxDelay > 0 && p.BaseDelay > p.MaxDelay {
+		problems = append(problems, "RetryPolicy.BaseDelay must not exceed MaxDelay")
+	}
+	return problems
+}
+
+// backoff returns the full-jitter delay before retry number retry (1 for the first
+// retry): a random duration between zero and min(MaxDelay, BaseDelay*2^(retry-1))
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	ceiling := p.BaseDelay
+	for i := 1; i < retry && ceiling < p.MaxDelay; i++ {
+		ceiling *= 2
+	}
+	if ceiling > p.MaxDelay {
+		ceiling = p.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// retryAfterDetail is the ReveniumError detail carrying a server-requested delay
+const retryAfterDetail = "retryAfter"
+
+// isRetryableStatus reports whether a metering response status is worth retrying:
+// request timeouts, rate limits and server errors
+func isRetryableStatus(status int) bool {
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// retryDelay returns the wait before the next attempt: the server's Retry-After when
+// the error carries one, full-jitter backoff otherwise
+func (p RetryPolicy) retryDelay(retry int, err error) time.Duration {
+	var revErr *ReveniumError
+	if errors.As(err, &revErr) {
+		if delay, ok := revErr.GetDetails()[retryAfterDetail].(time.Duration); ok {
+			return delay
+		}
+	}
+	return p.backoff(retry)
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
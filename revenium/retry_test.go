@@ -0,0 +1,141 @@
+package revenium
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}.normalized()
+
+	tests := []struct {
+		retry       int
+		wantCeiling time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{10, time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if delay := policy.backoff(tt.retry); delay < 0 || delay > tt.wantCeiling {
+				t.Fatalf("retry %d: delay %v outside [0, %v]", tt.retry, delay, tt.wantCeiling)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"-1", 0, false},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestRetryPolicy_Validation(t *testing.T) {
+	cfg := &Config{
+		ReveniumAPIKey: "hak_test",
+		GoogleAPIKey:   "google-key",
+		RetryPolicy:    RetryPolicy{MaxAttempts: -1, BaseDelay: 2 * time.Second, MaxDelay: time.Second},
+	}
+	err := cfg.Validate()
+	if !IsConfigError(err) {
+		t.Fatalf("expected config error, got %v", err)
+	}
+	problems, _ := err.(*ReveniumError).Details["problems"].([]string)
+	if len(problems) != 2 {
+		t.Errorf("expected 2 problems, got %v", problems)
+	}
+}
+
+func TestReveniumSink_RetryAfter(t *testing.T) {
+	tests := []struct {
+		name         string
+		retryAfter   string
+		policy       RetryPolicy
+		wantAttempts int32
+		minElapsed   time.Duration
+	}{
+		{"waits for Retry-After", "1", RetryPolicy{MaxAttempts: 2}, 2, time.Second},
+		{"gives up when Retry-After passes the deadline", "60", RetryPolicy{MaxAttempts: 3, Deadline: time.Second}, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if attempts.Add(1) == 1 {
+					w.Header().Set("Retry-After", tt.retryAfter)
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			sink := NewReveniumSink(&Config{ReveniumAPIKey: "hak_sink_test", ReveniumBaseURL: server.URL, RetryPolicy: tt.policy})
+			start := time.Now()
+			err := sink.Send(context.Background(), testEvent(MeteringEventCompletion))
+			elapsed := time.Since(start)
+
+			if attempts.Load() != tt.wantAttempts {
+				t.Errorf("expected %d attempts, got %d", tt.wantAttempts, attempts.Load())
+			}
+			if (tt.wantAttempts == 1) != IsMeteringError(err) {
+				t.Errorf("unexpected result: %v", err)
+			}
+			if elapsed < tt.minElapsed {
+				t.Errorf("expected to wait at least %v, took %v", tt.minElapsed, elapsed)
+			}
+		})
+	}
+}
+
+func TestReveniumSink_AttemptTimeout(t *testing.T) {
+	var attempts atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			<-release
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	sink := NewReveniumSink(&Config{
+		ReveniumAPIKey:  "hak_sink_test",
+		ReveniumBaseURL: server.URL,
+		RetryPolicy:     RetryPolicy{MaxAttempts: 2, AttemptTimeout: 100 * time.Millisecond},
+	})
+	if err := sink.Send(context.Background(), testEvent(MeteringEventCompletion)); err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	if attempts.Load() != 2 {
+		t.Errorf("expected the slow attempt to be abandoned and retried, got %d attempts", attempts.Load())
+	}
+}
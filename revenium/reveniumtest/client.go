@@ -0,0 +1,46 @@
+package reveniumtest
+
+import (
+	"testing"
+
+	"github.com/revenium/revenium-middleware-google-go/revenium"
+)
+
+// TestAPIKey is the Revenium API key used by the configurations built in this package
+const TestAPIKey = "hak_reveniumtest"
+
+// GeminiConfig returns a configuration that sends Gemini API calls to genaiServer and
+// metering to meteringServer
+func GeminiConfig(genaiServer *GenaiServer, meteringServer *MeteringServer) *revenium.Config {
+	return &revenium.Config{
+		GoogleAPIKey:    "reveniumtest-google-key",
+		GoogleBaseURL:   genaiServer.URL,
+		VertexDisabled:  true,
+		ReveniumAPIKey:  TestAPIKey,
+		ReveniumBaseURL: meteringServer.URL,
+	}
+}
+
+// VertexConfig returns a configuration that sends Vertex AI calls to genaiServer and
+// metering to meteringServer. No Google credentials are needed.
+func VertexConfig(genaiServer *GenaiServer, meteringServer *MeteringServer) *revenium.Config {
+	return &revenium.Config{
+		ProjectID:        "reveniumtest-project",
+		Location:         "us-central1",
+		GoogleBaseURL:    genaiServer.URL,
+		GoogleHTTPClient: genaiServer.Client(),
+		ReveniumAPIKey:   TestAPIKey,
+		ReveniumBaseURL:  meteringServer.URL,
+	}
+}
+
+// NewClient creates a client from cfg and closes it when the test ends
+func NewClient(t testing.TB, cfg *revenium.Config) *revenium.ReveniumGoogle {
+	t.Helper()
+	client, err := revenium.NewReveniumGoogle(cfg)
+	if err != nil {
+		t.Fatalf("reveniumtest: failed to create client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
@@ -0,0 +1,382 @@
+// Package reveniumtest provides fakes for testing code that uses the Revenium
+// middleware without network access: a server that speaks the Gemini API and
+// Vertex AI REST dialects, and a metering server that records every payload.
+//
+//	genaiServer := reveniumtest.NewGenaiServer(t)
+//	meteringServer := reveniumtest.NewMeteringServer(t)
+//	client := reveniumtest.NewClient(t, reveniumtest.GeminiConfig(genaiServer, meteringServer))
+//
+//	genaiServer.Enqueue(reveniumtest.MethodGenerateContent, reveniumtest.TextResponse("hi", 12, 3))
+//	client.Models().GenerateContent(ctx, "gemini-2.0-flash", contents, nil)
+//	client.Flush()
+//
+//	events := meteringServer.Events()
+package reveniumtest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Method identifies a genai REST method served by GenaiServer
+type Method string
+
+const (
+	// MethodGenerateContent serves Models.GenerateContent
+	MethodGenerateContent Method = "generateContent"
+	// MethodStreamGenerateContent serves Models.GenerateContentStream
+	MethodStreamGenerateContent Method = "streamGenerateContent"
+	// MethodPredict serves Models.GenerateImages, EditImage and UpscaleImage
+	MethodPredict Method = "predict"
+	// MethodPredictLongRunning serves Models.GenerateVideos
+	MethodPredictLongRunning Method = "predictLongRunning"
+	// MethodGetOperation serves Operations.GetVideosOperation (a GET on the operation
+	// for Gemini API, fetchPredictOperation for Vertex AI)
+	MethodGetOperation Method = "getOperation"
+)
+
+// Response is a scripted reply from GenaiServer
+type Response struct {
+	// Status is the HTTP status code (defaults to 200)
+	Status int
+	// Body is marshalled as the JSON response body
+	Body interface{}
+	// Chunks are sent as server-sent events for MethodStreamGenerateContent.
+	// Use StreamError to end a stream with an error chunk.
+	Chunks []interface{}
+	// Delay holds the response back, for example to keep calls in flight together
+	Delay time.Duration
+}
+
+// streamErrorChunk is written as a bare JSON error line, the way the API reports
+// errors in the middle of a stream
+type streamErrorChunk struct {
+	body map[string]interface{}
+}
+
+// Request is a request received by GenaiServer
+type Request struct {
+	Method Method
+	Model  string
+	Path   string
+	Header http.Header
+	Body   map[string]interface{}
+}
+
+// GenaiServer is an httptest server that answers Gemini API and Vertex AI REST calls
+// with scripted responses. Unscripted calls get a small successful default reply.
+type GenaiServer struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	scripts    map[Method][]Response
+	requests   []Request
+	operations int
+}
+
+// NewGenaiServer starts a fake genai server that is closed when the test ends
+func NewGenaiServer(t testing.TB) *GenaiServer {
+	t.Helper()
+	s := &GenaiServer{scripts: map[Method][]Response{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Enqueue scripts the next responses for method, served in order
+func (s *GenaiServer) Enqueue(method Method, responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[method] = append(s.scripts[method], responses...)
+}
+
+// Requests returns every request received so far
+func (s *GenaiServer) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *GenaiServer) handle(w http.ResponseWriter, r *http.Request) {
+	req := parseRequest(r)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	resp, scripted := s.next(req.Method)
+	if !scripted {
+		resp = s.defaultResponse(req)
+	}
+	s.mu.Unlock()
+
+	time.Sleep(resp.Delay)
+	if resp.Chunks != nil {
+		writeStream(w, resp)
+		return
+	}
+
+	if body, ok := resp.Body.(map[string]interface{}); ok && isOperationMethod(req.Method) {
+		if _, named := body["name"]; !named {
+			body = withField(body, "name", s.operationName(req))
+		}
+		resp.Body = body
+	}
+	writeJSON(w, resp.Status, resp.Body)
+}
+
+// next pops the next scripted response for method. Must be called with s.mu held.
+func (s *GenaiServer) next(method Method) (Response, bool) {
+	queue := s.scripts[method]
+	if len(queue) == 0 {
+		return Response{}, false
+	}
+	s.scripts[method] = queue[1:]
+	return queue[0], true
+}
+
+// defaultResponse is served when nothing is scripted. Must be called with s.mu held.
+func (s *GenaiServer) defaultResponse(req Request) Response {
+	switch req.Method {
+	case MethodGenerateContent:
+		return TextResponse("ok", 10, 5)
+	case MethodStreamGenerateContent:
+		return StreamResponse([]string{"o", "k"}, 10, 5)
+	case MethodPredict:
+		return ImagesResponse(1)
+	case MethodPredictLongRunning:
+		return PendingOperation()
+	case MethodGetOperation:
+		return VideoOperation("gs://reveniumtest/video-0.mp4")
+	}
+	return ErrorResponse(http.StatusNotFound, fmt.Sprintf("reveniumtest: unsupported path %s", req.Path))
+}
+
+// operationName returns the name of the operation a request refers to, creating one
+// for new long-running calls. Must be called with s.mu held.
+func (s *GenaiServer) operationName(req Request) string {
+	if req.Method == MethodGetOperation {
+		if name, ok := req.Body["operationName"].(string); ok {
+			return name
+		}
+		return strings.TrimPrefix(req.Path, "/"+apiVersion(req.Path)+"/")
+	}
+	s.operations++
+	resource := strings.TrimSuffix(strings.TrimPrefix(req.Path, "/"+apiVersion(req.Path)+"/"), ":"+string(req.Method))
+	return fmt.Sprintf("%s/operations/op-%d", resource, s.operations)
+}
+
+// parseRequest extracts the method, model and body from a genai REST request
+func parseRequest(r *http.Request) Request {
+	req := Request{Path: r.URL.Path, Header: r.Header.Clone()}
+
+	if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+		json.Unmarshal(data, &req.Body)
+	}
+
+	segment := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	resource, method, hasMethod := strings.Cut(segment, ":")
+	switch {
+	case method == "fetchPredictOperation":
+		req.Method = MethodGetOperation
+	case hasMethod:
+		req.Method = Method(method)
+	case r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/operations/"):
+		req.Method = MethodGetOperation
+	}
+
+	if _, model, ok := strings.Cut(r.URL.Path, "/models/"); ok {
+		model, _, _ = strings.Cut(model, "/")
+		model, _, _ = strings.Cut(model, ":")
+		req.Model = model
+	} else {
+		req.Model = resource
+	}
+	return req
+}
+
+// apiVersion returns the first path segment (v1beta for Gemini API, v1beta1 for Vertex AI)
+func apiVersion(path string) string {
+	version, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return version
+}
+
+func isOperationMethod(method Method) bool {
+	return method == MethodPredictLongRunning || method == MethodGetOperation
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeStream(w http.ResponseWriter, resp Response) {
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(status)
+
+	flusher, _ := w.(http.Flusher)
+	for _, chunk := range resp.Chunks {
+		if errChunk, ok := chunk.(streamErrorChunk); ok {
+			data, _ := json.Marshal(errChunk.body)
+			fmt.Fprintf(w, "%s\n\n", data)
+		} else {
+			data, _ := json.Marshal(chunk)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+func withField(body map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(body)+1)
+	for k, v := range body {
+		copied[k] = v
+	}
+	copied[key] = value
+	return copied
+}
+
+// TextResponse returns a generateContent reply with one candidate and usage metadata
+func TextResponse(text string, promptTokens, outputTokens int) Response {
+	return Response{Body: textChunk(text, "STOP", usage(promptTokens, outputTokens))}
+}
+
+// StreamResponse returns a streamed reply with one chunk per text; usage metadata is
+// only sent on the last chunk, as the API does
+func StreamResponse(texts []string, promptTokens, outputTokens int) Response {
+	chunks := make([]interface{}, len(texts))
+	for i, text := range texts {
+		if i == len(texts)-1 {
+			chunks[i] = textChunk(text, "STOP", usage(promptTokens, outputTokens))
+		} else {
+			chunks[i] = textChunk(text, "", nil)
+		}
+	}
+	return Response{Chunks: chunks}
+}
+
+// StreamError returns a chunk that ends a stream with an API error
+func StreamError(status int, message string) interface{} {
+	return streamErrorChunk{body: errorBody(status, message)}
+}
+
+// ErrorResponse returns an API error reply with the given HTTP status
+func ErrorResponse(status int, message string) Response {
+	return Response{Status: status, Body: errorBody(status, message)}
+}
+
+// ImagesResponse returns a predict reply with count generated PNG images
+func ImagesResponse(count int) Response {
+	predictions := make([]interface{}, count)
+	for i := range predictions {
+		predictions[i] = map[string]interface{}{
+			"bytesBase64Encoded": base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("image-%d", i))),
+			"mimeType":           "image/png",
+		}
+	}
+	return Response{Body: map[string]interface{}{"predictions": predictions}}
+}
+
+// PendingOperation returns a long-running operation that is not done yet
+func PendingOperation() Response {
+	return Response{Body: map[string]interface{}{"done": false}}
+}
+
+// VideoOperation returns a finished video operation with one video per URI, in a
+// shape understood by both Gemini API and Vertex AI clients
+func VideoOperation(uris ...string) Response {
+	samples := make([]interface{}, len(uris))
+	videos := make([]interface{}, len(uris))
+	for i, uri := range uris {
+		samples[i] = map[string]interface{}{"video": map[string]interface{}{"uri": uri}}
+		videos[i] = map[string]interface{}{"gcsUri": uri, "mimeType": "video/mp4"}
+	}
+	return Response{Body: map[string]interface{}{
+		"done": true,
+		"response": map[string]interface{}{
+			"generateVideoResponse": map[string]interface{}{"generatedSamples": samples},
+			"videos":                videos,
+		},
+	}}
+}
+
+// FailedOperation returns a finished operation that reports an error
+func FailedOperation(code int, message string) Response {
+	return Response{Body: map[string]interface{}{
+		"done":  true,
+		"error": map[string]interface{}{"code": code, "message": message},
+	}}
+}
+
+func textChunk(text string, finishReason string, usageMetadata map[string]interface{}) map[string]interface{} {
+	candidate := map[string]interface{}{
+		"content": map[string]interface{}{
+			"role":  "model",
+			"parts": []interface{}{map[string]interface{}{"text": text}},
+		},
+	}
+	if finishReason != "" {
+		candidate["finishReason"] = finishReason
+	}
+
+	chunk := map[string]interface{}{"candidates": []interface{}{candidate}}
+	if usageMetadata != nil {
+		chunk["usageMetadata"] = usageMetadata
+	}
+	return chunk
+}
+
+func usage(promptTokens, outputTokens int) map[string]interface{} {
+	return map[string]interface{}{
+		"promptTokenCount":     promptTokens,
+		"candidatesTokenCount": outputTokens,
+		"totalTokenCount":      promptTokens + outputTokens,
+	}
+}
+
+func errorBody(status int, message string) map[string]interface{} {
+	return map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    status,
+			"message": message,
+			"status":  statusName(status),
+		},
+	}
+}
+
+// statusName maps an HTTP status to the canonical status the API reports with it
+func statusName(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	}
+	return "INTERNAL"
+}
@@ -0,0 +1,264 @@
+package reveniumtest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/revenium/revenium-middleware-google-go/revenium"
+	"google.golang.org/genai"
+)
+
+func TestGenerateImages_Metering(t *testing.T) {
+	tests := []struct {
+		name     string
+		response Response
+		wantErr  bool
+		want     map[string]interface{}
+	}{
+		{
+			name:     "success",
+			response: ImagesResponse(2),
+			want: map[string]interface{}{
+				"operationType":       "IMAGE",
+				"stopReason":          "END",
+				"actualImageCount":    float64(2),
+				"requestedImageCount": float64(2),
+			},
+		},
+		{
+			name:     "error",
+			response: ErrorResponse(http.StatusBadRequest, "prompt rejected"),
+			wantErr:  true,
+			want: map[string]interface{}{
+				"stopReason":          "ERROR",
+				"actualImageCount":    float64(0),
+				"requestedImageCount": float64(2),
+			},
+		},
+	}
+
+	for _, backend := range testBackends {
+		for _, tt := range tests {
+			t.Run(backend.name+"/"+tt.name, func(t *testing.T) {
+				genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+				client := NewClient(t, backend.config(genaiServer, meteringServer))
+
+				genaiServer.Enqueue(MethodPredict, tt.response)
+				_, err := client.Images().GenerateImages(context.Background(), "imagen-3.0-generate-002", "a lighthouse",
+					&genai.GenerateImagesConfig{NumberOfImages: 2})
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+				}
+				client.Flush()
+
+				events := meteringServer.Events()
+				if len(events) != 1 {
+					t.Fatalf("expected 1 metering event, got %d", len(events))
+				}
+				want := map[string]interface{}{"model": "imagen-3.0-generate-002", "provider": backend.provider}
+				for key, value := range tt.want {
+					want[key] = value
+				}
+				assertPayload(t, events[0], revenium.MeteringEventImage, want)
+			})
+		}
+	}
+}
+
+func TestGenerateVideos_Metering(t *testing.T) {
+	const model = "veo-2.0-generate-001"
+
+	for _, backend := range testBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+			client := NewClient(t, backend.config(genaiServer, meteringServer))
+			ctx := context.Background()
+
+			genaiServer.Enqueue(MethodGetOperation, PendingOperation(), VideoOperation("gs://bucket/a.mp4"))
+
+			operation, err := client.Videos().GenerateVideos(ctx, model, "a timelapse", nil, nil)
+			if err != nil {
+				t.Fatalf("GenerateVideos: %v", err)
+			}
+			if !strings.Contains(operation.Name, "/operations/") {
+				t.Fatalf("expected an operation name, got %q", operation.Name)
+			}
+
+			resp, err := client.Videos().WaitForVideoGeneration(ctx, operation, model, 10*time.Millisecond, 5*time.Second)
+			if err != nil {
+				t.Fatalf("WaitForVideoGeneration: %v", err)
+			}
+			if len(resp.GeneratedVideos) != 1 || resp.GeneratedVideos[0].Video.URI != "gs://bucket/a.mp4" {
+				t.Errorf("unexpected videos: %+v", resp.GeneratedVideos)
+			}
+			client.Flush()
+
+			events := meteringServer.Events()
+			if len(events) != 2 {
+				t.Fatalf("expected start and completion events, got %d", len(events))
+			}
+			assertPayload(t, events[0], revenium.MeteringEventVideo, map[string]interface{}{
+				"stopReason":       "PENDING",
+				"actualVideoCount": float64(0),
+				"provider":         backend.provider,
+			})
+			if id := revenium.ResponseTransactionID(operation); id == "" || id != events[0].Payload["transactionId"] {
+				t.Errorf("operation transaction ID %q does not match metered %v", id, events[0].Payload["transactionId"])
+			}
+			assertPayload(t, events[1], revenium.MeteringEventVideo, map[string]interface{}{
+				"stopReason":       "END",
+				"actualVideoCount": float64(1),
+				"transactionId":    events[0].Payload["transactionId"],
+			})
+
+			polls := 0
+			for _, req := range genaiServer.Requests() {
+				if req.Method == MethodGetOperation {
+					polls++
+				}
+			}
+			if polls != 2 {
+				t.Errorf("expected 2 operation polls, got %d", polls)
+			}
+		})
+	}
+}
+
+func TestWaitForVideoGeneration_FailedOperation(t *testing.T) {
+	genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+	client := NewClient(t, GeminiConfig(genaiServer, meteringServer))
+	ctx := context.Background()
+
+	genaiServer.Enqueue(MethodGetOperation, FailedOperation(3, "unsafe prompt"))
+
+	operation, err := client.Videos().GenerateVideos(ctx, "veo-2.0-generate-001", "a timelapse", nil, nil)
+	if err != nil {
+		t.Fatalf("GenerateVideos: %v", err)
+	}
+	if _, err := client.Videos().WaitForVideoGeneration(ctx, operation, "veo-2.0-generate-001", 10*time.Millisecond, 5*time.Second); err == nil {
+		t.Fatal("expected the operation error")
+	}
+	client.Flush()
+
+	events := meteringServer.Events()
+	if len(events) != 2 {
+		t.Fatalf("expected start and error events, got %d", len(events))
+	}
+	if reason, _ := events[1].Payload["errorReason"].(string); !strings.Contains(reason, "unsafe prompt") {
+		t.Errorf("expected errorReason to carry the operation error, got %q", reason)
+	}
+	if events[1].Payload["transactionId"] != revenium.ResponseTransactionID(operation) {
+		t.Errorf("expected the error event to reuse the operation transaction ID, got %v", events[1].Payload["transactionId"])
+	}
+}
+
+func TestTransportMetering_Media(t *testing.T) {
+	const imageModel, videoModel = "imagen-3.0-generate-002", "veo-2.0-generate-001"
+
+	tests := []struct {
+		name            string
+		call            func(ctx context.Context, client *revenium.ReveniumGoogle) error
+		want            []map[string]interface{}
+		sameTransaction bool
+		transport       bool
+	}{
+		{
+			name: "direct images",
+			call: func(ctx context.Context, client *revenium.ReveniumGoogle) error {
+				_, err := client.GetGenaiClient().Models.GenerateImages(ctx, imageModel, "a lighthouse", &genai.GenerateImagesConfig{NumberOfImages: 2})
+				return err
+			},
+			want:      []map[string]interface{}{{"model": imageModel, "stopReason": "END", "actualImageCount": float64(2), "requestedImageCount": float64(2)}},
+			transport: true,
+		},
+		{
+			name: "direct videos",
+			call: func(ctx context.Context, client *revenium.ReveniumGoogle) error {
+				operation, err := client.GetGenaiClient().Models.GenerateVideos(ctx, videoModel, "a timelapse", nil, nil)
+				if err != nil {
+					return err
+				}
+				// Polling a done operation again must not meter it twice
+				for i := 0; i < 3; i++ {
+					if operation, err = client.GetGenaiClient().Operations.GetVideosOperation(ctx, operation, nil); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			want: []map[string]interface{}{
+				{"model": videoModel, "stopReason": "PENDING", "actualVideoCount": float64(0)},
+				{"model": videoModel, "stopReason": "END", "actualVideoCount": float64(1)},
+			},
+			sameTransaction: true,
+			transport:       true,
+		},
+		{
+			name: "wrapper images are metered once",
+			call: func(ctx context.Context, client *revenium.ReveniumGoogle) error {
+				_, err := client.Images().GenerateImages(ctx, imageModel, "a lighthouse", &genai.GenerateImagesConfig{NumberOfImages: 2})
+				return err
+			},
+			want: []map[string]interface{}{{"model": imageModel, "stopReason": "END", "actualImageCount": float64(2)}},
+		},
+		{
+			name: "wrapper videos are metered once",
+			call: func(ctx context.Context, client *revenium.ReveniumGoogle) error {
+				operation, err := client.Videos().GenerateVideos(ctx, videoModel, "a timelapse", nil, nil)
+				if err != nil {
+					return err
+				}
+				_, err = client.Videos().WaitForVideoGeneration(ctx, operation, videoModel, 10*time.Millisecond, 5*time.Second)
+				return err
+			},
+			want: []map[string]interface{}{
+				{"model": videoModel, "stopReason": "PENDING"},
+				{"model": videoModel, "stopReason": "END", "actualVideoCount": float64(1)},
+			},
+			sameTransaction: true,
+		},
+	}
+
+	for _, backend := range testBackends {
+		for _, tt := range tests {
+			t.Run(backend.name+"/"+tt.name, func(t *testing.T) {
+				genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+				cfg := backend.config(genaiServer, meteringServer)
+				cfg.TransportMetering = true
+				client := NewClient(t, cfg)
+
+				genaiServer.Enqueue(MethodPredict, ImagesResponse(2))
+				ctx := revenium.WithUsageMetadata(context.Background(), map[string]interface{}{"organizationId": "org-1"})
+				if err := tt.call(ctx, client); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				client.Flush()
+
+				events := meteringServer.Events()
+				if len(events) != len(tt.want) {
+					t.Fatalf("expected %d metering events, got %d", len(tt.want), len(events))
+				}
+				for i, want := range tt.want {
+					eventType := revenium.MeteringEventImage
+					if want["model"] == videoModel {
+						eventType = revenium.MeteringEventVideo
+					}
+					want["provider"] = backend.provider
+					want["organizationId"] = "org-1"
+					assertPayload(t, events[i], eventType, want)
+
+					attributes, _ := events[i].Payload["attributes"].(map[string]interface{})
+					if (attributes["meteredByTransport"] == true) != tt.transport {
+						t.Errorf("meteredByTransport = %v, want %v", attributes["meteredByTransport"], tt.transport)
+					}
+				}
+				if tt.sameTransaction && events[0].Payload["transactionId"] != events[1].Payload["transactionId"] {
+					t.Errorf("start and completion transaction IDs differ: %v, %v", events[0].Payload["transactionId"], events[1].Payload["transactionId"])
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package reveniumtest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/revenium/revenium-middleware-google-go/revenium"
+)
+
+// Metering API paths, by event type
+var meteringPaths = map[string]revenium.MeteringEventType{
+	"/meter/v2/ai/completions": revenium.MeteringEventCompletion,
+	"/meter/v2/ai/images":      revenium.MeteringEventImage,
+	"/meter/v2/ai/video":       revenium.MeteringEventVideo,
+}
+
+// RecordedEvent is one metering request received by MeteringServer
+type RecordedEvent struct {
+	Type    revenium.MeteringEventType
+	Path    string
+	APIKey  string
+	Header  http.Header
+	Payload map[string]interface{}
+	// Status is the HTTP status the server replied with
+	Status int
+}
+
+// MeteringServer is an httptest server that acts as the Revenium metering API and
+// records every request. It accepts everything unless failures are scripted with FailNext.
+type MeteringServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	attempts []RecordedEvent
+	failures []int
+	changed  chan struct{}
+}
+
+// NewMeteringServer starts a recording metering server that is closed when the test ends
+func NewMeteringServer(t testing.TB) *MeteringServer {
+	t.Helper()
+	s := &MeteringServer{changed: make(chan struct{}, 1)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// FailNext makes the next requests fail with the given HTTP statuses, in order
+func (s *MeteringServer) FailNext(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, statuses...)
+}
+
+// Events returns the accepted metering events, in the order they arrived
+func (s *MeteringServer) Events() []RecordedEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []RecordedEvent
+	for _, attempt := range s.attempts {
+		if attempt.Status < 300 {
+			events = append(events, attempt)
+		}
+	}
+	return events
+}
+
+// Attempts returns every request received, including rejected ones
+func (s *MeteringServer) Attempts() []RecordedEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RecordedEvent(nil), s.attempts...)
+}
+
+// WaitForEvents waits until n events were accepted and returns them. The test fails
+// if they do not arrive within timeout.
+func (s *MeteringServer) WaitForEvents(t testing.TB, n int, timeout time.Duration) []RecordedEvent {
+	t.Helper()
+	deadline := time.After(timeout)
+	for {
+		if events := s.Events(); len(events) >= n {
+			return events
+		}
+		select {
+		case <-s.changed:
+		case <-deadline:
+			t.Fatalf("reveniumtest: expected %d metering events, got %d", n, len(s.Events()))
+			return nil
+		}
+	}
+}
+
+func (s *MeteringServer) handle(w http.ResponseWriter, r *http.Request) {
+	event := RecordedEvent{
+		Type:   meteringPaths[r.URL.Path],
+		Path:   r.URL.Path,
+		APIKey: r.Header.Get("x-api-key"),
+		Header: r.Header.Clone(),
+		Status: http.StatusCreated,
+	}
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		if reader, err := gzip.NewReader(r.Body); err == nil {
+			body = reader
+		}
+	}
+	if data, _ := io.ReadAll(body); len(data) > 0 {
+		json.Unmarshal(data, &event.Payload)
+	}
+
+	s.mu.Lock()
+	if event.Type == "" {
+		event.Status = http.StatusNotFound
+	} else if len(s.failures) > 0 {
+		event.Status = s.failures[0]
+		s.failures = s.failures[1:]
+	}
+	s.attempts = append(s.attempts, event)
+	s.mu.Unlock()
+
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+
+	writeJSON(w, event.Status, map[string]interface{}{"id": event.Payload["transactionId"]})
+}
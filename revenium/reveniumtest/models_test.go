@@ -0,0 +1,929 @@
+package reveniumtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/revenium/revenium-middleware-google-go/revenium"
+	"google.golang.org/genai"
+)
+
+const testModel = "gemini-2.0-flash"
+
+var testBackends = []struct {
+	name     string
+	config   func(*GenaiServer, *MeteringServer) *revenium.Config
+	provider string
+}{
+	{"gemini", GeminiConfig, "GOOGLE_AI"},
+	{"vertex", VertexConfig, "VERTEX_AI"},
+}
+
+func testContents() []*genai.Content {
+	return genai.Text("Say hello")
+}
+
+func TestGenerateContent_Metering(t *testing.T) {
+	for _, backend := range testBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+			client := NewClient(t, backend.config(genaiServer, meteringServer))
+
+			genaiServer.Enqueue(MethodGenerateContent, TextResponse("Hello!", 12, 3))
+			resp, err := client.Models().GenerateContent(context.Background(), testModel, testContents(), nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Text() != "Hello!" {
+				t.Errorf("unexpected response text %q", resp.Text())
+			}
+			client.Flush()
+
+			requests := genaiServer.Requests()
+			if len(requests) != 1 || requests[0].Method != MethodGenerateContent || requests[0].Model != testModel {
+				t.Fatalf("unexpected genai requests: %+v", requests)
+			}
+
+			events := meteringServer.Events()
+			if len(events) != 1 {
+				t.Fatalf("expected 1 metering event, got %d", len(events))
+			}
+			assertPayload(t, events[0], revenium.MeteringEventCompletion, map[string]interface{}{
+				"model":            testModel,
+				"provider":         backend.provider,
+				"isStreamed":       false,
+				"stopReason":       "END",
+				"inputTokenCount":  float64(12),
+				"outputTokenCount": float64(3),
+				"totalTokenCount":  float64(15),
+			})
+			if id := revenium.ResponseTransactionID(resp); id == "" || id != events[0].Payload["transactionId"] {
+				t.Errorf("response transaction ID %q does not match metered %v", id, events[0].Payload["transactionId"])
+			}
+		})
+	}
+}
+
+func TestGenerateContent_ErrorMetering(t *testing.T) {
+	genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+	client := NewClient(t, GeminiConfig(genaiServer, meteringServer))
+
+	genaiServer.Enqueue(MethodGenerateContent, ErrorResponse(http.StatusTooManyRequests, "quota exceeded"))
+	if _, err := client.Models().GenerateContent(context.Background(), testModel, testContents(), nil); err == nil {
+		t.Fatal("expected the API error to be returned")
+	}
+	client.Flush()
+
+	events := meteringServer.Events()
+	if len(events) != 1 {
+		t.Fatalf("expected 1 metering event, got %d", len(events))
+	}
+	if reason, _ := events[0].Payload["errorReason"].(string); !strings.Contains(reason, "quota exceeded") {
+		t.Errorf("expected errorReason to carry the API error, got %q", reason)
+	}
+	assertPayload(t, events[0], revenium.MeteringEventCompletion, map[string]interface{}{
+		"inputTokenCount":  float64(0),
+		"outputTokenCount": float64(0),
+	})
+}
+
+func TestGenerateContentStream_Metering(t *testing.T) {
+	tests := []struct {
+		name          string
+		response      Response
+		stopAfter     int
+		wantText      string
+		wantErr       bool
+		wantUsage     map[string]interface{}
+		wantEstimated bool
+	}{
+		{
+			name:     "complete stream",
+			response: StreamResponse([]string{"Hel", "lo", "!"}, 8, 4),
+			wantText: "Hello!",
+			wantUsage: map[string]interface{}{
+				"inputTokenCount":  float64(8),
+				"outputTokenCount": float64(4),
+			},
+		},
+		{
+			name: "error mid-stream",
+			response: Response{Chunks: append(
+				StreamResponse([]string{"partial", ""}, 8, 4).Chunks[:1],
+				StreamError(http.StatusServiceUnavailable, "model overloaded"),
+			)},
+			wantText: "partial",
+			wantErr:  true,
+			// Estimated from "Say hello" and "partial", four characters per token
+			wantUsage: map[string]interface{}{
+				"inputTokenCount":  float64(3),
+				"outputTokenCount": float64(2),
+			},
+			wantEstimated: true,
+		},
+		{
+			name:      "consumer stops before usage",
+			response:  StreamResponse([]string{"Hello there, ", "friend", "!"}, 8, 4),
+			stopAfter: 1,
+			wantText:  "Hello there, ",
+			wantUsage: map[string]interface{}{
+				"inputTokenCount":  float64(3),
+				"outputTokenCount": float64(4),
+			},
+			wantEstimated: true,
+		},
+		{
+			name:     "error before any chunk",
+			response: Response{Chunks: []interface{}{StreamError(http.StatusBadRequest, "invalid argument")}},
+			wantErr:  true,
+			wantUsage: map[string]interface{}{
+				"inputTokenCount":  float64(0),
+				"outputTokenCount": float64(0),
+			},
+		},
+	}
+
+	for _, backend := range testBackends {
+		for _, tt := range tests {
+			t.Run(backend.name+"/"+tt.name, func(t *testing.T) {
+				genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+				client := NewClient(t, backend.config(genaiServer, meteringServer))
+
+				genaiServer.Enqueue(MethodStreamGenerateContent, tt.response)
+
+				var text strings.Builder
+				var streamErr error
+				chunkIDs := map[string]bool{}
+				chunks := 0
+				for resp, err := range client.Models().GenerateContentStream(context.Background(), testModel, testContents(), nil) {
+					if err != nil {
+						streamErr = err
+						break
+					}
+					text.WriteString(resp.Text())
+					chunkIDs[revenium.ResponseTransactionID(resp)] = true
+					if chunks++; chunks == tt.stopAfter {
+						break
+					}
+				}
+				client.Flush()
+
+				if text.String() != tt.wantText {
+					t.Errorf("expected streamed text %q, got %q", tt.wantText, text.String())
+				}
+				if (streamErr != nil) != tt.wantErr {
+					t.Errorf("stream error = %v, wantErr %v", streamErr, tt.wantErr)
+				}
+
+				events := meteringServer.Events()
+				if len(events) != 1 {
+					t.Fatalf("expected 1 metering event, got %d", len(events))
+				}
+				want := map[string]interface{}{"isStreamed": true, "provider": backend.provider}
+				for key, value := range tt.wantUsage {
+					want[key] = value
+				}
+				assertPayload(t, events[0], revenium.MeteringEventCompletion, want)
+				attributes, _ := events[0].Payload["attributes"].(map[string]interface{})
+				if estimated := attributes["tokenCountEstimated"] == true; estimated != tt.wantEstimated {
+					t.Errorf("tokenCountEstimated = %v, want %v", estimated, tt.wantEstimated)
+				}
+				if id, _ := events[0].Payload["transactionId"].(string); tt.wantText != "" && (len(chunkIDs) != 1 || !chunkIDs[id]) {
+					t.Errorf("expected every chunk to carry transaction ID %q, got %v", id, chunkIDs)
+				}
+			})
+		}
+	}
+}
+
+func TestMeteringServer_RetriesAreRecorded(t *testing.T) {
+	genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+	client := NewClient(t, GeminiConfig(genaiServer, meteringServer))
+
+	meteringServer.FailNext(http.StatusBadGateway)
+	resp, err := client.Models().GenerateContent(context.Background(), testModel, testContents(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events := meteringServer.WaitForEvents(t, 1, 5*time.Second)
+	attempts := meteringServer.Attempts()
+	if len(attempts) != 2 || attempts[0].Status != http.StatusBadGateway {
+		t.Fatalf("expected a failed attempt followed by a retry, got %+v", attempts)
+	}
+	if key := attempts[0].Header.Get("Idempotency-Key"); key == "" || attempts[1].Header.Get("Idempotency-Key") != key {
+		t.Errorf("expected retries to share one idempotency key, got %q and %q", key, attempts[1].Header.Get("Idempotency-Key"))
+	}
+	if id := revenium.ResponseTransactionID(resp); events[0].Payload["transactionId"] != id {
+		t.Errorf("expected transaction ID %q, got %v", id, events[0].Payload["transactionId"])
+	}
+	if events[0].APIKey != TestAPIKey {
+		t.Errorf("expected API key %q, got %q", TestAPIKey, events[0].APIKey)
+	}
+}
+
+func TestIdempotencyKeys_UniquePerCall(t *testing.T) {
+	tests := []struct {
+		name              string
+		metadata          map[string]interface{}
+		wantSharedTransID bool
+		call              func(ctx context.Context, client *revenium.ReveniumGoogle) error
+	}{
+		{
+			name:              "caller transactionId reused across calls",
+			metadata:          map[string]interface{}{"transactionId": "txn-from-caller"},
+			wantSharedTransID: true,
+			call: func(ctx context.Context, client *revenium.ReveniumGoogle) error {
+				_, err := client.Models().GenerateContent(ctx, testModel, testContents(), nil)
+				return err
+			},
+		},
+		{
+			name: "stream iterated twice",
+			call: func() func(ctx context.Context, client *revenium.ReveniumGoogle) error {
+				var stream iter.Seq2[*genai.GenerateContentResponse, error]
+				return func(ctx context.Context, client *revenium.ReveniumGoogle) error {
+					if stream == nil {
+						stream = client.Models().GenerateContentStream(ctx, testModel, testContents(), nil)
+					}
+					for _, err := range stream {
+						if err != nil {
+							return err
+						}
+					}
+					return nil
+				}
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+			client := NewClient(t, GeminiConfig(genaiServer, meteringServer))
+
+			ctx := revenium.WithUsageMetadata(context.Background(), tt.metadata)
+			for i := 0; i < 2; i++ {
+				if err := tt.call(ctx, client); err != nil {
+					t.Fatalf("call %d: %v", i+1, err)
+				}
+			}
+			client.Flush()
+
+			attempts := meteringServer.Attempts()
+			if len(attempts) != 2 {
+				t.Fatalf("expected 2 metering attempts, got %d", len(attempts))
+			}
+			if key := attempts[0].Header.Get("Idempotency-Key"); key == "" || key == attempts[1].Header.Get("Idempotency-Key") {
+				t.Errorf("expected distinct idempotency keys, got %q twice", key)
+			}
+			events := meteringServer.Events()
+			if shared := events[0].Payload["transactionId"] == events[1].Payload["transactionId"]; shared != tt.wantSharedTransID {
+				t.Errorf("transaction IDs %v and %v: shared = %v, want %v",
+					events[0].Payload["transactionId"], events[1].Payload["transactionId"], shared, tt.wantSharedTransID)
+			}
+		})
+	}
+}
+
+// assertPayload checks the event type and the given payload fields
+func assertPayload(t *testing.T, event RecordedEvent, wantType revenium.MeteringEventType, want map[string]interface{}) {
+	t.Helper()
+	if event.Type != wantType {
+		t.Errorf("expected %s event, got %q (path %s)", wantType, event.Type, event.Path)
+	}
+	if event.Payload["transactionId"] == "" || event.Payload["transactionId"] == nil {
+		t.Error("payload is missing transactionId")
+	}
+	for key, value := range want {
+		if event.Payload[key] != value {
+			t.Errorf("payload[%q] = %v (%T), want %v (%T)", key, event.Payload[key], event.Payload[key], value, value)
+		}
+	}
+}
+
+func TestGenerateContentWithReceipt(t *testing.T) {
+	tests := []struct {
+		name        string
+		failMetered []int
+		wantErr     func(error) bool
+	}{
+		{"recorded", nil, func(err error) bool { return err == nil }},
+		{"rejected", []int{http.StatusBadRequest}, revenium.IsValidationError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+			client := NewClient(t, GeminiConfig(genaiServer, meteringServer))
+			meteringServer.FailNext(tt.failMetered...)
+
+			genaiServer.Enqueue(MethodGenerateContent, TextResponse("Hello!", 12, 3))
+			resp, receipt, err := client.Models().GenerateContentWithReceipt(context.Background(), testModel, testContents(), nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if receipt == nil {
+				t.Fatal("expected a receipt")
+			}
+			if receipt.TransactionID != revenium.ResponseTransactionID(resp) {
+				t.Errorf("receipt transaction ID %q does not match the response's %q", receipt.TransactionID, revenium.ResponseTransactionID(resp))
+			}
+			if receipt.InputTokens != 12 || receipt.OutputTokens != 3 || receipt.TotalTokens != 15 {
+				t.Errorf("unexpected token breakdown: %+v", receipt)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := receipt.Wait(ctx); !tt.wantErr(err) {
+				t.Errorf("unexpected delivery outcome: %v", err)
+			}
+		})
+	}
+}
+
+func TestGenerateContentWithReceipt_PanickingSink(t *testing.T) {
+	genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+	cfg := GeminiConfig(genaiServer, meteringServer)
+	cfg.MeteringSink = revenium.MeteringSinkFunc(func(ctx context.Context, event revenium.MeteringEvent) error {
+		panic("sink failure")
+	})
+	client := NewClient(t, cfg)
+
+	type result struct {
+		receipt *revenium.MeteringReceipt
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		_, receipt, err := client.Models().GenerateContentWithReceipt(context.Background(), testModel, testContents(), nil)
+		done <- result{receipt, err}
+	}()
+
+	select {
+	case got := <-done:
+		if got.err != nil || got.receipt == nil {
+			t.Fatalf("expected a receipt, got %v and %v", got.receipt, got.err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := got.receipt.Wait(ctx); !revenium.IsMeteringError(err) {
+			t.Errorf("expected the sink panic as a metering error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("GenerateContentWithReceipt did not return when the sink panicked")
+	}
+}
+
+func TestMeteringReceiptHook_Stream(t *testing.T) {
+	genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+	client := NewClient(t, GeminiConfig(genaiServer, meteringServer))
+
+	receipts := make(chan *revenium.MeteringReceipt, 1)
+	ctx := revenium.WithMeteringReceiptHook(context.Background(), func(receipt *revenium.MeteringReceipt) {
+		receipts <- receipt
+	})
+
+	genaiServer.Enqueue(MethodStreamGenerateContent, StreamResponse([]string{"Hel", "lo"}, 8, 4))
+	var lastID string
+	for resp, err := range client.Models().GenerateContentStream(ctx, testModel, testContents(), nil) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		lastID = revenium.ResponseTransactionID(resp)
+	}
+
+	select {
+	case receipt := <-receipts:
+		if receipt.TransactionID != lastID || receipt.OutputTokens != 4 {
+			t.Errorf("unexpected receipt: %+v", receipt)
+		}
+		<-receipt.Done()
+		if err := receipt.Err(); err != nil {
+			t.Errorf("unexpected delivery error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("receipt hook was not called")
+	}
+	if len(meteringServer.Events()) != 1 {
+		t.Errorf("expected 1 metering event, got %d", len(meteringServer.Events()))
+	}
+}
+
+func TestGenaiClientConfig_PassThrough(t *testing.T) {
+	genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+	cfg := VertexConfig(genaiServer, meteringServer)
+	cfg.GenaiClientConfig = &genai.ClientConfig{
+		HTTPOptions: genai.HTTPOptions{APIVersion: "v1", Headers: http.Header{"X-Team": []string{"search"}}},
+	}
+	client := NewClient(t, cfg)
+
+	genaiServer.Enqueue(MethodGenerateContent, TextResponse("Hello!", 12, 3))
+	if _, err := client.Models().GenerateContent(context.Background(), testModel, testContents(), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client.Flush()
+
+	requests := genaiServer.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 genai request, got %d", len(requests))
+	}
+	if !strings.HasPrefix(requests[0].Path, "/v1/") {
+		t.Errorf("API version should be passed through, got path %s", requests[0].Path)
+	}
+	if requests[0].Header.Get("X-Team") != "search" {
+		t.Errorf("custom header missing, got %v", requests[0].Header)
+	}
+	assertPayload(t, meteringServer.WaitForEvents(t, 1, time.Second)[0], revenium.MeteringEventCompletion, map[string]interface{}{
+		"provider": "VERTEX_AI",
+	})
+}
+
+func TestWithGenaiClient_WrapsExistingClient(t *testing.T) {
+	genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+	genaiClient, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		Backend:     genai.BackendGeminiAPI,
+		APIKey:      "prebuilt-key",
+		HTTPOptions: genai.HTTPOptions{BaseURL: genaiServer.URL},
+	})
+	if err != nil {
+		t.Fatalf("failed to create genai client: %v", err)
+	}
+
+	client := NewClient(t, &revenium.Config{
+		GenaiClient:     genaiClient,
+		ReveniumAPIKey:  TestAPIKey,
+		ReveniumBaseURL: meteringServer.URL,
+	})
+	if client.GetGenaiClient() != genaiClient {
+		t.Error("the wrapped client should be used as is")
+	}
+
+	genaiServer.Enqueue(MethodGenerateContent, TextResponse("Hello!", 12, 3))
+	if _, err := client.Models().GenerateContent(context.Background(), testModel, testContents(), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client.Flush()
+
+	if key := genaiServer.Requests()[0].Header.Get("X-Goog-Api-Key"); key != "prebuilt-key" {
+		t.Errorf("request should use the wrapped client's API key, got %q", key)
+	}
+	assertPayload(t, meteringServer.WaitForEvents(t, 1, time.Second)[0], revenium.MeteringEventCompletion, map[string]interface{}{
+		"provider":         "GOOGLE_AI",
+		"inputTokenCount":  float64(12),
+		"outputTokenCount": float64(3),
+	})
+}
+
+func TestTransportMetering(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    Method
+		response  Response
+		call      func(ctx context.Context, client *revenium.ReveniumGoogle) (string, error)
+		want      map[string]interface{}
+		wantErr   bool
+		transport bool
+	}{
+		{
+			name:     "direct GenerateContent",
+			method:   MethodGenerateContent,
+			response: TextResponse("Hello!", 12, 3),
+			call: func(ctx context.Context, client *revenium.ReveniumGoogle) (string, error) {
+				resp, err := client.GetGenaiClient().Models.GenerateContent(ctx, testModel, testContents(), nil)
+				return revenium.ResponseTransactionID(resp), err
+			},
+			want:      map[string]interface{}{"isStreamed": false, "inputTokenCount": float64(12), "outputTokenCount": float64(3), "stopReason": "END"},
+			transport: true,
+		},
+		{
+			name:     "direct stream",
+			method:   MethodStreamGenerateContent,
+			response: StreamResponse([]string{"Hel", "lo"}, 8, 4),
+			call: func(ctx context.Context, client *revenium.ReveniumGoogle) (string, error) {
+				var id string
+				for resp, err := range client.GetGenaiClient().Models.GenerateContentStream(ctx, testModel, testContents(), nil) {
+					if err != nil {
+						return id, err
+					}
+					id = revenium.ResponseTransactionID(resp)
+				}
+				return id, nil
+			},
+			want:      map[string]interface{}{"isStreamed": true, "inputTokenCount": float64(8), "outputTokenCount": float64(4)},
+			transport: true,
+		},
+		{
+			name:     "direct error",
+			method:   MethodGenerateContent,
+			response: ErrorResponse(http.StatusBadRequest, "invalid argument"),
+			call: func(ctx context.Context, client *revenium.ReveniumGoogle) (string, error) {
+				_, err := client.GetGenaiClient().Models.GenerateContent(ctx, testModel, testContents(), nil)
+				return "", err
+			},
+			want:      map[string]interface{}{"inputTokenCount": float64(0)},
+			wantErr:   true,
+			transport: true,
+		},
+		{
+			name:     "wrapper is metered once",
+			method:   MethodGenerateContent,
+			response: TextResponse("Hello!", 12, 3),
+			call: func(ctx context.Context, client *revenium.ReveniumGoogle) (string, error) {
+				resp, err := client.Models().GenerateContent(ctx, testModel, testContents(), nil)
+				return revenium.ResponseTransactionID(resp), err
+			},
+			want: map[string]interface{}{"inputTokenCount": float64(12)},
+		},
+	}
+
+	for _, backend := range testBackends {
+		for _, tt := range tests {
+			t.Run(backend.name+"/"+tt.name, func(t *testing.T) {
+				genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+				cfg := backend.config(genaiServer, meteringServer)
+				cfg.TransportMetering = true
+				client := NewClient(t, cfg)
+
+				genaiServer.Enqueue(tt.method, tt.response)
+				ctx := revenium.WithUsageMetadata(context.Background(), map[string]interface{}{"organizationId": "org-1"})
+				id, err := tt.call(ctx, client)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+				}
+				client.Flush()
+
+				events := meteringServer.Events()
+				if len(events) != 1 {
+					t.Fatalf("expected 1 metering event, got %d", len(events))
+				}
+				want := map[string]interface{}{"model": testModel, "provider": backend.provider, "organizationId": "org-1"}
+				for key, value := range tt.want {
+					want[key] = value
+				}
+				assertPayload(t, events[0], revenium.MeteringEventCompletion, want)
+
+				attributes, _ := events[0].Payload["attributes"].(map[string]interface{})
+				if (attributes["meteredByTransport"] == true) != tt.transport {
+					t.Errorf("meteredByTransport = %v, want %v", attributes["meteredByTransport"], tt.transport)
+				}
+				if tt.wantErr {
+					if reason, _ := events[0].Payload["errorReason"].(string); !strings.Contains(reason, "invalid argument") {
+						t.Errorf("expected the API error in errorReason, got %q", reason)
+					}
+				} else if id != events[0].Payload["transactionId"] {
+					t.Errorf("response transaction ID %q does not match metered %v", id, events[0].Payload["transactionId"])
+				}
+			})
+		}
+	}
+}
+
+func TestTransportMetering_CallerHTTPClientUnchanged(t *testing.T) {
+	genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+	cfg := VertexConfig(genaiServer, meteringServer)
+	cfg.TransportMetering = true
+	transport := cfg.GoogleHTTPClient.Transport
+	NewClient(t, cfg)
+
+	if cfg.GoogleHTTPClient.Transport != transport {
+		t.Error("the caller's HTTP client must not be modified")
+	}
+}
+
+func TestRateLimits_FailFast(t *testing.T) {
+	genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+	cfg := GeminiConfig(genaiServer, meteringServer)
+	cfg.RateLimits = revenium.RateLimitConfig{
+		Models:   map[string]revenium.RateLimit{testModel: {RequestsPerMinute: 1}},
+		FailFast: true,
+	}
+	client := NewClient(t, cfg)
+
+	genaiServer.Enqueue(MethodGenerateContent, TextResponse("Hello!", 12, 3))
+	if _, err := client.Models().GenerateContent(context.Background(), testModel, testContents(), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err := client.Models().GenerateContent(context.Background(), testModel, testContents(), nil)
+	if !revenium.IsRateLimitError(err) {
+		t.Fatalf("expected a rate limit error, got %v", err)
+	}
+	client.Flush()
+
+	if len(genaiServer.Requests()) != 1 || len(meteringServer.Events()) != 1 {
+		t.Errorf("a throttled call must not reach Google or be metered, got %d requests and %d events",
+			len(genaiServer.Requests()), len(meteringServer.Events()))
+	}
+}
+
+func TestRateLimits_StreamMediationLatency(t *testing.T) {
+	genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+	cfg := GeminiConfig(genaiServer, meteringServer)
+	cfg.RateLimits = revenium.RateLimitConfig{Default: revenium.RateLimit{TokensPerMinute: 60000}}
+	client := NewClient(t, cfg)
+
+	// The first call uses more tokens than estimated, so the stream has to wait
+	genaiServer.Enqueue(MethodGenerateContent, TextResponse("Hello!", 60000, 30))
+	if _, err := client.Models().GenerateContent(context.Background(), testModel, testContents(), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	genaiServer.Enqueue(MethodStreamGenerateContent, StreamResponse([]string{"Hel", "lo"}, 8, 4))
+	for _, err := range client.Models().GenerateContentStream(context.Background(), testModel, testContents(), nil) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	client.Flush()
+
+	events := meteringServer.Events()
+	if len(events) != 2 {
+		t.Fatalf("expected 2 metering events, got %d", len(events))
+	}
+	stream := events[0]
+	if stream.Payload["isStreamed"] != true {
+		stream = events[1]
+	}
+	if latency, _ := stream.Payload["mediationLatency"].(float64); latency < 1 || stream.Payload["isStreamed"] != true {
+		t.Errorf("expected the stream's rate limit wait as mediationLatency, got %v", stream.Payload["mediationLatency"])
+	}
+}
+
+func TestGenerateContentWithReceipt_FailFastRateLimit(t *testing.T) {
+	genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+	cfg := GeminiConfig(genaiServer, meteringServer)
+	cfg.RateLimits = revenium.RateLimitConfig{Default: revenium.RateLimit{RequestsPerMinute: 1}, FailFast: true}
+	client := NewClient(t, cfg)
+
+	if _, _, err := client.Models().GenerateContentWithReceipt(context.Background(), testModel, testContents(), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type result struct {
+		receipt *revenium.MeteringReceipt
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		_, receipt, err := client.Models().GenerateContentWithReceipt(context.Background(), testModel, testContents(), nil)
+		done <- result{receipt, err}
+	}()
+
+	select {
+	case got := <-done:
+		if !revenium.IsRateLimitError(got.err) || got.receipt != nil {
+			t.Errorf("expected a rate limit error and no receipt, got %v and %v", got.err, got.receipt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("GenerateContentWithReceipt did not return for a throttled call")
+	}
+}
+
+func TestResponseCache_HitsAreMetered(t *testing.T) {
+	genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+	cfg := GeminiConfig(genaiServer, meteringServer)
+	cfg.ResponseCache = revenium.NewMemoryCache(10)
+	client := NewClient(t, cfg)
+
+	genaiServer.Enqueue(MethodGenerateContent, TextResponse("Refund", 12, 3))
+	ctx := revenium.WithUsageMetadata(context.Background(), map[string]interface{}{"organizationId": "org-1"})
+	var ids []string
+	for i := 0; i < 2; i++ {
+		resp, err := client.Models().GenerateContent(ctx, testModel, testContents(), nil)
+		if err != nil {
+			t.Fatalf("call %d: unexpected error: %v", i+1, err)
+		}
+		if resp.Text() != "Refund" {
+			t.Errorf("call %d: unexpected text %q", i+1, resp.Text())
+		}
+		ids = append(ids, revenium.ResponseTransactionID(resp))
+	}
+	client.Flush()
+
+	if len(genaiServer.Requests()) != 1 {
+		t.Fatalf("expected the second call to be served from the cache, got %d genai requests", len(genaiServer.Requests()))
+	}
+	events := meteringServer.Events()
+	if len(events) != 2 {
+		t.Fatalf("expected 2 metering events, got %d", len(events))
+	}
+
+	var hit RecordedEvent
+	for _, event := range events {
+		if event.Payload["transactionId"] == ids[1] {
+			hit = event
+		}
+	}
+	assertPayload(t, hit, revenium.MeteringEventCompletion, map[string]interface{}{
+		"organizationId":   "org-1",
+		"inputTokenCount":  float64(0),
+		"outputTokenCount": float64(0),
+		"stopReason":       "END",
+	})
+	attributes, _ := hit.Payload["attributes"].(map[string]interface{})
+	if attributes["cacheHit"] != true || attributes["cacheSavedTokens"] != float64(15) {
+		t.Errorf("expected cache hit attributes, got %v", attributes)
+	}
+}
+
+func TestRequestCoalescing(t *testing.T) {
+	genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+	cfg := GeminiConfig(genaiServer, meteringServer)
+	cfg.CoalesceRequests = true
+	client := NewClient(t, cfg)
+
+	response := TextResponse("Summary", 12, 3)
+	response.Delay = 200 * time.Millisecond
+	genaiServer.Enqueue(MethodGenerateContent, response)
+
+	const callers = 4
+	ids := make([]string, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := revenium.WithUsageMetadata(context.Background(), map[string]interface{}{"taskId": fmt.Sprintf("task-%d", i)})
+			resp, err := client.Models().GenerateContent(ctx, testModel, testContents(), nil)
+			if err != nil {
+				t.Errorf("caller %d: unexpected error: %v", i, err)
+				return
+			}
+			if resp.Text() != "Summary" {
+				t.Errorf("caller %d: unexpected text %q", i, resp.Text())
+			}
+			ids[i] = revenium.ResponseTransactionID(resp)
+		}(i)
+	}
+	wg.Wait()
+	client.Flush()
+
+	if len(genaiServer.Requests()) != 1 {
+		t.Fatalf("expected one Google call, got %d", len(genaiServer.Requests()))
+	}
+	events := meteringServer.Events()
+	if len(events) != callers {
+		t.Fatalf("expected %d metering events, got %d", callers, len(events))
+	}
+
+	var leaderID string
+	coalesced := 0
+	for _, event := range events {
+		attributes, _ := event.Payload["attributes"].(map[string]interface{})
+		if attributes["coalesced"] != true {
+			leaderID = event.Payload["transactionId"].(string)
+			assertPayload(t, event, revenium.MeteringEventCompletion, map[string]interface{}{"inputTokenCount": float64(12)})
+			continue
+		}
+		coalesced++
+		assertPayload(t, event, revenium.MeteringEventCompletion, map[string]interface{}{
+			"inputTokenCount":  float64(0),
+			"outputTokenCount": float64(0),
+		})
+		if event.Payload["taskId"] == nil {
+			t.Errorf("coalesced events keep the caller's metadata, got %v", event.Payload)
+		}
+	}
+	if coalesced != callers-1 {
+		t.Errorf("expected %d coalesced events, got %d", callers-1, coalesced)
+	}
+	for _, event := range events {
+		if attributes, _ := event.Payload["attributes"].(map[string]interface{}); attributes["coalesced"] == true &&
+			attributes["coalescedTransactionId"] != leaderID {
+			t.Errorf("coalesced events should point at %s, got %v", leaderID, attributes["coalescedTransactionId"])
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if id == "" || seen[id] {
+			t.Errorf("every caller should get its own transaction ID, got %v", ids)
+			break
+		}
+		seen[id] = true
+	}
+}
+
+func TestGenerateContentStream_LatencyStats(t *testing.T) {
+	genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+	client := NewClient(t, GeminiConfig(genaiServer, meteringServer))
+	genaiServer.Enqueue(MethodStreamGenerateContent, StreamResponse([]string{"Hel", "lo", "!"}, 8, 4))
+
+	var stats []revenium.StreamStats
+	ctx := revenium.WithStreamStatsHook(context.Background(), func(s revenium.StreamStats) {
+		stats = append(stats, s)
+	})
+	for _, err := range client.Models().GenerateContentStream(ctx, testModel, testContents(), nil) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	client.Flush()
+
+	events := meteringServer.Events()
+	if len(events) != 1 || len(stats) != 1 {
+		t.Fatalf("expected 1 event and 1 stats report, got %d and %d", len(events), len(stats))
+	}
+	if stats[0].ChunkCount != 3 || stats[0].TransactionID != events[0].Payload["transactionId"] || stats[0].Model != testModel {
+		t.Errorf("unexpected stats %+v", stats[0])
+	}
+
+	attributes, _ := events[0].Payload["attributes"].(map[string]interface{})
+	for _, key := range []string{"streamMeanChunkGapMs", "streamP95ChunkGapMs", "streamMaxChunkGapMs", "streamOutputTokensPerSecond"} {
+		if _, ok := attributes[key].(float64); !ok {
+			t.Errorf("expected attribute %s, got %v", key, attributes)
+		}
+	}
+	if attributes["streamChunkCount"] != float64(3) || attributes["streamStalled"] != false {
+		t.Errorf("unexpected stream attributes %v", attributes)
+	}
+
+	for _, field := range []string{"requestTime", "completionStartTime", "responseTime"} {
+		value, _ := events[0].Payload[field].(string)
+		if _, err := time.Parse("2006-01-02T15:04:05.000Z07:00", value); err != nil {
+			t.Errorf("%s should have millisecond precision, got %q", field, value)
+		}
+	}
+}
+
+func TestGenerateContentStream_MeteredFromMergedResponse(t *testing.T) {
+	genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+	cfg := GeminiConfig(genaiServer, meteringServer)
+	cfg.CapturePrompts = true
+	client := NewClient(t, cfg)
+	genaiServer.Enqueue(MethodStreamGenerateContent, Response{Chunks: []interface{}{
+		textChunk("The answer ", "", nil),
+		textChunk("was cut", "MAX_TOKENS", usage(8, 4)),
+	}})
+
+	accumulator := revenium.NewStreamAccumulator()
+	for resp, err := range client.Models().GenerateContentStream(context.Background(), testModel, testContents(), nil) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		accumulator.Add(resp)
+	}
+	client.Flush()
+
+	if got := accumulator.Response().Text(); got != "The answer was cut" {
+		t.Errorf("merged text = %q", got)
+	}
+	events := meteringServer.Events()
+	if len(events) != 1 {
+		t.Fatalf("expected 1 metering event, got %d", len(events))
+	}
+	assertPayload(t, events[0], revenium.MeteringEventCompletion, map[string]interface{}{
+		"stopReason":       "TOKEN_LIMIT",
+		"outputTokenCount": float64(4),
+		"outputResponse":   "The answer was cut",
+	})
+}
+
+func TestRequestCoalescing_LeaderCancelled(t *testing.T) {
+	genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+	cfg := GeminiConfig(genaiServer, meteringServer)
+	cfg.CoalesceRequests = true
+	client := NewClient(t, cfg)
+
+	response := TextResponse("Summary", 12, 3)
+	response.Delay = 300 * time.Millisecond
+	genaiServer.Enqueue(MethodGenerateContent, response)
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := client.Models().GenerateContent(leaderCtx, testModel, testContents(), nil)
+		leaderErr <- err
+	}()
+	for deadline := time.Now().Add(time.Second); len(genaiServer.Requests()) == 0 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	waiterResp := make(chan *genai.GenerateContentResponse, 1)
+	go func() {
+		resp, err := client.Models().GenerateContent(context.Background(), testModel, testContents(), nil)
+		if err != nil {
+			t.Errorf("the waiter should not see the leader's cancellation: %v", err)
+		}
+		waiterResp <- resp
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the cancelled caller to get context.Canceled, got %v", err)
+	}
+	if resp := <-waiterResp; resp == nil || resp.Text() != "Summary" {
+		t.Errorf("expected the shared response, got %v", resp)
+	}
+	client.Flush()
+	if len(genaiServer.Requests()) != 1 || len(meteringServer.Events()) != 2 {
+		t.Errorf("expected one Google call and two events, got %d and %d", len(genaiServer.Requests()), len(meteringServer.Events()))
+	}
+}
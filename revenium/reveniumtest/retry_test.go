@@ -0,0 +1,183 @@
+package reveniumtest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/revenium/revenium-middleware-google-go/revenium"
+	"google.golang.org/genai"
+)
+
+func TestGenerationRetry(t *testing.T) {
+	fastRetry := revenium.GenerationRetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	withFallback := fastRetry
+	withFallback.MaxAttempts = 1
+	withFallback.Fallbacks = []revenium.FallbackTarget{{Model: "gemini-2.5-flash"}}
+
+	tests := []struct {
+		name       string
+		policy     revenium.GenerationRetryPolicy
+		responses  []Response
+		wantErr    bool
+		wantModels []string
+	}{
+		{
+			name:       "retry after 503",
+			policy:     fastRetry,
+			responses:  []Response{ErrorResponse(http.StatusServiceUnavailable, "overloaded"), TextResponse("Hello!", 12, 3)},
+			wantModels: []string{testModel, testModel},
+		},
+		{
+			name:       "fallback after 429",
+			policy:     withFallback,
+			responses:  []Response{ErrorResponse(http.StatusTooManyRequests, "quota"), TextResponse("Hello!", 12, 3)},
+			wantModels: []string{testModel, "gemini-2.5-flash"},
+		},
+		{
+			name:       "attempts exhausted",
+			policy:     fastRetry,
+			responses:  []Response{ErrorResponse(http.StatusInternalServerError, "boom"), ErrorResponse(http.StatusInternalServerError, "boom")},
+			wantErr:    true,
+			wantModels: []string{testModel, testModel},
+		},
+		{
+			name:       "client errors are not retried",
+			policy:     withFallback,
+			responses:  []Response{ErrorResponse(http.StatusBadRequest, "invalid argument")},
+			wantErr:    true,
+			wantModels: []string{testModel},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+			cfg := GeminiConfig(genaiServer, meteringServer)
+			cfg.GenerationRetry = tt.policy
+			client := NewClient(t, cfg)
+
+			genaiServer.Enqueue(MethodGenerateContent, tt.responses...)
+			_, err := client.Models().GenerateContent(context.Background(), testModel, testContents(), nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			client.Flush()
+
+			requests := genaiServer.Requests()
+			if len(requests) != len(tt.wantModels) {
+				t.Fatalf("expected %d genai requests, got %d", len(tt.wantModels), len(requests))
+			}
+			for i, request := range requests {
+				if request.Model != tt.wantModels[i] {
+					t.Errorf("attempt %d used model %q, want %q", i, request.Model, tt.wantModels[i])
+				}
+			}
+
+			events := meteringServer.Events()
+			if len(events) != len(tt.wantModels) {
+				t.Fatalf("expected one metering event per attempt, got %d", len(events))
+			}
+			assertAttemptChain(t, events)
+		})
+	}
+}
+
+func TestGenerationRetry_FallbackClient(t *testing.T) {
+	genaiServer, meteringServer, regionServer := NewGenaiServer(t), NewMeteringServer(t), NewGenaiServer(t)
+	regionClient, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		Backend:     genai.BackendVertexAI,
+		Project:     "reveniumtest-project",
+		Location:    "europe-west4",
+		HTTPClient:  regionServer.Client(),
+		HTTPOptions: genai.HTTPOptions{BaseURL: regionServer.URL},
+	})
+	if err != nil {
+		t.Fatalf("failed to create fallback client: %v", err)
+	}
+
+	cfg := GeminiConfig(genaiServer, meteringServer)
+	cfg.GenerationRetry = revenium.GenerationRetryPolicy{Fallbacks: []revenium.FallbackTarget{{Client: regionClient}}}
+	client := NewClient(t, cfg)
+
+	genaiServer.Enqueue(MethodGenerateContent, ErrorResponse(http.StatusServiceUnavailable, "overloaded"))
+	regionServer.Enqueue(MethodGenerateContent, TextResponse("Hello!", 12, 3))
+	resp, receipt, err := client.Models().GenerateContentWithReceipt(context.Background(), testModel, testContents(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client.Flush()
+
+	if len(regionServer.Requests()) != 1 || regionServer.Requests()[0].Model != testModel {
+		t.Fatalf("expected the fallback client to receive the requested model, got %+v", regionServer.Requests())
+	}
+	if receipt == nil || receipt.TransactionID != revenium.ResponseTransactionID(resp) || receipt.Provider != "VERTEX_AI" {
+		t.Errorf("expected the receipt of the successful attempt, got %+v", receipt)
+	}
+
+	events := meteringServer.Events()
+	if len(events) != 2 {
+		t.Fatalf("expected 2 metering events, got %d", len(events))
+	}
+	assertAttemptChain(t, events)
+	if events[0].Payload["provider"] != "GOOGLE_AI" || events[1].Payload["provider"] != "VERTEX_AI" {
+		t.Errorf("expected the provider to follow each attempt's backend, got %v then %v", events[0].Payload["provider"], events[1].Payload["provider"])
+	}
+}
+
+func TestGenerationRetry_StreamBeforeFirstChunk(t *testing.T) {
+	genaiServer, meteringServer := NewGenaiServer(t), NewMeteringServer(t)
+	cfg := GeminiConfig(genaiServer, meteringServer)
+	cfg.GenerationRetry = revenium.GenerationRetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}
+	client := NewClient(t, cfg)
+
+	genaiServer.Enqueue(MethodStreamGenerateContent, ErrorResponse(http.StatusServiceUnavailable, "overloaded"), StreamResponse([]string{"Hel", "lo"}, 8, 4))
+	var text string
+	for resp, err := range client.Models().GenerateContentStream(context.Background(), testModel, testContents(), nil) {
+		if err != nil {
+			t.Fatalf("unexpected stream error: %v", err)
+		}
+		text += resp.Text()
+	}
+	client.Flush()
+
+	if text != "Hello" {
+		t.Errorf("expected the retried stream's text, got %q", text)
+	}
+	events := meteringServer.Events()
+	if len(events) != 2 {
+		t.Fatalf("expected 2 metering events, got %d", len(events))
+	}
+	assertAttemptChain(t, events)
+}
+
+// assertAttemptChain checks that events are numbered attempts linked to the first one
+func assertAttemptChain(t *testing.T, events []RecordedEvent) {
+	t.Helper()
+	byRetry := map[float64]RecordedEvent{}
+	for _, event := range events {
+		retry, _ := event.Payload["retryNumber"].(float64)
+		byRetry[retry] = event
+	}
+	if len(byRetry) != len(events) {
+		t.Fatalf("expected distinct retry numbers, got %d for %d events", len(byRetry), len(events))
+	}
+
+	first := byRetry[0]
+	if _, ok := first.Payload["parentTransactionId"]; ok {
+		t.Errorf("first attempt should have no parent, got %v", first.Payload["parentTransactionId"])
+	}
+	for retry := 1; retry < len(events); retry++ {
+		event, ok := byRetry[float64(retry)]
+		if !ok {
+			t.Fatalf("missing attempt with retryNumber %d", retry)
+		}
+		if event.Payload["parentTransactionId"] != first.Payload["transactionId"] {
+			t.Errorf("attempt %d parent = %v, want %v", retry, event.Payload["parentTransactionId"], first.Payload["transactionId"])
+		}
+		if event.Payload["transactionId"] == first.Payload["transactionId"] {
+			t.Errorf("attempt %d reuses the first transaction ID", retry)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package revenium
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// pendingGroup is a sync.WaitGroup that also counts the goroutines it waits for,
+// so shutdown can report how many metering events were still in flight
+type pendingGroup struct {
+	wg      sync.WaitGroup
+	pending atomic.Int64
+}
+
+// Add adds delta to the group, like sync.WaitGroup.Add
+func (g *pendingGroup) Add(delta int) {
+	g.pending.Add(int64(delta))
+	g.wg.Add(delta)
+}
+
+// Done marks one goroutine as finished
+func (g *pendingGroup) Done() {
+	g.pending.Add(-1)
+	g.wg.Done()
+}
+
+// Wait blocks until every goroutine has finished
+func (g *pendingGroup) Wait() {
+	g.wg.Wait()
+}
+
+// Pending returns the number of goroutines still running
+func (g *pendingGroup) Pending() int {
+	return int(g.pending.Load())
+}
+
+// PendingMetering returns the number of metering events still being built or delivered
+func (r *ReveniumGoogle) PendingMetering() int {
+	return r.wg.Pending()
+}
+
+// Shutdown waits for pending metering events until they are delivered or ctx is done,
+// then releases the client's metering resources. Deliveries run on a context detached
+// from the calls that produced them, each bounded by the RetryPolicy deadline, so
+// cancelling a request never drops its billing data; Shutdown's ctx only limits how
+// long the caller waits.
+//
+// If ctx is done first, Shutdown returns a metering error reporting how many events
+// were still pending (also in Details["pending"]). Those deliveries keep running in
+// the background until their own deadline.
+func (r *ReveniumGoogle) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		r.logger.Debug("All metering requests completed")
+		if r.metering != nil {
+			return r.metering.close()
+		}
+		return nil
+	case <-ctx.Done():
+		pending := r.wg.Pending()
+		r.logger.Warn("Shutdown deadline reached with %d metering events pending", pending)
+		return NewMeteringError(fmt.Sprintf("shutdown deadline reached with %d metering events pending", pending), ctx.Err()).
+			WithDetails("pending", pending)
+	}
+}
@@ -0,0 +1,84 @@
+package revenium
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newShutdownTestClient(t *testing.T, sink MeteringSink) *ReveniumGoogle {
+	t.Helper()
+	client, err := NewReveniumGoogle(&Config{GoogleAPIKey: "google-key", MeteringSink: sink})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return client
+}
+
+// startMetering sends an event the way the model wrappers do
+func startMetering(client *ReveniumGoogle, ctx context.Context) {
+	event := testEvent(MeteringEventCompletion)
+	client.wg.Add(1)
+	go func() {
+		defer client.wg.Done()
+		client.metering.send(ctx, event.Type, event.Payload)
+	}()
+}
+
+func TestShutdown_ReportsPendingOnDeadline(t *testing.T) {
+	release := make(chan struct{})
+	client := newShutdownTestClient(t, MeteringSinkFunc(func(ctx context.Context, event MeteringEvent) error {
+		<-release
+		return nil
+	}))
+
+	startMetering(client, context.Background())
+	startMetering(client, context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := client.Shutdown(ctx)
+	if !IsMeteringError(err) {
+		t.Fatalf("expected a metering error, got %v", err)
+	}
+	if pending := err.(*ReveniumError).Details["pending"]; pending != 2 {
+		t.Errorf("expected 2 pending events, got %v", pending)
+	}
+
+	close(release)
+	if err := client.Shutdown(context.Background()); err != nil {
+		t.Errorf("expected a clean shutdown once deliveries finish, got %v", err)
+	}
+	if client.PendingMetering() != 0 {
+		t.Errorf("expected no pending events, got %d", client.PendingMetering())
+	}
+}
+
+func TestMetering_SurvivesCallerCancellation(t *testing.T) {
+	type delivery struct {
+		err         error
+		deadline    time.Time
+		hasDeadline bool
+	}
+	delivered := make(chan delivery, 1)
+	client := newShutdownTestClient(t, MeteringSinkFunc(func(ctx context.Context, event MeteringEvent) error {
+		deadline, ok := ctx.Deadline()
+		delivered <- delivery{err: ctx.Err(), deadline: deadline, hasDeadline: ok}
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	startMetering(client, ctx)
+	if err := client.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := <-delivered
+	if got.err != nil {
+		t.Errorf("delivery context must not inherit the caller's cancellation, got %v", got.err)
+	}
+	if !got.hasDeadline || time.Until(got.deadline) > DefaultRetryPolicy().Deadline {
+		t.Errorf("expected the delivery context to carry the retry deadline, got %v (set: %v)", got.deadline, got.hasDeadline)
+	}
+}
@@ -0,0 +1,365 @@
+package revenium
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// MeteringEventType identifies the kind of usage a metering event records.
+// The Revenium HTTP sink uses it to pick the metering endpoint.
+type MeteringEventType string
+
+const (
+	// MeteringEventCompletion records a text generation call (GenerateContent, GenerateContentStream)
+	MeteringEventCompletion MeteringEventType = "completion"
+	// MeteringEventImage records an image generation, edit or upscale call
+	MeteringEventImage MeteringEventType = "image"
+	// MeteringEventVideo records a video generation operation
+	MeteringEventVideo MeteringEventType = "video"
+)
+
+// MeteringEvent is one usage record produced by the middleware
+type MeteringEvent struct {
+	Type    MeteringEventType      `json:"type"`
+	Payload map[string]interface{} `json:"payload"`
+	// IdempotencyKey is unique to the event and shared by all its delivery attempts.
+	// Unlike the transaction ID, it differs between calls that reuse a caller's
+	// transactionId.
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+}
+
+// TransactionID returns the event's transaction ID, or "" if it has none
+func (e MeteringEvent) TransactionID() string {
+	id, _ := e.Payload["transactionId"].(string)
+	return id
+}
+
+// idempotencyKey returns the event's idempotency key, falling back to its transaction
+// ID for events built without one
+func (e MeteringEvent) idempotencyKey() string {
+	if e.IdempotencyKey != "" {
+		return e.IdempotencyKey
+	}
+	return e.TransactionID()
+}
+
+// MeteringSink receives metering events. Send is called from background goroutines,
+// possibly concurrently, so implementations must be safe for concurrent use and must
+// not modify the payload.
+// Set a sink with WithMeteringSink; the default sends events to Revenium.
+type MeteringSink interface {
+	Send(ctx context.Context, event MeteringEvent) error
+}
+
+// MeteringSinkFunc adapts a function to the MeteringSink interface, for example to
+// forward usage into your own warehouse
+type MeteringSinkFunc func(ctx context.Context, event MeteringEvent) error
+
+// Send calls f(ctx, event)
+func (f MeteringSinkFunc) Send(ctx context.Context, event MeteringEvent) error {
+	return f(ctx, event)
+}
+
+// meteringEndpoints maps event types to Revenium metering endpoints
+var meteringEndpoints = map[MeteringEventType]string{
+	MeteringEventCompletion: meteringEndpoint,
+	MeteringEventImage:      imageMeteringEndpoint,
+	MeteringEventVideo:      videoMeteringEndpoint,
+}
+
+// reveniumSink posts events to the Revenium metering API, retrying as the
+// configured RetryPolicy allows. Every attempt carries the event's idempotency key.
+type reveniumSink struct {
+	config     func() *Config
+	httpClient *http.Client
+	logger     Logger
+}
+
+// NewReveniumSink returns a sink that posts events to the Revenium metering API with
+// cfg's settings, for combining Revenium with other sinks in NewFanOutSink.
+// If cfg's metering HTTP settings are invalid, every Send returns the error.
+func NewReveniumSink(cfg *Config) MeteringSink {
+	resolved := *cfg
+	if resolved.ReveniumBaseURL == "" {
+		resolved.ReveniumBaseURL = defaultReveniumBaseURL
+	}
+	resolved.ReveniumBaseURL = NormalizeReveniumBaseURL(resolved.ReveniumBaseURL)
+
+	httpClient, err := newMeteringHTTPClient(&resolved)
+	if err != nil {
+		return MeteringSinkFunc(func(ctx context.Context, event MeteringEvent) error { return err })
+	}
+
+	return &reveniumSink{
+		config:     func() *Config { return &resolved },
+		httpClient: httpClient,
+		logger:     newClientLogger(&resolved),
+	}
+}
+
+// Send posts the event, retrying request timeouts, rate limits, server and network
+// errors as the configured RetryPolicy allows
+func (s *reveniumSink) Send(ctx context.Context, event MeteringEvent) error {
+	policy := DefaultRetryPolicy()
+	if config := s.config(); config != nil {
+		policy = config.RetryPolicy.normalized()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, policy.Deadline)
+	defer cancel()
+
+	var lastErr error
+	logger := payloadLogger(s.logger, event.Payload)
+
+	attempts := 0
+	for attempts < policy.MaxAttempts {
+		if attempts > 0 {
+			delay := policy.retryDelay(attempts, lastErr)
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+				logger.Debug("[METERING] Not retrying: waiting %v would pass the retry deadline", delay)
+				break
+			}
+			if err := sleepContext(ctx, delay); err != nil {
+				break
+			}
+		}
+		attempts++
+
+		attemptCtx, cancelAttempt := context.WithTimeout(ctx, policy.AttemptTimeout)
+		err := s.post(attemptCtx, event, withLogFields(logger, LogFieldAttempt, attempts))
+		cancelAttempt()
+		if err == nil {
+			return nil // Success
+		}
+
+		lastErr = err
+
+		// Don't retry on validation or configuration errors
+		if IsValidationError(err) || IsConfigError(err) {
+			return err
+		}
+	}
+
+	return NewMeteringError(fmt.Sprintf("%s metering failed after retries", event.Type), fmt.Errorf("attempts: %d, last error: %w", attempts, lastErr))
+}
+
+// post sends a single metering request to the endpoint for the event's type
+func (s *reveniumSink) post(ctx context.Context, event MeteringEvent, logger Logger) error {
+	config := s.config()
+	if config == nil || config.ReveniumAPIKey == "" {
+		return NewConfigError("metering not configured", nil)
+	}
+
+	endpoint, ok := meteringEndpoints[event.Type]
+	if !ok {
+		return NewValidationError(fmt.Sprintf("unknown metering event type %q", event.Type), nil)
+	}
+	baseURL := config.ReveniumBaseURL
+	if baseURL == "" {
+		baseURL = defaultReveniumBaseURL
+	}
+	url := baseURL + endpoint
+
+	// Marshal payload to JSON
+	jsonData, err := json.Marshal(event.Payload)
+	if err != nil {
+		return NewMeteringError("failed to marshal metering payload", err)
+	}
+
+	// Log the exact payload being sent (unless payloads are redacted)
+	logger.Debug("[METERING] Sending %s payload to %s: %s", event.Type, url, describePayload(config, jsonData))
+
+	requestBody := jsonData
+	compressed := config.MeteringGzip && len(jsonData) >= gzipMinBytes
+	if compressed {
+		if requestBody, err = gzipBody(jsonData); err != nil {
+			return NewMeteringError("failed to compress metering payload", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(requestBody))
+	if err != nil {
+		return NewMeteringError("failed to create metering request", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	req.Header.Set("x-api-key", config.ReveniumAPIKey)
+	req.Header.Set("User-Agent", GetUserAgent())
+	if key := event.idempotencyKey(); key != "" {
+		req.Header.Set(idempotencyKeyHeader, key)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		logger.Error("[METERING] Network error: %v", err)
+		return NewNetworkError("metering request failed", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body for error details
+	body, _ := io.ReadAll(resp.Body)
+
+	logger = withLogFields(logger, LogFieldStatus, resp.StatusCode)
+	logger.Debug("[METERING] Response status: %d, body: %s", resp.StatusCode, string(body))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		logger.Error("[METERING] API error response (status %d): %s", resp.StatusCode, string(body))
+		if !isRetryableStatus(resp.StatusCode) && resp.StatusCode >= 400 {
+			// Validation error - don't retry
+			return NewValidationError(
+				fmt.Sprintf("%s metering API returned %d: %s", event.Type, resp.StatusCode, string(body)),
+				nil,
+			)
+		}
+		meteringErr := NewMeteringError(fmt.Sprintf("%s metering API error", event.Type), fmt.Errorf("status %d: %s", resp.StatusCode, string(body)))
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			meteringErr.WithDetails(retryAfterDetail, delay)
+		}
+		return meteringErr
+	}
+
+	logger.Debug("[METERING] Successfully sent %s metering data (status %d)", event.Type, resp.StatusCode)
+	return nil
+}
+
+// describePayload returns the payload body for logging, or only its size when payloads are redacted
+func describePayload(config *Config, jsonData []byte) string {
+	if config != nil && config.RedactPayloads {
+		return fmt.Sprintf("<redacted, %d bytes>", len(jsonData))
+	}
+	return string(jsonData)
+}
+
+// JSONLSink writes each event as one JSON line, for example to stdout or a file
+type JSONLSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewJSONLSink returns a sink that writes events as JSON lines to w
+func NewJSONLSink(w io.Writer) *JSONLSink {
+	return &JSONLSink{w: w}
+}
+
+// NewStdoutSink returns a sink that writes events as JSON lines to standard output
+func NewStdoutSink() *JSONLSink {
+	return NewJSONLSink(os.Stdout)
+}
+
+// NewJSONLFileSink returns a sink that appends events as JSON lines to the file at
+// path, creating it if needed. Call Close when the client is closed.
+func NewJSONLFileSink(path string) (*JSONLSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, NewConfigError(fmt.Sprintf("failed to open metering file %s", path), err)
+	}
+	return &JSONLSink{w: file, closer: file}, nil
+}
+
+// Send writes the event as a single line
+func (s *JSONLSink) Send(ctx context.Context, event MeteringEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return NewMeteringError("failed to marshal metering event", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(line); err != nil {
+		return NewMeteringError("failed to write metering event", err)
+	}
+	return nil
+}
+
+// Close closes the underlying file for sinks created with NewJSONLFileSink
+func (s *JSONLSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// MemorySink keeps events in memory. It is intended for tests.
+type MemorySink struct {
+	mu     sync.Mutex
+	events []MeteringEvent
+}
+
+// NewMemorySink returns an empty in-memory sink
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// Send records the event
+func (s *MemorySink) Send(ctx context.Context, event MeteringEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+// Events returns a copy of the recorded events in the order they were sent
+func (s *MemorySink) Events() []MeteringEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]MeteringEvent(nil), s.events...)
+}
+
+// Len returns the number of recorded events
+func (s *MemorySink) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events)
+}
+
+// Reset discards the recorded events
+func (s *MemorySink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = nil
+}
+
+// fanOutSink sends every event to several sinks
+type fanOutSink struct {
+	sinks []MeteringSink
+}
+
+// NewFanOutSink returns a sink that sends every event to each of sinks concurrently.
+// Every sink receives the event even if another fails; the failures are combined
+// into the returned error.
+func NewFanOutSink(sinks ...MeteringSink) MeteringSink {
+	return &fanOutSink{sinks: sinks}
+}
+
+// Send delivers the event to every sink and waits for all of them
+func (s *fanOutSink) Send(ctx context.Context, event MeteringEvent) error {
+	errs := make([]error, len(s.sinks))
+
+	var wg sync.WaitGroup
+	for i, sink := range s.sinks {
+		wg.Add(1)
+		go func(i int, sink MeteringSink) {
+			defer wg.Done()
+			errs[i] = sink.Send(ctx, event)
+		}(i, sink)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return NewMeteringError("one or more metering sinks failed", err)
+	}
+	return nil
+}
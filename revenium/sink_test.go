@@ -0,0 +1,149 @@
+package revenium
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func testEvent(eventType MeteringEventType) MeteringEvent {
+	return MeteringEvent{
+		Type:    eventType,
+		Payload: map[string]interface{}{"model": "gemini-2.0-flash", "transactionId": "txn-1"},
+	}
+}
+
+func TestReveniumSink_Endpoints(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "hak_sink_test" {
+			t.Errorf("unexpected API key %q", r.Header.Get("x-api-key"))
+		}
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	sink := NewReveniumSink(&Config{ReveniumAPIKey: "hak_sink_test", ReveniumBaseURL: server.URL})
+	for _, eventType := range []MeteringEventType{MeteringEventCompletion, MeteringEventImage, MeteringEventVideo} {
+		if err := sink.Send(context.Background(), testEvent(eventType)); err != nil {
+			t.Fatalf("%s: unexpected error: %v", eventType, err)
+		}
+	}
+
+	want := []string{meteringEndpoint, imageMeteringEndpoint, videoMeteringEndpoint}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Errorf("expected paths %v, got %v", want, paths)
+	}
+}
+
+func TestReveniumSink_Retries(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		wantAttempts int32
+		wantErr      func(error) bool
+	}{
+		{"server errors are retried", http.StatusBadGateway, 3, IsMeteringError},
+		{"client errors are not retried", http.StatusBadRequest, 1, IsValidationError},
+		{"rate limits are retried", http.StatusTooManyRequests, 3, IsMeteringError},
+		{"request timeouts are retried", http.StatusRequestTimeout, 3, IsMeteringError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			sink := NewReveniumSink(&Config{ReveniumAPIKey: "hak_sink_test", ReveniumBaseURL: server.URL})
+			err := sink.Send(context.Background(), testEvent(MeteringEventCompletion))
+			if !tt.wantErr(err) {
+				t.Errorf("unexpected error type: %v", err)
+			}
+			if attempts.Load() != tt.wantAttempts {
+				t.Errorf("expected %d attempts, got %d", tt.wantAttempts, attempts.Load())
+			}
+		})
+	}
+}
+
+func TestJSONLSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewJSONLSink(&buf)
+	sink.Send(context.Background(), testEvent(MeteringEventCompletion))
+	sink.Send(context.Background(), testEvent(MeteringEventImage))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(lines), buf.String())
+	}
+	var event MeteringEvent
+	if err := json.Unmarshal([]byte(lines[1]), &event); err != nil {
+		t.Fatalf("line is not valid JSON: %v", err)
+	}
+	if event.Type != MeteringEventImage || event.TransactionID() != "txn-1" {
+		t.Errorf("unexpected event: %+v", event)
+	}
+
+	path := filepath.Join(t.TempDir(), "metering.jsonl")
+	fileSink, err := NewJSONLFileSink(path)
+	if err != nil {
+		t.Fatalf("failed to open file sink: %v", err)
+	}
+	fileSink.Send(context.Background(), testEvent(MeteringEventVideo))
+	fileSink.Close()
+
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `"type":"video"`) {
+		t.Errorf("file sink did not write the event: %q", data)
+	}
+}
+
+func TestFanOutSink(t *testing.T) {
+	first, second := NewMemorySink(), NewMemorySink()
+	failing := MeteringSinkFunc(func(ctx context.Context, event MeteringEvent) error {
+		return errors.New("warehouse unavailable")
+	})
+
+	err := NewFanOutSink(first, failing, second).Send(context.Background(), testEvent(MeteringEventCompletion))
+	if !IsMeteringError(err) || !strings.Contains(err.Error(), "warehouse unavailable") {
+		t.Errorf("expected the failing sink's error, got %v", err)
+	}
+	if first.Len() != 1 || second.Len() != 1 {
+		t.Errorf("every sink should receive the event: first=%d second=%d", first.Len(), second.Len())
+	}
+}
+
+func TestWithMeteringSink_NoReveniumAccount(t *testing.T) {
+	sink := NewMemorySink()
+	client, err := New(context.Background(),
+		WithGoogleAPIKey("google-key"),
+		WithReveniumAPIKey(""),
+		WithMeteringSink(sink),
+	)
+	if err != nil {
+		t.Fatalf("a custom sink should not require a Revenium API key: %v", err)
+	}
+
+	client.metering.send(context.Background(), MeteringEventImage, map[string]interface{}{"model": "imagen"})
+	events := sink.Events()
+	if len(events) != 1 || events[0].Type != MeteringEventImage {
+		t.Errorf("expected one image event, got %+v", events)
+	}
+}
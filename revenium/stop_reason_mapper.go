@@ -41,6 +41,11 @@ const (
 // - Handles empty strings gracefully
 // - Gracefully maps unknown/future Google values with warning
 func MapGoogleFinishReason(finishReason genai.FinishReason, defaultReason ReveniumStopReason) ReveniumStopReason {
+	return mapGoogleFinishReason(finishReason, defaultReason, GetLogger())
+}
+
+// mapGoogleFinishReason is MapGoogleFinishReason warning about unknown values on logger
+func mapGoogleFinishReason(finishReason genai.FinishReason, defaultReason ReveniumStopReason, logger Logger) ReveniumStopReason {
 	// Handle empty finish reason
 	if finishReason == "" {
 		return defaultReason
@@ -87,7 +92,7 @@ func MapGoogleFinishReason(finishReason genai.FinishReason, defaultReason Reveni
 
 	// Unknown finish reason (future-proof for new Google values)
 	default:
-		Warn("Unknown finishReason: %q. Using fallback: %q. Please report this to support@revenium.io if this is a new Google AI value.", finishReason, defaultReason)
+		logger.Warn("Unknown finishReason: %q. Using fallback: %q. Please report this to support@revenium.io if this is a new Google AI value.", finishReason, defaultReason)
 		return defaultReason
 	}
 }
@@ -0,0 +1,141 @@
+package revenium
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+)
+
+const streamStatsHookKey contextKey = "revenium_stream_stats_hook"
+
+// defaultStreamStallThreshold is the inter-chunk gap counted as a stall when no
+// threshold is configured
+const defaultStreamStallThreshold = 5 * time.Second
+
+// meteringTimeFormat is RFC 3339 with milliseconds, so sub-second timing survives
+const meteringTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// formatMeteringTime formats t in UTC for metering payloads
+func formatMeteringTime(t time.Time) string {
+	return t.UTC().Format(meteringTimeFormat)
+}
+
+// WithStreamStallThreshold sets the gap between stream chunks counted as a stall
+func WithStreamStallThreshold(threshold time.Duration) Option {
+	return func(c *Config) {
+		c.StreamStallThreshold = threshold
+	}
+}
+
+// StreamStats describes the user-visible latency of one GenerateContentStream call
+type StreamStats struct {
+	// TransactionID is the transaction ID of the stream's metering event
+	TransactionID string
+	// Model is the model that served the stream
+	Model string
+	// ChunkCount is the number of chunks received
+	ChunkCount int
+	// TimeToFirstChunk is the delay from the request to the first chunk
+	TimeToFirstChunk time.Duration
+	// Duration is the delay from the request to the end of the stream
+	Duration time.Duration
+	// MeanChunkGap, P95ChunkGap and MaxChunkGap describe the gaps between chunks
+	MeanChunkGap time.Duration
+	P95ChunkGap  time.Duration
+	MaxChunkGap  time.Duration
+	// OutputTokensPerSecond is the output and reasoning token rate from the first
+	// chunk to the end of the stream (zero when it cannot be measured)
+	OutputTokensPerSecond float64
+	// StallCount is the number of gaps longer than the stall threshold
+	StallCount int
+	// StallThreshold is the gap counted as a stall
+	StallThreshold time.Duration
+}
+
+// attributes returns the stats as metering payload attributes, durations in
+// milliseconds
+func (s StreamStats) attributes() map[string]interface{} {
+	return map[string]interface{}{
+		"streamChunkCount":            s.ChunkCount,
+		"streamMeanChunkGapMs":        durationMillis(s.MeanChunkGap),
+		"streamP95ChunkGapMs":         durationMillis(s.P95ChunkGap),
+		"streamMaxChunkGapMs":         durationMillis(s.MaxChunkGap),
+		"streamOutputTokensPerSecond": math.Round(s.OutputTokensPerSecond*100) / 100,
+		"streamStallCount":            s.StallCount,
+		"streamStalled":               s.StallCount > 0,
+	}
+}
+
+// durationMillis returns d in milliseconds with microsecond precision
+func durationMillis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// WithStreamStatsHook returns a context that makes every GenerateContentStream call
+// made with it invoke hook with the stream's latency statistics once the stream
+// ends. The hook runs on the consumer's goroutine, so it should return quickly.
+func WithStreamStatsHook(ctx context.Context, hook func(stats StreamStats)) context.Context {
+	return context.WithValue(ctx, streamStatsHookKey, hook)
+}
+
+// streamStatsHook returns the hook set with WithStreamStatsHook, or nil
+func streamStatsHook(ctx context.Context) func(stats StreamStats) {
+	hook, _ := ctx.Value(streamStatsHookKey).(func(stats StreamStats))
+	return hook
+}
+
+// streamTimer records when the chunks of a stream arrive
+type streamTimer struct {
+	start time.Time
+	first time.Time
+	last  time.Time
+	gaps  []time.Duration
+}
+
+// chunk records a chunk received at now
+func (t *streamTimer) chunk(now time.Time) {
+	if t.first.IsZero() {
+		t.first = now
+	} else {
+		t.gaps = append(t.gaps, now.Sub(t.last))
+	}
+	t.last = now
+}
+
+// stats summarizes the stream ended at end that generated outputTokens tokens
+func (t *streamTimer) stats(end time.Time, outputTokens int64, stallThreshold time.Duration) StreamStats {
+	if stallThreshold <= 0 {
+		stallThreshold = defaultStreamStallThreshold
+	}
+	stats := StreamStats{
+		ChunkCount:     len(t.gaps),
+		Duration:       end.Sub(t.start),
+		StallThreshold: stallThreshold,
+	}
+	if t.first.IsZero() {
+		return stats
+	}
+	stats.ChunkCount++
+	stats.TimeToFirstChunk = t.first.Sub(t.start)
+	if generating := end.Sub(t.first); generating > 0 && outputTokens > 0 {
+		stats.OutputTokensPerSecond = float64(outputTokens) / generating.Seconds()
+	}
+	if len(t.gaps) == 0 {
+		return stats
+	}
+
+	sorted := append([]time.Duration(nil), t.gaps...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, gap := range sorted {
+		total += gap
+		if gap > stallThreshold {
+			stats.StallCount++
+		}
+	}
+	stats.MeanChunkGap = total / time.Duration(len(sorted))
+	stats.P95ChunkGap = sorted[int(math.Ceil(0.95*float64(len(sorted))))-1]
+	stats.MaxChunkGap = sorted[len(sorted)-1]
+	return stats
+}
@@ -0,0 +1,48 @@
+package revenium
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStreamTimer_Stats(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	timer := &streamTimer{start: start}
+	offsets := []time.Duration{400, 450, 500, 550, 600, 3600}
+	for _, offset := range offsets {
+		timer.chunk(start.Add(offset * time.Millisecond))
+	}
+
+	stats := timer.stats(start.Add(4400*time.Millisecond), 200, 2*time.Second)
+	want := StreamStats{
+		ChunkCount:            6,
+		TimeToFirstChunk:      400 * time.Millisecond,
+		Duration:              4400 * time.Millisecond,
+		MeanChunkGap:          640 * time.Millisecond,
+		P95ChunkGap:           3 * time.Second,
+		MaxChunkGap:           3 * time.Second,
+		OutputTokensPerSecond: 50,
+		StallCount:            1,
+		StallThreshold:        2 * time.Second,
+	}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+}
+
+func TestStreamTimer_NoChunks(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	timer := &streamTimer{start: start}
+
+	stats := timer.stats(start.Add(time.Second), 0, 0)
+	if stats.ChunkCount != 0 || stats.Duration != time.Second || stats.StallThreshold != defaultStreamStallThreshold {
+		t.Errorf("unexpected stats for an empty stream: %+v", stats)
+	}
+}
+
+func TestFormatMeteringTime(t *testing.T) {
+	at := time.Date(2025, 1, 1, 12, 0, 0, 7_500_000, time.FixedZone("CET", 3600))
+	if got := formatMeteringTime(at); got != "2025-01-01T11:00:00.007Z" {
+		t.Errorf("formatMeteringTime() = %q", got)
+	}
+}
@@ -0,0 +1,82 @@
+package revenium
+
+import (
+	"encoding/json"
+
+	"google.golang.org/genai"
+)
+
+// tokenCountEstimatedAttribute marks events whose token counts were estimated locally
+// because Google reported no usage metadata
+const tokenCountEstimatedAttribute = "tokenCountEstimated"
+
+// imageTokenEstimate is the token count Gemini charges for an inline image
+const imageTokenEstimate = 258
+
+// charsPerToken is the average length of a Gemini token in characters of English text
+const charsPerToken = 4
+
+// estimateTextTokens estimates the tokens of chars characters of text
+func estimateTextTokens(chars int64) int64 {
+	return (chars + charsPerToken - 1) / charsPerToken
+}
+
+// estimatePromptTokens estimates the input tokens of a request: about four
+// characters per text token, counting function calls and responses, code and tool
+// declarations as their JSON, and a fixed count per inline image or file
+func estimatePromptTokens(contents []*genai.Content, config *genai.GenerateContentConfig) int64 {
+	var chars, tokens int64
+	countParts := func(content *genai.Content) {
+		if content == nil {
+			return
+		}
+		for _, part := range content.Parts {
+			if part == nil {
+				continue
+			}
+			chars += int64(len(part.Text))
+			if part.FunctionCall != nil {
+				chars += int64(len(part.FunctionCall.Name) + len(marshalCaptureValue(part.FunctionCall.Args)))
+			}
+			if part.FunctionResponse != nil {
+				chars += int64(len(part.FunctionResponse.Name) + len(marshalCaptureValue(part.FunctionResponse.Response)))
+			}
+			if part.ExecutableCode != nil {
+				chars += int64(len(part.ExecutableCode.Code))
+			}
+			if part.CodeExecutionResult != nil {
+				chars += int64(len(part.CodeExecutionResult.Output))
+			}
+			if part.InlineData != nil || part.FileData != nil {
+				tokens += imageTokenEstimate
+			}
+		}
+	}
+
+	for _, content := range contents {
+		countParts(content)
+	}
+	if config != nil {
+		countParts(config.SystemInstruction)
+		if len(config.Tools) > 0 {
+			if declarations, err := json.Marshal(config.Tools); err == nil {
+				chars += int64(len(declarations))
+			}
+		}
+	}
+	return tokens + estimateTextTokens(chars)
+}
+
+// estimateStreamUsage estimates the usage of a stream that ended without usage
+// metadata, from the request and the characters of output and thoughts received
+func estimateStreamUsage(contents []*genai.Content, config *genai.GenerateContentConfig, outputChars, thoughtChars int64) *genai.GenerateContentResponseUsageMetadata {
+	prompt := int32(estimatePromptTokens(contents, config))
+	output := int32(estimateTextTokens(outputChars))
+	thoughts := int32(estimateTextTokens(thoughtChars))
+	return &genai.GenerateContentResponseUsageMetadata{
+		PromptTokenCount:     prompt,
+		CandidatesTokenCount: output,
+		ThoughtsTokenCount:   thoughts,
+		TotalTokenCount:      prompt + output + thoughts,
+	}
+}
@@ -0,0 +1,143 @@
+package revenium
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestEstimateStreamUsage(t *testing.T) {
+	usage := estimateStreamUsage(genai.Text("Say hello"), nil, 7, 10)
+
+	if usage.PromptTokenCount != 3 || usage.CandidatesTokenCount != 2 || usage.ThoughtsTokenCount != 3 || usage.TotalTokenCount != 8 {
+		t.Errorf("unexpected estimate %+v", usage)
+	}
+}
+
+func TestEstimatePromptTokens_ToolsAndFunctionParts(t *testing.T) {
+	// Function parts count their name and JSON: "searchdb" plus 32 bytes of JSON each
+	args := map[string]any{"query": strings.Repeat("q", 20)}
+	response := map[string]any{"result": strings.Repeat("r", 19)}
+	tools := []*genai.Tool{{FunctionDeclarations: []*genai.FunctionDeclaration{{Name: "lookup"}}}}
+	toolsJSON, _ := json.Marshal(tools)
+
+	tests := []struct {
+		name       string
+		part       *genai.Part
+		config     *genai.GenerateContentConfig
+		extraChars int
+	}{
+		{"text only", nil, nil, 0},
+		{"function call", &genai.Part{FunctionCall: &genai.FunctionCall{Name: "searchdb", Args: args}}, nil, 40},
+		{"function response", &genai.Part{FunctionResponse: &genai.FunctionResponse{Name: "searchdb", Response: response}}, nil, 40},
+		{"executable code", &genai.Part{ExecutableCode: &genai.ExecutableCode{Code: strings.Repeat("c", 40)}}, nil, 40},
+		{"tool declarations", nil, &genai.GenerateContentConfig{Tools: tools}, len(toolsJSON)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contents := genai.Text("Say hello")
+			if tt.part != nil {
+				contents = append(contents, &genai.Content{Role: genai.RoleModel, Parts: []*genai.Part{tt.part}})
+			}
+			want := estimateTextTokens(int64(len("Say hello") + tt.extraChars))
+			if got := estimatePromptTokens(contents, tt.config); got != want {
+				t.Errorf("estimatePromptTokens() = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+// newStreamTestClient returns a client whose Gemini API streams chunks and whose
+// metering events are recorded in the returned sink
+func newStreamTestClient(t *testing.T, chunks ...string) (*ReveniumGoogle, *MemorySink) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "%s\n\n", chunk)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	sink := NewMemorySink()
+	client, err := NewReveniumGoogle(&Config{
+		GoogleAPIKey:   "google-key",
+		GoogleBaseURL:  server.URL,
+		VertexDisabled: true,
+		MeteringSink:   sink,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, sink
+}
+
+func TestGenerateContentStream_EstimatesUsageWithoutMetadata(t *testing.T) {
+	textChunk := func(text string) string {
+		return fmt.Sprintf(`data: {"candidates":[{"content":{"role":"model","parts":[{"text":%q}]}}]}`, text)
+	}
+	errorChunk := `{"error":{"code":503,"message":"model overloaded","status":"UNAVAILABLE"}}`
+	contents := []*genai.Content{
+		genai.NewContentFromText("Look up the order", genai.RoleUser),
+		{Role: genai.RoleModel, Parts: []*genai.Part{genai.NewPartFromFunctionCall("lookup", map[string]any{"orderId": "A-1234"})}},
+		{Role: genai.RoleUser, Parts: []*genai.Part{genai.NewPartFromFunctionResponse("lookup", map[string]any{"status": "shipped"})}},
+	}
+
+	tests := []struct {
+		name      string
+		chunks    []string
+		stopAfter int
+		wantErr   bool
+		wantText  string
+	}{
+		{"consumer stops early", []string{textChunk("Your order "), textChunk("has shipped.")}, 1, false, "Your order "},
+		{"error mid-stream", []string{textChunk("Your order "), errorChunk}, 0, true, "Your order "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, sink := newStreamTestClient(t, tt.chunks...)
+
+			var text strings.Builder
+			var streamErr error
+			chunks := 0
+			for resp, err := range client.Models().GenerateContentStream(context.Background(), "gemini-2.0-flash", contents, nil) {
+				if err != nil {
+					streamErr = err
+					break
+				}
+				text.WriteString(resp.Text())
+				if chunks++; chunks == tt.stopAfter {
+					break
+				}
+			}
+			client.Flush()
+
+			if (streamErr != nil) != tt.wantErr || text.String() != tt.wantText {
+				t.Fatalf("got text %q and error %v", text.String(), streamErr)
+			}
+			events := sink.Events()
+			if len(events) != 1 {
+				t.Fatalf("expected 1 metering event, got %d", len(events))
+			}
+			payload := events[0].Payload
+			wantInput := estimatePromptTokens(contents, nil)
+			if payload["inputTokenCount"] != wantInput || payload["outputTokenCount"] != estimateTextTokens(int64(len(tt.wantText))) {
+				t.Errorf("expected estimated usage of %d input tokens, got %v input and %v output",
+					wantInput, payload["inputTokenCount"], payload["outputTokenCount"])
+			}
+			if attributes, _ := payload["attributes"].(map[string]interface{}); attributes[tokenCountEstimatedAttribute] != true {
+				t.Errorf("expected %s, got %v", tokenCountEstimatedAttribute, payload["attributes"])
+			}
+		})
+	}
+}
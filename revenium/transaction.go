@@ -0,0 +1,131 @@
+package revenium
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+const (
+	// TransactionIDHeader is the header added to genai responses' SDKHTTPResponse
+	// carrying the Revenium transaction ID of the call that produced them
+	TransactionIDHeader = "X-Revenium-Transaction-Id"
+
+	// idempotencyKeyHeader carries the event's idempotency key on every metering
+	// delivery attempt so Revenium can discard duplicates when a retried request had
+	// already been recorded
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	// operationTransactionIDKey is the GenerateVideosOperation metadata key carrying
+	// the transaction ID of the GenerateVideos call
+	operationTransactionIDKey = "reveniumTransactionId"
+)
+
+// newTransactionID returns a random UUIDv7 (RFC 9562). The leading 48 bits are the
+// Unix time in milliseconds, so IDs sort by creation time; the remaining 74 bits are
+// random, so concurrent calls never collide in practice.
+func newTransactionID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[6:]); err != nil {
+		// crypto/rand does not fail on supported platforms; fall back to the clock
+		binary.BigEndian.PutUint64(id[8:], uint64(time.Now().UnixNano()))
+	}
+
+	ms := uint64(time.Now().UnixMilli())
+	id[0] = byte(ms >> 40)
+	id[1] = byte(ms >> 32)
+	id[2] = byte(ms >> 24)
+	id[3] = byte(ms >> 16)
+	id[4] = byte(ms >> 8)
+	id[5] = byte(ms)
+	id[6] = id[6]&0x0f | 0x70 // version 7
+	id[8] = id[8]&0x3f | 0x80 // RFC 9562 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+}
+
+// withTransactionID returns a copy of metadata whose transactionId is set, keeping one
+// supplied by the caller and generating one otherwise. Every metering event of a
+// logical call is built from the returned metadata, so retries reuse the same ID.
+// A caller-supplied ID is shared by every call made with the same metadata; each
+// event still gets its own idempotency key.
+func withTransactionID(metadata map[string]interface{}) (map[string]interface{}, string) {
+	withID := make(map[string]interface{}, len(metadata)+1)
+	for key, value := range metadata {
+		withID[key] = value
+	}
+
+	if id, ok := withID["transactionId"].(string); ok && id != "" {
+		return withID, id
+	}
+	id := newTransactionID()
+	withID["transactionId"] = id
+	return withID, id
+}
+
+// withOperationTransactionID returns a copy of metadata carrying the transaction ID
+// GenerateVideos stamped on operation, or metadata itself when there is none
+func withOperationTransactionID(metadata map[string]interface{}, operation *genai.GenerateVideosOperation) map[string]interface{} {
+	id := ResponseTransactionID(operation)
+	if id == "" {
+		return metadata
+	}
+	withID := make(map[string]interface{}, len(metadata)+1)
+	for key, value := range metadata {
+		withID[key] = value
+	}
+	withID["transactionId"] = id
+	return withID
+}
+
+// stampTransactionID records id in the response's SDKHTTPResponse headers
+func stampTransactionID(resp *genai.HTTPResponse, id string) *genai.HTTPResponse {
+	if resp == nil {
+		resp = &genai.HTTPResponse{}
+	}
+	if resp.Headers == nil {
+		resp.Headers = http.Header{}
+	}
+	resp.Headers.Set(TransactionIDHeader, id)
+	return resp
+}
+
+// ResponseTransactionID returns the Revenium transaction ID of the call that produced
+// resp, so application logs can be joined to Revenium records. It accepts the
+// responses returned by Models() and Images() (including every streamed chunk) and the
+// operation returned by Videos().GenerateVideos; it returns "" for anything else.
+func ResponseTransactionID(resp interface{}) string {
+	var httpResp *genai.HTTPResponse
+	switch r := resp.(type) {
+	case *genai.GenerateContentResponse:
+		if r != nil {
+			httpResp = r.SDKHTTPResponse
+		}
+	case *genai.GenerateImagesResponse:
+		if r != nil {
+			httpResp = r.SDKHTTPResponse
+		}
+	case *genai.EditImageResponse:
+		if r != nil {
+			httpResp = r.SDKHTTPResponse
+		}
+	case *genai.UpscaleImageResponse:
+		if r != nil {
+			httpResp = r.SDKHTTPResponse
+		}
+	case *genai.GenerateVideosOperation:
+		if r != nil {
+			id, _ := r.Metadata[operationTransactionIDKey].(string)
+			return id
+		}
+	}
+
+	if httpResp == nil {
+		return ""
+	}
+	return httpResp.Headers.Get(TransactionIDHeader)
+}
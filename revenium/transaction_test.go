@@ -0,0 +1,97 @@
+package revenium
+
+import (
+	"regexp"
+	"sync"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+var uuidV7Pattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNewTransactionID_UniqueUUIDv7(t *testing.T) {
+	const goroutines, perGoroutine = 8, 1000
+
+	var mu sync.Mutex
+	seen := make(map[string]bool, goroutines*perGoroutine)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				id := newTransactionID()
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("duplicate transaction ID %s", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for id := range seen {
+		if !uuidV7Pattern.MatchString(id) {
+			t.Fatalf("%q is not a UUIDv7", id)
+		}
+	}
+}
+
+func TestWithTransactionID(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]interface{}
+		wantID   string
+	}{
+		{"generates an ID", map[string]interface{}{"organizationId": "org-1"}, ""},
+		{"keeps the caller's ID", map[string]interface{}{"transactionId": "txn-from-caller"}, "txn-from-caller"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata, id := withTransactionID(tt.metadata)
+			if tt.wantID != "" && id != tt.wantID {
+				t.Errorf("expected ID %q, got %q", tt.wantID, id)
+			}
+			if tt.wantID == "" && !uuidV7Pattern.MatchString(id) {
+				t.Errorf("expected a generated UUIDv7, got %q", id)
+			}
+			if metadata["transactionId"] != id {
+				t.Errorf("metadata transactionId = %v, want %q", metadata["transactionId"], id)
+			}
+			if tt.wantID == "" {
+				if _, ok := tt.metadata["transactionId"]; ok {
+					t.Error("the caller's metadata map must not be modified")
+				}
+			}
+		})
+	}
+}
+
+func TestResponseTransactionID(t *testing.T) {
+	resp := &genai.GenerateContentResponse{}
+	resp.SDKHTTPResponse = stampTransactionID(resp.SDKHTTPResponse, "txn-1")
+
+	tests := []struct {
+		name string
+		resp interface{}
+		want string
+	}{
+		{"stamped response", resp, "txn-1"},
+		{"unstamped response", &genai.GenerateImagesResponse{}, ""},
+		{"video operation", &genai.GenerateVideosOperation{Metadata: map[string]any{operationTransactionIDKey: "txn-2"}}, "txn-2"},
+		{"nil response", (*genai.GenerateContentResponse)(nil), ""},
+		{"unsupported type", "txn-3", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResponseTransactionID(tt.resp); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
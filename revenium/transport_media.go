@@ -0,0 +1,353 @@
+package revenium
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// mediaRequest is the part of an Imagen predict, Veo predictLongRunning or Vertex AI
+// fetchPredictOperation request the transport reads
+type mediaRequest struct {
+	Instances  []map[string]json.RawMessage `json:"instances"`
+	Parameters struct {
+		SampleCount int `json:"sampleCount"`
+	} `json:"parameters"`
+	OperationName string `json:"operationName"`
+}
+
+// requestedCount returns the number of images or videos requested (1 by default)
+func (r mediaRequest) requestedCount() int {
+	if r.Parameters.SampleCount > 0 {
+		return r.Parameters.SampleCount
+	}
+	return 1
+}
+
+// isImageRequest reports whether a predict request generates, edits or upscales
+// images. Vertex AI also serves embeddings through predict; those are not metered.
+func (r mediaRequest) isImageRequest() bool {
+	if len(r.Instances) == 0 {
+		return false
+	}
+	for _, field := range []string{"prompt", "image", "referenceImages"} {
+		if _, ok := r.Instances[0][field]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// videoOperation is the part of a long-running video operation the transport reads
+type videoOperation struct {
+	Name  string `json:"name"`
+	Done  bool   `json:"done"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	Response struct {
+		GenerateVideoResponse struct {
+			GeneratedSamples      []json.RawMessage `json:"generatedSamples"`
+			RAIMediaFilteredCount int32             `json:"raiMediaFilteredCount"`
+		} `json:"generateVideoResponse"`
+		Videos                []json.RawMessage `json:"videos"`
+		RAIMediaFilteredCount int32             `json:"raiMediaFilteredCount"`
+	} `json:"response"`
+}
+
+// isMediaMethod reports whether a request is an Imagen or Veo call metered by
+// roundTripMedia rather than from usageMetadata
+func isMediaMethod(req *http.Request, method string) bool {
+	return method == "predict" || method == "predictLongRunning" || isOperationPoll(req, method)
+}
+
+// isOperationPoll reports whether a request may poll a video operation: a GET of an
+// operation under a model on the Gemini API, or fetchPredictOperation on Vertex AI.
+// roundTripMedia meters only polls of Veo models or of operations it saw start.
+func isOperationPoll(req *http.Request, method string) bool {
+	if method == "fetchPredictOperation" {
+		return true
+	}
+	return req.Method == http.MethodGet && strings.Contains(req.URL.Path, "/models/") && strings.Contains(req.URL.Path, "/operations/")
+}
+
+// isVideoModel reports whether model is a Veo model
+func isVideoModel(model string) bool {
+	return strings.HasPrefix(model, "veo")
+}
+
+// Bounds of the video operations the transport remembers
+const (
+	operationTrackerTTL        = 6 * time.Hour
+	operationTrackerMaxEntries = 10000
+)
+
+// operationTracker remembers the video operations started through the transport and
+// the operations already metered as done, so every operation is metered done once.
+// Entries expire after operationTrackerTTL; beyond operationTrackerMaxEntries the
+// oldest are evicted.
+type operationTracker struct {
+	mu      sync.Mutex
+	order   *list.List // *trackedOperation, oldest first
+	entries map[string]*list.Element
+	now     func() time.Time
+}
+
+// trackedOperation is one operation known to an operationTracker
+type trackedOperation struct {
+	name          string
+	transactionID string // of the predictLongRunning call that started it
+	done          bool
+	expires       time.Time
+}
+
+// newOperationTracker returns an empty tracker
+func newOperationTracker() *operationTracker {
+	return &operationTracker{order: list.New(), entries: map[string]*list.Element{}, now: time.Now}
+}
+
+// start records an operation returned by the predictLongRunning call with the given
+// transaction ID
+func (t *operationTracker) start(name, transactionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.evict()
+	if _, ok := t.entries[name]; !ok {
+		t.add(name, transactionID, false)
+	}
+}
+
+// started reports whether name was started through the transport and is not done
+func (t *operationTracker) started(name string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.evict()
+	el, ok := t.entries[name]
+	return ok && !el.Value.(*trackedOperation).done
+}
+
+// finish records that name was metered as done, dropping its start entry, and returns
+// the transaction ID it was started with. It returns false when the operation had
+// already been metered as done.
+func (t *operationTracker) finish(name string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.evict()
+	transactionID := ""
+	if el, ok := t.entries[name]; ok {
+		tracked := el.Value.(*trackedOperation)
+		if tracked.done {
+			return "", false
+		}
+		transactionID = tracked.transactionID
+		t.order.Remove(el)
+		delete(t.entries, name)
+	}
+	t.add(name, "", true)
+	return transactionID, true
+}
+
+// size returns the number of operations remembered
+func (t *operationTracker) size() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.entries)
+}
+
+// add appends an entry. Must be called with t.mu held.
+func (t *operationTracker) add(name, transactionID string, done bool) {
+	for len(t.entries) >= operationTrackerMaxEntries {
+		t.removeOldest()
+	}
+	t.entries[name] = t.order.PushBack(&trackedOperation{name: name, transactionID: transactionID, done: done, expires: t.now().Add(operationTrackerTTL)})
+}
+
+// evict drops expired entries. Must be called with t.mu held.
+func (t *operationTracker) evict() {
+	now := t.now()
+	for front := t.order.Front(); front != nil && !now.Before(front.Value.(*trackedOperation).expires); front = t.order.Front() {
+		t.removeOldest()
+	}
+}
+
+// removeOldest drops the oldest entry. Must be called with t.mu held.
+func (t *operationTracker) removeOldest() {
+	front := t.order.Front()
+	t.order.Remove(front)
+	delete(t.entries, front.Value.(*trackedOperation).name)
+}
+
+// pollOperationName returns the name of the operation a poll request refers to
+func pollOperationName(req *http.Request, request mediaRequest) string {
+	if request.OperationName != "" {
+		return request.OperationName
+	}
+	// Gemini API polls GET /v1beta/{name}
+	_, name, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/"), "/")
+	return name
+}
+
+// roundTripMedia forwards an Imagen or Veo request and meters it the way the Images
+// and Videos wrappers do: one image event per predict call, one video event when a
+// generation starts and one when its operation is first seen done
+func (t *meteringRoundTripper) roundTripMedia(req *http.Request, model, method string) (*http.Response, error) {
+	var request mediaRequest
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		json.Unmarshal(body, &request)
+	}
+	if method == "predict" && !request.isImageRequest() {
+		return t.next.RoundTrip(req)
+	}
+	if strings.Contains(req.URL.Path, "/operations/") {
+		// Gemini API operation paths look like /v1beta/models/veo-2.0/operations/id
+		_, rest, _ := strings.Cut(req.URL.Path, "/models/")
+		model, _, _ = strings.Cut(rest, "/")
+	}
+	pollName := ""
+	if isOperationPoll(req, method) {
+		// Tuning, file and batch operations are not video generations
+		pollName = pollOperationName(req, request)
+		if !isVideoModel(model) && !t.operations.started(pollName) {
+			return t.next.RoundTrip(req)
+		}
+	}
+
+	ctx := req.Context()
+	metadata, transactionID := withTransactionID(GetUsageMetadata(ctx))
+	requestTime := time.Now()
+	images := &ImagesInterface{provider: t.parent.GetProvider(), logger: t.parent.logger, parent: t.parent}
+	videos := &VideosInterface{provider: t.parent.GetProvider(), logger: t.parent.logger, parent: t.parent}
+
+	resp, err := t.next.RoundTrip(req)
+	duration := time.Since(requestTime)
+	if err != nil {
+		switch method {
+		case "predict":
+			t.sendMedia(ctx, MeteringEventImage, images.buildImageErrorMeteringPayload(model, metadata, transactionID, duration, requestTime, err.Error(), request.requestedCount()))
+		case "predictLongRunning":
+			t.sendMedia(ctx, MeteringEventVideo, videos.buildVideoErrorMeteringPayload(model, metadata, transactionID, duration, requestTime, err.Error(), request.requestedCount()))
+		}
+		return resp, err
+	}
+
+	body, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if readErr != nil {
+		return resp, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		errorReason := fmt.Sprintf("Google API returned %d: %s", resp.StatusCode, googleErrorMessage(body))
+		switch method {
+		case "predict":
+			resp.Header.Set(TransactionIDHeader, transactionID)
+			t.sendMedia(ctx, MeteringEventImage, images.buildImageErrorMeteringPayload(model, metadata, transactionID, duration, requestTime, errorReason, request.requestedCount()))
+		case "predictLongRunning":
+			resp.Header.Set(TransactionIDHeader, transactionID)
+			t.sendMedia(ctx, MeteringEventVideo, videos.buildVideoErrorMeteringPayload(model, metadata, transactionID, duration, requestTime, errorReason, request.requestedCount()))
+		}
+		return resp, nil
+	}
+
+	switch method {
+	case "predict":
+		var predictions struct {
+			Predictions []struct {
+				BytesBase64Encoded string `json:"bytesBase64Encoded"`
+				GCSURI             string `json:"gcsUri"`
+			} `json:"predictions"`
+		}
+		json.Unmarshal(body, &predictions)
+		generated := &genai.GenerateImagesResponse{}
+		for _, prediction := range predictions.Predictions {
+			// Predictions filtered for safety carry a reason instead of an image
+			if prediction.BytesBase64Encoded != "" || prediction.GCSURI != "" {
+				generated.GeneratedImages = append(generated.GeneratedImages, &genai.GeneratedImage{})
+			}
+		}
+		resp.Header.Set(TransactionIDHeader, transactionID)
+		t.sendMedia(ctx, MeteringEventImage, images.buildImageMeteringPayload(generated, model, metadata, transactionID, duration, requestTime, request.requestedCount(), nil))
+
+	case "predictLongRunning":
+		var operation videoOperation
+		json.Unmarshal(body, &operation)
+		if operation.Name != "" {
+			t.operations.start(operation.Name, transactionID)
+		}
+		resp.Header.Set(TransactionIDHeader, transactionID)
+		t.sendMedia(ctx, MeteringEventVideo, videos.buildVideoOperationStartPayload(&genai.GenerateVideosOperation{Name: operation.Name, Done: operation.Done},
+			model, metadata, transactionID, duration, requestTime, request.requestedCount(), nil))
+
+	default:
+		var operation videoOperation
+		if err := json.Unmarshal(body, &operation); err != nil || !operation.Done {
+			return resp, nil
+		}
+		name := operation.Name
+		if name == "" {
+			name = pollName
+		}
+		startID, first := t.operations.finish(name)
+		if !first {
+			return resp, nil
+		}
+		if startID != "" {
+			// Meter the completion under the ID of the call that started it
+			transactionID = startID
+			metadata["transactionId"] = startID
+		}
+
+		resp.Header.Set(TransactionIDHeader, transactionID)
+		if operation.Error != nil {
+			t.sendMedia(ctx, MeteringEventVideo, videos.buildVideoErrorMeteringPayload(model, metadata, transactionID, duration, requestTime, operation.Error.Message, 0))
+			return resp, nil
+		}
+		samples := operation.Response.Videos
+		filtered := operation.Response.RAIMediaFilteredCount
+		if len(samples) == 0 {
+			samples = operation.Response.GenerateVideoResponse.GeneratedSamples
+			filtered = operation.Response.GenerateVideoResponse.RAIMediaFilteredCount
+		}
+		generated := &genai.GenerateVideosResponse{GeneratedVideos: make([]*genai.GeneratedVideo, len(samples)), RAIMediaFilteredCount: filtered}
+		t.sendMedia(ctx, MeteringEventVideo, videos.buildVideoCompletionPayload(generated, model, metadata, transactionID, duration, requestTime))
+	}
+	return resp, nil
+}
+
+// sendMedia sends an image or video metering event in the background
+func (t *meteringRoundTripper) sendMedia(ctx context.Context, eventType MeteringEventType, payload map[string]interface{}) {
+	addPayloadAttributes(payload, map[string]interface{}{transportMeteringAttribute: true})
+
+	t.parent.wg.Add(1)
+	go func() {
+		defer t.parent.wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				t.parent.logger.Error("Metering goroutine panic: %v", r)
+			}
+		}()
+
+		if err := t.parent.metering.send(ctx, eventType, payload); err != nil {
+			t.parent.logger.Error("Failed to send metering data: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,302 @@
+package revenium
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// transportMeteringAttribute marks events metered from the HTTP transport
+const transportMeteringAttribute = "meteredByTransport"
+
+// generateMethods are the REST methods metered from usageMetadata. Imagen and Veo calls
+// are metered by roundTripMedia; any other request passes through untouched.
+var generateMethods = map[string]bool{
+	"generateContent":       true,
+	"streamGenerateContent": true,
+}
+
+// WithTransportMetering installs a metering http.RoundTripper into the genai client,
+// so every generateContent call is metered, including calls made directly
+// through GetGenaiClient() such as Chats or newer SDK methods. Imagen and Veo calls
+// are metered as image and video events, like Images() and Videos() do.
+func WithTransportMetering() Option {
+	return func(c *Config) {
+		c.TransportMetering = true
+	}
+}
+
+// transportMeteringKey marks a request context as metered by a wrapper method
+type transportMeteringKey struct{}
+
+// skipTransportMetering marks ctx so the metering transport leaves the call to the
+// wrapper method that already meters it
+func skipTransportMetering(ctx context.Context) context.Context {
+	return context.WithValue(ctx, transportMeteringKey{}, true)
+}
+
+// meteringRoundTripper meters Gemini API and Vertex AI REST calls by reading
+// usageMetadata from JSON responses and server-sent event streams
+type meteringRoundTripper struct {
+	next   http.RoundTripper
+	parent *ReveniumGoogle
+	// operations tracks video operations so each is metered done once
+	operations *operationTracker
+}
+
+// installTransportMetering wraps the genai client's HTTP transport. The client must
+// have been created from an HTTP client the middleware owns.
+func (r *ReveniumGoogle) installTransportMetering(client *genai.Client) {
+	httpClient := client.ClientConfig().HTTPClient
+	next := httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	httpClient.Transport = &meteringRoundTripper{next: next, parent: r, operations: newOperationTracker()}
+}
+
+// RoundTrip forwards the request and meters the response as it is read
+func (t *meteringRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if skip, _ := req.Context().Value(transportMeteringKey{}).(bool); skip {
+		return t.next.RoundTrip(req)
+	}
+
+	model, method := parseGenaiPath(req.URL.Path)
+	if isMediaMethod(req, method) {
+		return t.roundTripMedia(req, model, method)
+	}
+	if !generateMethods[method] {
+		return t.next.RoundTrip(req)
+	}
+	call := &transportCall{
+		parent:      t.parent,
+		ctx:         req.Context(),
+		model:       model,
+		isStreamed:  method == "streamGenerateContent",
+		requestTime: time.Now(),
+	}
+	call.metadata, call.transactionID = withTransactionID(GetUsageMetadata(req.Context()))
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		call.finish(nil, NewNetworkError("Google request failed", err))
+		return resp, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		resp.Header.Set(TransactionIDHeader, call.transactionID)
+		call.finish(nil, fmt.Errorf("Google API returned %d: %s", resp.StatusCode, googleErrorMessage(body)))
+		return resp, nil
+	}
+
+	resp.Header.Set(TransactionIDHeader, call.transactionID)
+	if call.isStreamed {
+		resp.Body = &meteringStreamBody{body: resp.Body, call: call}
+		return resp, nil
+	}
+	if !strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return resp, nil
+	}
+
+	if chunk, ok := parseUsageChunk(body); ok {
+		call.completionStartTime = time.Now()
+		call.finish(chunk, nil)
+	}
+	return resp, nil
+}
+
+// transportCall holds what is known about one metered REST call
+type transportCall struct {
+	parent              *ReveniumGoogle
+	ctx                 context.Context
+	model               string
+	metadata            map[string]interface{}
+	transactionID       string
+	isStreamed          bool
+	requestTime         time.Time
+	completionStartTime time.Time
+}
+
+// finish sends the metering event in the background
+func (c *transportCall) finish(resp *genai.GenerateContentResponse, err error) {
+	responseTime := time.Now()
+	if c.completionStartTime.IsZero() {
+		c.completionStartTime = responseTime
+	}
+
+	model := c.model
+	if resp != nil && resp.ModelVersion != "" && model == "" {
+		model = resp.ModelVersion
+	}
+	provider := c.parent.GetProvider().String()
+
+	c.parent.wg.Add(1)
+	go func() {
+		defer c.parent.wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				c.parent.logger.Error("Metering goroutine panic: %v", r)
+			}
+		}()
+
+		payload := buildGoogleMeteringPayloadWithTimingAndVision(resp, model, c.metadata, c.isStreamed,
+			c.requestTime, c.completionStartTime, responseTime, provider, nil, err, VisionDetectionResult{}, c.parent.logger)
+		addPayloadAttributes(payload, map[string]interface{}{transportMeteringAttribute: true})
+
+		if err := c.parent.metering.send(c.ctx, MeteringEventCompletion, payload); err != nil {
+			c.parent.logger.Error("Failed to send metering data: %v", err)
+		}
+	}()
+}
+
+// meteringStreamBody passes a server-sent event stream through unchanged, keeping the
+// latest usageMetadata, and meters the call once the stream ends or is closed
+type meteringStreamBody struct {
+	body io.ReadCloser
+	call *transportCall
+
+	pending   []byte
+	last      *genai.GenerateContentResponse
+	firstSeen bool
+	once      sync.Once
+}
+
+// Read reads from the stream and inspects every complete line
+func (b *meteringStreamBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 {
+		b.scan(p[:n])
+	}
+	switch {
+	case err == io.EOF:
+		b.scan([]byte("\n"))
+		b.finish(nil)
+	case err != nil:
+		b.finish(NewNetworkError("Google stream failed", err))
+	}
+	return n, err
+}
+
+// Close closes the stream and meters what was received so far
+func (b *meteringStreamBody) Close() error {
+	err := b.body.Close()
+	b.finish(nil)
+	return err
+}
+
+// scan splits data into lines and parses "data:" events
+func (b *meteringStreamBody) scan(data []byte) {
+	b.pending = append(b.pending, data...)
+	for {
+		i := bytes.IndexByte(b.pending, '\n')
+		if i < 0 {
+			return
+		}
+		line := bytes.TrimSpace(b.pending[:i])
+		b.pending = b.pending[i+1:]
+
+		event, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			continue
+		}
+		if !b.firstSeen {
+			b.firstSeen = true
+			b.call.completionStartTime = time.Now()
+		}
+		if chunk, ok := parseUsageChunk(event); ok {
+			b.last = chunk
+		} else if b.last != nil {
+			// Later chunks without usage may still carry the finish reason
+			if finish := parseFinishReason(event); finish != "" {
+				b.last.Candidates = []*genai.Candidate{{FinishReason: finish}}
+			}
+		}
+	}
+}
+
+// finish meters the stream once. Streams that never reported usage are metered
+// only when they failed.
+func (b *meteringStreamBody) finish(err error) {
+	b.once.Do(func() {
+		if b.last == nil && err == nil {
+			return
+		}
+		b.call.finish(b.last, err)
+	})
+}
+
+// usageChunk is the part of a generateContent response the transport reads
+type usageChunk struct {
+	UsageMetadata *genai.GenerateContentResponseUsageMetadata `json:"usageMetadata"`
+	ModelVersion  string                                      `json:"modelVersion"`
+	Candidates    []struct {
+		FinishReason genai.FinishReason `json:"finishReason"`
+	} `json:"candidates"`
+}
+
+// parseUsageChunk decodes a response or stream event that reports usageMetadata
+func parseUsageChunk(data []byte) (*genai.GenerateContentResponse, bool) {
+	var chunk usageChunk
+	if err := json.Unmarshal(data, &chunk); err != nil || chunk.UsageMetadata == nil {
+		return nil, false
+	}
+
+	resp := &genai.GenerateContentResponse{UsageMetadata: chunk.UsageMetadata, ModelVersion: chunk.ModelVersion}
+	if len(chunk.Candidates) > 0 && chunk.Candidates[0].FinishReason != "" {
+		resp.Candidates = []*genai.Candidate{{FinishReason: chunk.Candidates[0].FinishReason}}
+	}
+	return resp, true
+}
+
+// parseFinishReason returns the first candidate's finish reason in a stream event
+func parseFinishReason(data []byte) genai.FinishReason {
+	var chunk usageChunk
+	if err := json.Unmarshal(data, &chunk); err != nil || len(chunk.Candidates) == 0 {
+		return ""
+	}
+	return chunk.Candidates[0].FinishReason
+}
+
+// parseGenaiPath extracts the model and REST method from a Gemini API or Vertex AI
+// path such as /v1beta/models/gemini-2.0-flash:generateContent or
+// /v1beta1/projects/p/locations/l/publishers/google/models/gemini-2.0-flash:streamGenerateContent
+func parseGenaiPath(path string) (model, method string) {
+	segment := path[strings.LastIndex(path, "/")+1:]
+	resource, method, _ := strings.Cut(segment, ":")
+	if strings.Contains(path, "/models/") {
+		model = resource
+	}
+	return model, method
+}
+
+// googleErrorMessage returns the message of a Google API error body, or the body itself
+func googleErrorMessage(body []byte) string {
+	var apiErr struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Error.Message != "" {
+		return fmt.Sprintf("%s (%s)", apiErr.Error.Message, apiErr.Error.Status)
+	}
+	return string(body)
+}
@@ -0,0 +1,208 @@
+package revenium
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// jsonResponse returns a 200 response carrying body as JSON
+func jsonResponse(body string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestParseGenaiPath(t *testing.T) {
+	tests := []struct {
+		path       string
+		wantModel  string
+		wantMethod string
+	}{
+		{"/v1beta/models/gemini-2.0-flash:generateContent", "gemini-2.0-flash", "generateContent"},
+		{"/v1beta1/projects/p/locations/us-central1/publishers/google/models/gemini-2.0-flash:streamGenerateContent", "gemini-2.0-flash", "streamGenerateContent"},
+		{"/v1beta1/projects/p/locations/us-central1/endpoints/123:generateContent", "", "generateContent"},
+		{"/v1beta/files", "", ""},
+	}
+
+	for _, tt := range tests {
+		model, method := parseGenaiPath(tt.path)
+		if model != tt.wantModel || method != tt.wantMethod {
+			t.Errorf("parseGenaiPath(%q) = %q, %q, want %q, %q", tt.path, model, method, tt.wantModel, tt.wantMethod)
+		}
+	}
+}
+
+func TestMeteringStreamBody_KeepsLatestUsage(t *testing.T) {
+	stream := "data: {\"candidates\":[{\"content\":{}}],\"usageMetadata\":{\"promptTokenCount\":5}}\n\n" +
+		"data: {\"candidates\":[{\"finishReason\":\"MAX_TOKENS\"}],\"usageMetadata\":{\"promptTokenCount\":5,\"candidatesTokenCount\":9},\"modelVersion\":\"gemini-2.0-flash-001\"}"
+	body := &meteringStreamBody{body: io.NopCloser(strings.NewReader(stream)), call: &transportCall{}}
+	// Leave out finish so no metering is sent without a parent client
+	body.once.Do(func() {})
+
+	data, err := io.ReadAll(body)
+	if err != nil || string(data) != stream {
+		t.Fatalf("stream must pass through unchanged, got %q (%v)", data, err)
+	}
+	if body.last == nil || body.last.UsageMetadata.CandidatesTokenCount != 9 {
+		t.Fatalf("expected the usage of the last event, got %+v", body.last)
+	}
+	if body.last.ModelVersion != "gemini-2.0-flash-001" || body.last.Candidates[0].FinishReason != "MAX_TOKENS" {
+		t.Errorf("unexpected model version or finish reason: %+v", body.last)
+	}
+	if body.call.completionStartTime.IsZero() {
+		t.Error("completion start time should be set by the first event")
+	}
+}
+
+func TestClose_WithPendingTransportEvent(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		sink := NewMemorySink()
+		client := newShutdownTestClient(t, sink)
+		call := &transportCall{parent: client, ctx: context.Background(), model: "gemini-2.0-flash", requestTime: time.Now()}
+		call.finish(nil, errors.New("boom"))
+
+		closed := make(chan error, 1)
+		go func() { closed <- client.Close() }()
+		select {
+		case err := <-closed:
+			if err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Close deadlocked with a transport event pending")
+		}
+		if sink.Len() != 1 {
+			t.Fatalf("expected the pending event to be sent, got %d", sink.Len())
+		}
+	}
+}
+
+func TestMeteringRoundTripper_PassesOtherBodiesThrough(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+	}{
+		{name: "file download", path: "/v1beta/files/abc:download", contentType: "application/octet-stream"},
+		{name: "non-JSON generate response", path: "/v1beta/models/gemini-2.0-flash:generateContent", contentType: "text/plain"},
+		{name: "other method", path: "/v1beta/models/gemini-2.0-flash:countTokens", contentType: "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := NewMemorySink()
+			client := newShutdownTestClient(t, sink)
+			body := io.NopCloser(strings.NewReader(`{"usageMetadata":{"promptTokenCount":5}}`))
+			transport := &meteringRoundTripper{parent: client, operations: newOperationTracker(), next: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": []string{tt.contentType}}, Body: body}, nil
+			})}
+
+			req, _ := http.NewRequest(http.MethodPost, "https://example.test"+tt.path, nil)
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip: %v", err)
+			}
+			if resp.Body != body {
+				t.Error("the response body must be passed through without buffering")
+			}
+			client.Flush()
+			if sink.Len() != 0 {
+				t.Errorf("expected no metering events, got %d", sink.Len())
+			}
+		})
+	}
+}
+
+func TestMeteringRoundTripper_OperationPolls(t *testing.T) {
+	const done = `{"name":"%s","done":true,"response":{"videos":[{"gcsUri":"gs://bucket/a.mp4"}]}}`
+
+	tests := []struct {
+		name       string
+		start      string
+		polls      []string
+		wantEvents int
+	}{
+		{name: "tuning job", polls: []string{"tunedModels/t/operations/1"}},
+		{name: "non-video model", polls: []string{"models/gemini-tuned/operations/2"}},
+		{name: "veo model polled repeatedly", polls: []string{"models/veo-2.0-generate-001/operations/3", "models/veo-2.0-generate-001/operations/3"}, wantEvents: 1},
+		{name: "operation started through the transport", start: "models/custom-video/operations/4", polls: []string{"models/custom-video/operations/4"}, wantEvents: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := NewMemorySink()
+			client := newShutdownTestClient(t, sink)
+			transport := &meteringRoundTripper{parent: client, operations: newOperationTracker(), next: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				return jsonResponse(fmt.Sprintf(done, strings.TrimPrefix(req.URL.Path, "/v1beta/"))), nil
+			})}
+			if tt.start != "" {
+				transport.operations.start(tt.start, "txn-start")
+			}
+
+			for _, poll := range tt.polls {
+				req, _ := http.NewRequest(http.MethodGet, "https://example.test/v1beta/"+poll, nil)
+				if _, err := transport.RoundTrip(req); err != nil {
+					t.Fatalf("RoundTrip: %v", err)
+				}
+			}
+			client.Flush()
+
+			if sink.Len() != tt.wantEvents {
+				t.Errorf("expected %d metering events, got %d", tt.wantEvents, sink.Len())
+			}
+			if tt.start != "" {
+				if transport.operations.started(tt.start) {
+					t.Error("a metered operation must no longer be tracked as started")
+				}
+				if id := sink.Events()[0].TransactionID(); id != "txn-start" {
+					t.Errorf("expected the completion to reuse the start transaction ID, got %q", id)
+				}
+			}
+		})
+	}
+}
+
+func TestOperationTracker_Bounded(t *testing.T) {
+	now := time.Now()
+	tracker := newOperationTracker()
+	tracker.now = func() time.Time { return now }
+
+	tracker.start("op-1", "txn-1")
+	if id, first := tracker.finish("op-1"); !first || id != "txn-1" {
+		t.Errorf("finish = %q, %v, want the start transaction ID", id, first)
+	}
+	if _, first := tracker.finish("op-1"); first {
+		t.Error("an operation must be finished exactly once")
+	}
+
+	now = now.Add(operationTrackerTTL)
+	tracker.start("op-2", "")
+	if tracker.size() != 1 {
+		t.Errorf("expired entries must be evicted, %d remain", tracker.size())
+	}
+
+	for i := 0; i < operationTrackerMaxEntries+10; i++ {
+		tracker.start(fmt.Sprintf("op-%d", i+3), "")
+	}
+	if tracker.size() != operationTrackerMaxEntries {
+		t.Errorf("expected at most %d entries, got %d", operationTrackerMaxEntries, tracker.size())
+	}
+	if tracker.started("op-2") {
+		t.Error("the oldest entry must be evicted first")
+	}
+}
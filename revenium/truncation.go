@@ -0,0 +1,202 @@
+package revenium
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// TruncationStrategy selects which part of over-long captured content is kept
+type TruncationStrategy string
+
+const (
+	// TruncateHead keeps the beginning of the content and drops the end
+	TruncateHead TruncationStrategy = "head"
+	// TruncateTail keeps the end of the content and drops the beginning
+	TruncateTail TruncationStrategy = "tail"
+	// TruncateHeadTail keeps the beginning and the end and elides the middle
+	TruncateHeadTail TruncationStrategy = "head_tail"
+	// TruncateNewestFirst fills the input message budget from the newest message
+	// backwards, dropping the oldest messages first. Single fields keep their head.
+	TruncateNewestFirst TruncationStrategy = "newest_first"
+)
+
+// IsValid reports whether the strategy is one of the known values
+func (s TruncationStrategy) IsValid() bool {
+	switch s {
+	case TruncateHead, TruncateTail, TruncateHeadTail, TruncateNewestFirst:
+		return true
+	}
+	return false
+}
+
+// ParseTruncationStrategy parses a strategy name (case-insensitive, "-" and "_" are equivalent)
+func ParseTruncationStrategy(value string) (TruncationStrategy, bool) {
+	normalized := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(value)), "-", "_")
+	strategy := TruncationStrategy(normalized)
+	if !strategy.IsValid() {
+		return "", false
+	}
+	return strategy, true
+}
+
+// PromptLimits controls how captured prompts and responses are truncated
+type PromptLimits struct {
+	// MaxFieldLength caps the system prompt and the output response, in bytes
+	MaxFieldLength int
+	// MaxInputMessagesLength is the total byte budget shared by all input message contents
+	MaxInputMessagesLength int
+	// Strategy selects which part of over-long content is kept
+	Strategy TruncationStrategy
+}
+
+// DefaultPromptLimits returns the limits used when nothing is configured
+func DefaultPromptLimits() PromptLimits {
+	return PromptLimits{
+		MaxFieldLength:         MaxPromptLength,
+		MaxInputMessagesLength: MaxPromptLength,
+		Strategy:               TruncateHead,
+	}
+}
+
+// normalized fills unset or invalid values with defaults
+func (l PromptLimits) normalized() PromptLimits {
+	defaults := DefaultPromptLimits()
+	if l.MaxFieldLength <= 0 {
+		l.MaxFieldLength = defaults.MaxFieldLength
+	}
+	if l.MaxInputMessagesLength <= 0 {
+		l.MaxInputMessagesLength = defaults.MaxInputMessagesLength
+	}
+	if !l.Strategy.IsValid() {
+		l.Strategy = defaults.Strategy
+	}
+	return l
+}
+
+// truncateWithStrategy truncates s to at most maxBytes (marker included) using the given strategy.
+// It returns the content unchanged when it already fits. A limit too small for the whole
+// marker keeps as much of the marker as fits.
+func truncateWithStrategy(s string, maxBytes int, strategy TruncationStrategy) (string, bool) {
+	if len(s) <= maxBytes {
+		return s, false
+	}
+
+	markerLen := len(TruncationMarker)
+	if maxBytes <= markerLen {
+		return TruncationMarker[:max(maxBytes, 0)], true
+	}
+	keep := maxBytes - markerLen
+
+	switch strategy {
+	case TruncateTail:
+		return TruncationMarker + truncateUTF8SafeTail(s, keep), true
+	case TruncateHeadTail:
+		headLen := keep / 2
+		head := truncateUTF8Safe(s, headLen)
+		tail := truncateUTF8SafeTail(s, keep-len(head))
+		return head + TruncationMarker + tail, true
+	default:
+		return truncateUTF8Safe(s, keep) + TruncationMarker, true
+	}
+}
+
+// truncateUTF8SafeTail keeps the last maxBytes of s while preserving UTF-8 validity
+func truncateUTF8SafeTail(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	if maxBytes <= 0 {
+		return ""
+	}
+
+	start := len(s) - maxBytes
+	// Move forward past continuation bytes so we start on a rune boundary
+	for start < len(s) && !utf8.RuneStart(s[start]) {
+		start++
+	}
+	return s[start:]
+}
+
+// allocateMessageBudgetKeeping is allocateMessageBudget with the message at index keep
+// (the newest user turn) served first; the other messages share what is left. A
+// negative keep reserves nothing.
+func allocateMessageBudgetKeeping(lengths []int, budget int, strategy TruncationStrategy, keep int) []int {
+	if keep < 0 || keep >= len(lengths) {
+		return allocateMessageBudget(lengths, budget, strategy)
+	}
+
+	reserved := min(lengths[keep], budget)
+	others := append(append([]int(nil), lengths[:keep]...), lengths[keep+1:]...)
+	shared := allocateMessageBudget(others, budget-reserved, strategy)
+
+	allocations := make([]int, 0, len(lengths))
+	allocations = append(allocations, shared[:keep]...)
+	allocations = append(allocations, reserved)
+	return append(allocations, shared[keep:]...)
+}
+
+// allocateMessageBudget distributes budget bytes across messages with the given lengths.
+// A negative entry in the result means the message is dropped.
+//
+// For TruncateNewestFirst the budget is filled from the newest message backwards so the
+// oldest messages are dropped first. For the other strategies the budget is shared fairly:
+// messages shorter than an equal share keep their full length and the remainder is split
+// between the longer ones, so no message is dropped entirely.
+func allocateMessageBudget(lengths []int, budget int, strategy TruncationStrategy) []int {
+	allocations := make([]int, len(lengths))
+
+	total := 0
+	for _, n := range lengths {
+		total += n
+	}
+	if total <= budget {
+		copy(allocations, lengths)
+		return allocations
+	}
+
+	if strategy == TruncateNewestFirst {
+		remaining := budget
+		for i := len(lengths) - 1; i >= 0; i-- {
+			switch {
+			case remaining <= 0:
+				allocations[i] = -1
+			case lengths[i] <= remaining:
+				allocations[i] = lengths[i]
+				remaining -= lengths[i]
+			default:
+				allocations[i] = remaining
+				remaining = 0
+			}
+		}
+		return allocations
+	}
+
+	// Fair share (water-filling): repeatedly grant short messages their full length
+	// and recompute the share for the rest
+	settled := make([]bool, len(lengths))
+	remaining := budget
+	open := len(lengths)
+	for open > 0 {
+		share := remaining / open
+		progressed := false
+		for i, n := range lengths {
+			if settled[i] || n > share {
+				continue
+			}
+			allocations[i] = n
+			remaining -= n
+			settled[i] = true
+			open--
+			progressed = true
+		}
+		if !progressed {
+			for i := range lengths {
+				if !settled[i] {
+					allocations[i] = share
+				}
+			}
+			break
+		}
+	}
+	return allocations
+}
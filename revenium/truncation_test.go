@@ -0,0 +1,211 @@
+package revenium
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"google.golang.org/genai"
+)
+
+func TestTruncateWithStrategy(t *testing.T) {
+	content := strings.Repeat("a", 50) + strings.Repeat("b", 50)
+	maxBytes := 40
+
+	tests := []struct {
+		name       string
+		strategy   TruncationStrategy
+		wantPrefix string
+		wantSuffix string
+	}{
+		{name: "head", strategy: TruncateHead, wantPrefix: "aaaa", wantSuffix: TruncationMarker},
+		{name: "tail", strategy: TruncateTail, wantPrefix: TruncationMarker, wantSuffix: "bbbb"},
+		{name: "head_tail", strategy: TruncateHeadTail, wantPrefix: "aaaa", wantSuffix: "bbbb"},
+		{name: "newest_first keeps head", strategy: TruncateNewestFirst, wantPrefix: "aaaa", wantSuffix: TruncationMarker},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := truncateWithStrategy(content, maxBytes, tt.strategy)
+			if !truncated {
+				t.Fatal("expected content to be truncated")
+			}
+			if len(got) != maxBytes {
+				t.Errorf("length: got %d, want %d", len(got), maxBytes)
+			}
+			if !strings.HasPrefix(got, tt.wantPrefix) || !strings.HasSuffix(got, tt.wantSuffix) {
+				t.Errorf("unexpected result %q", got)
+			}
+			if !strings.Contains(got, TruncationMarker) {
+				t.Errorf("expected truncation marker in %q", got)
+			}
+		})
+	}
+}
+
+func TestTruncateWithStrategy_UTF8(t *testing.T) {
+	content := strings.Repeat("日本語", 20)
+
+	for _, strategy := range []TruncationStrategy{TruncateHead, TruncateTail, TruncateHeadTail} {
+		got, _ := truncateWithStrategy(content, 31, strategy)
+		if !utf8.ValidString(got) {
+			t.Errorf("%s: result is not valid UTF-8: %q", strategy, got)
+		}
+		if len(got) > 31 {
+			t.Errorf("%s: result exceeds limit: %d", strategy, len(got))
+		}
+	}
+}
+
+func TestTruncateWithStrategy_LimitBelowMarker(t *testing.T) {
+	for _, maxBytes := range []int{0, 1, 5, len(TruncationMarker)} {
+		got, truncated := truncateWithStrategy(strings.Repeat("a", 100), maxBytes, TruncateHead)
+		if !truncated || len(got) > maxBytes {
+			t.Errorf("maxBytes %d: got %q (truncated %v)", maxBytes, got, truncated)
+		}
+	}
+}
+
+func TestExtractPromptsFromRequestWithLimits_TinyBudget(t *testing.T) {
+	var contents []*genai.Content
+	for i := 0; i < 50; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "model"
+		}
+		contents = append(contents, &genai.Content{Role: role, Parts: []*genai.Part{{Text: strings.Repeat("message ", 20)}}})
+	}
+	contents = append(contents, &genai.Content{Role: "user", Parts: []*genai.Part{{Text: "latest question"}}})
+
+	for _, strategy := range []TruncationStrategy{TruncateHead, TruncateTail, TruncateHeadTail, TruncateNewestFirst} {
+		t.Run(string(strategy), func(t *testing.T) {
+			limits := PromptLimits{MaxFieldLength: 1000, MaxInputMessagesLength: 100, Strategy: strategy}
+			result := ExtractPromptsFromRequestWithLimits(contents, nil, limits)
+
+			if !result.PromptsTruncated {
+				t.Error("expected PromptsTruncated to be true")
+			}
+
+			var messages []map[string]interface{}
+			if err := json.Unmarshal([]byte(result.InputMessages), &messages); err != nil {
+				t.Fatalf("input messages are not valid JSON: %v", err)
+			}
+			if len(messages) == 0 || messages[len(messages)-1]["content"] != "latest question" {
+				t.Fatalf("latest user turn was not preserved: %v", messages)
+			}
+
+			total := 0
+			for _, msg := range messages {
+				total += len(msg["content"].(string))
+			}
+			if total > limits.MaxInputMessagesLength {
+				t.Errorf("total content %d exceeds budget %d", total, limits.MaxInputMessagesLength)
+			}
+		})
+	}
+}
+
+func TestParseTruncationStrategy(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   TruncationStrategy
+		wantOK bool
+	}{
+		{"head", TruncateHead, true},
+		{"TAIL", TruncateTail, true},
+		{"head-tail", TruncateHeadTail, true},
+		{" newest_first ", TruncateNewestFirst, true},
+		{"middle", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseTruncationStrategy(tt.input)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ParseTruncationStrategy(%q) = %q, %v; want %q, %v", tt.input, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestAllocateMessageBudget(t *testing.T) {
+	t.Run("fits", func(t *testing.T) {
+		got := allocateMessageBudget([]int{10, 20}, 100, TruncateHead)
+		if got[0] != 10 || got[1] != 20 {
+			t.Errorf("got %v", got)
+		}
+	})
+
+	t.Run("fair share keeps short messages whole", func(t *testing.T) {
+		got := allocateMessageBudget([]int{10, 500, 500}, 210, TruncateHead)
+		if got[0] != 10 || got[1] != 100 || got[2] != 100 {
+			t.Errorf("got %v", got)
+		}
+	})
+
+	t.Run("newest first drops oldest", func(t *testing.T) {
+		got := allocateMessageBudget([]int{100, 100, 50}, 120, TruncateNewestFirst)
+		if got[2] != 50 || got[1] != 70 || got[0] >= 0 {
+			t.Errorf("got %v", got)
+		}
+	})
+}
+
+func TestAllocateMessageBudgetKeeping(t *testing.T) {
+	got := allocateMessageBudgetKeeping([]int{100, 100, 100, 30}, 40, TruncateHead, 3)
+	if got[3] != 30 || got[0]+got[1]+got[2] > 10 {
+		t.Errorf("expected the kept message to be served first, got %v", got)
+	}
+}
+
+func TestExtractPromptsFromRequestWithLimits_KeepsLatestTurn(t *testing.T) {
+	contents := []*genai.Content{
+		{Role: "user", Parts: []*genai.Part{{Text: strings.Repeat("old ", 500)}}},
+		{Role: "model", Parts: []*genai.Part{{Text: strings.Repeat("reply ", 500)}}},
+		{Role: "user", Parts: []*genai.Part{{Text: "latest question"}}},
+	}
+
+	for _, strategy := range []TruncationStrategy{TruncateHead, TruncateTail, TruncateHeadTail, TruncateNewestFirst} {
+		t.Run(string(strategy), func(t *testing.T) {
+			limits := PromptLimits{MaxFieldLength: 1000, MaxInputMessagesLength: 300, Strategy: strategy}
+			result := ExtractPromptsFromRequestWithLimits(contents, nil, limits)
+
+			if !result.PromptsTruncated {
+				t.Error("expected PromptsTruncated to be true")
+			}
+
+			var messages []map[string]interface{}
+			if err := json.Unmarshal([]byte(result.InputMessages), &messages); err != nil {
+				t.Fatalf("input messages are not valid JSON: %v", err)
+			}
+
+			last := messages[len(messages)-1]
+			if last["content"] != "latest question" {
+				t.Errorf("latest user turn was not preserved: %v", last["content"])
+			}
+
+			total := 0
+			for _, msg := range messages {
+				total += len(msg["content"].(string))
+			}
+			if total > limits.MaxInputMessagesLength {
+				t.Errorf("total content %d exceeds budget %d", total, limits.MaxInputMessagesLength)
+			}
+		})
+	}
+}
+
+func TestConfigPromptLimits(t *testing.T) {
+	cfg := &Config{MaxPromptLength: 100}
+	limits := cfg.PromptLimits()
+
+	if limits.MaxFieldLength != 100 {
+		t.Errorf("MaxFieldLength: got %d, want 100", limits.MaxFieldLength)
+	}
+	if limits.MaxInputMessagesLength != MaxPromptLength {
+		t.Errorf("MaxInputMessagesLength: got %d, want %d", limits.MaxInputMessagesLength, MaxPromptLength)
+	}
+	if limits.Strategy != TruncateHead {
+		t.Errorf("Strategy: got %q, want %q", limits.Strategy, TruncateHead)
+	}
+}